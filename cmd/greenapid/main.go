@@ -1,18 +1,112 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"net/http"
+	"os"
+	"slices"
+	"strings"
 
 	"github.com/jbowens/codenamesgreen/gameapi"
+	"github.com/jbowens/codenamesgreen/gameapi/ai"
 )
 
 func main() {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	brokerAddr := flag.String("broker", "", "address of a greenbrokerd hub to share games with other instances")
+	vectors := flag.String("vectors", "", "path to word vectors in GloVe or word2vec text format, used to suggest clues and play bots")
+	origins := flag.String("allowed-origins", strings.Join(gameapi.DefaultCORSPolicy.AllowedOrigins, ","),
+		"comma-separated origins allowed to make cross-origin requests, like https://*.codenamesgreen.com, or * for any origin; empty to allow none")
+	credentials := flag.Bool("allow-credentials", false, "allow cross-origin requests with cookies; requires -allowed-origins without *")
+	trustProxy := flag.Bool("trust-proxy", false, "identify clients by the X-Forwarded-For header when rate limiting, for instances behind a proxy")
+	maxMessage := flag.Int("max-message-length", gameapi.DefaultMaxMessageLength, "longest chat message allowed, in characters")
+	wordFilter := flag.String("word-filter", "", "path to a list of words, one per line, to mask in chat messages")
+	rejectWords := flag.Bool("reject-filtered", false, "reject chat messages containing words from -word-filter instead of masking them")
+	adminToken := flag.String("admin-token", os.Getenv("GREENAPID_ADMIN_TOKEN"), "token that enables the admin API under /admin; defaults to $GREENAPID_ADMIN_TOKEN")
+	inviteKey := flag.String("invite-key", os.Getenv("GREENAPID_INVITE_KEY"), "key that signs invites to private games, shared by instances using -broker; defaults to $GREENAPID_INVITE_KEY")
+	history := flag.String("history", "", "path to a file to record finished games in, so that players' statistics survive restarts")
+	accounts := flag.String("accounts", "", "path to a file to store player accounts in, which enables accounts; can't be used with -broker")
+	flag.Parse()
+
 	wordLists, err := gameapi.DefaultWordlists()
 	if err != nil {
 		panic(err)
 	}
 
-	h := gameapi.Handler(wordLists)
-	err = http.ListenAndServe(":8080", h)
+	cors := gameapi.DefaultCORSPolicy
+	cors.AllowedOrigins = nil
+	if *origins != "" {
+		cors.AllowedOrigins = strings.Split(*origins, ",")
+	}
+	cors.AllowCredentials = *credentials
+	if *credentials && slices.Contains(cors.AllowedOrigins, "*") {
+		usageError("-allow-credentials can't be used with -allowed-origins *")
+	}
+	if *accounts != "" && *brokerAddr != "" {
+		// Accounts and sessions are only known to the instance that
+		// stores them, so other instances would let anyone play as
+		// an account's player.
+		usageError("-accounts can't be used with -broker")
+	}
+	limits := gameapi.DefaultRateLimits
+	limits.TrustForwardedFor = *trustProxy
+	opts := []gameapi.Option{gameapi.WithCORS(cors), gameapi.WithRateLimits(limits)}
+	opts = append(opts, gameapi.WithMaxMessageLength(*maxMessage))
+	if *wordFilter != "" {
+		f, err := gameapi.LoadWordFilter(*wordFilter, *rejectWords)
+		if err != nil {
+			panic(err)
+		}
+		opts = append(opts, gameapi.WithChatFilter(f))
+	}
+	if *adminToken != "" {
+		opts = append(opts, gameapi.WithAdminToken(*adminToken))
+	}
+	if *inviteKey != "" {
+		opts = append(opts, gameapi.WithInviteKey([]byte(*inviteKey)))
+	}
+
+	if *history != "" {
+		hist, err := gameapi.OpenGameHistory(*history)
+		if err != nil {
+			panic(err)
+		}
+		opts = append(opts, gameapi.WithGameHistory(hist))
+	}
+	if *accounts != "" {
+		db, err := gameapi.OpenAccountDB(*accounts)
+		if err != nil {
+			panic(err)
+		}
+		opts = append(opts, gameapi.WithAccounts(db))
+	}
+
+	if *brokerAddr != "" {
+		b, err := gameapi.DialBroker(*brokerAddr)
+		if err != nil {
+			panic(err)
+		}
+		opts = append(opts, gameapi.WithBroker(b))
+	}
+
+	if *vectors != "" {
+		emb, err := ai.LoadEmbeddingsFile(*vectors)
+		if err != nil {
+			panic(err)
+		}
+		opts = append(opts,
+			gameapi.WithSpymaster(ai.NewSpymaster(emb)),
+			gameapi.WithGuesser(ai.NewGuesser(emb)))
+	}
+
+	h := gameapi.Handler(wordLists, opts...)
+	err = http.ListenAndServe(*addr, h)
 	panic(err)
 }
+
+// usageError reports flags that can't be used together and exits.
+func usageError(msg string) {
+	fmt.Fprintln(os.Stderr, "greenapid:", msg)
+	os.Exit(2)
+}
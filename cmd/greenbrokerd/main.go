@@ -0,0 +1,20 @@
+package main
+
+import (
+	"flag"
+	"net"
+
+	"github.com/jbowens/codenamesgreen/gameapi"
+)
+
+func main() {
+	addr := flag.String("addr", ":8081", "address to listen for greenapid instances on")
+	flag.Parse()
+
+	l, err := net.Listen("tcp", *addr)
+	if err != nil {
+		panic(err)
+	}
+	err = gameapi.ServeBroker(l)
+	panic(err)
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jbowens/codenamesgreen/gameapi"
+	"github.com/jbowens/codenamesgreen/gameapi/client"
+)
+
+// ANSI escape sequences used to draw the screen.
+const (
+	clearScreen = "\x1b[H\x1b[2J"
+	reset       = "\x1b[0m"
+	bold        = "\x1b[1m"
+	dim         = "\x1b[2m"
+	greenText   = "\x1b[32m"
+	redText     = "\x1b[31m"
+	foundGreen  = "\x1b[30;42m"
+	foundBlack  = "\x1b[97;41m"
+)
+
+const (
+	cellWidth = 14
+	logLines  = 8
+)
+
+// view holds everything that's drawn on the screen.
+type view struct {
+	game    *client.Game
+	events  []gameapi.Event
+	team    int
+	message string // feedback on the last command
+}
+
+func sideName(team int) string {
+	switch team {
+	case 1:
+		return "A"
+	case 2:
+		return "B"
+	default:
+		return "?"
+	}
+}
+
+// render draws the screen: the game's status, the board as seen
+// by the player's side, recent events and the prompt.
+func (v *view) render(w io.Writer) {
+	g := gameapi.Game{
+		GameState: gameapi.GameState{Events: v.events},
+		OneLayout: v.game.OneLayout,
+		TwoLayout: v.game.TwoLayout,
+	}
+	st := g.Status()
+
+	fmt.Fprint(w, clearScreen)
+	fmt.Fprintf(w, "%sCodenames Green%s  game %s  side %s\n", bold, reset, v.game.ID, sideName(v.team))
+	fmt.Fprintf(w, "Greens left: %d  Timer tokens used: %d  ", st.GreensLeft, st.TokensConsumed)
+	switch {
+	case st.Won:
+		fmt.Fprintf(w, "%sYou won!%s\n\n", greenText, reset)
+	case st.Lost:
+		fmt.Fprintf(w, "%sYou lost.%s\n\n", redText, reset)
+	case st.Ended:
+		fmt.Fprint(w, "The game is over.\n\n")
+	case st.Turn == 0:
+		fmt.Fprint(w, "Either side may start\n\n")
+	default:
+		fmt.Fprintf(w, "Side %s is guessing\n\n", sideName(st.Turn))
+	}
+
+	fmt.Fprint(w, "    ")
+	for col := 0; col < 5; col++ {
+		fmt.Fprintf(w, " %-*c", cellWidth, 'A'+col)
+	}
+	fmt.Fprintln(w)
+	for row := 0; row < 5; row++ {
+		fmt.Fprintf(w, " %d  ", row+1)
+		for col := 0; col < 5; col++ {
+			fmt.Fprint(w, " "+v.cell(row*5+col, st))
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "\n%sYour key: %sgreen%s, %sblack%s. A mark shows a side guessed a tan card there.%s\n\n",
+		dim, greenText+bold, reset+dim, redText+bold, reset+dim, reset)
+
+	start := 0
+	if len(v.events) > logLines {
+		start = len(v.events) - logLines
+	}
+	for _, e := range v.events[start:] {
+		if line := v.describe(e); line != "" {
+			fmt.Fprintln(w, line)
+		}
+	}
+	if v.message != "" {
+		fmt.Fprintf(w, "\n%s%s%s\n", dim, v.message, reset)
+	}
+	fmt.Fprint(w, "\n> ")
+}
+
+// add adds an event to the log. Chat messages that have
+// been removed by a moderator are hidden.
+func (v *view) add(e gameapi.Event) {
+	if e.Type == "chat_removed" {
+		for i, old := range v.events {
+			if old.Type == "chat" && old.Number == e.Index {
+				v.events[i].Type = "chat_removed"
+			}
+		}
+	}
+	v.events = append(v.events, e)
+}
+
+// playerID returns the ID of the player with the name,
+// as of the most recent event that names them.
+func (v *view) playerID(name string) (string, bool) {
+	for i := len(v.events) - 1; i >= 0; i-- {
+		e := v.events[i]
+		if (e.Type == "join_side" || e.Type == "change_name") && strings.EqualFold(e.Name, name) {
+			return e.PlayerID, true
+		}
+	}
+	return "", false
+}
+
+// cell renders the card at index i, padded to cellWidth.
+func (v *view) cell(i int, st gameapi.Status) string {
+	word := v.game.Words[i]
+	var marks string
+	for team := 1; team <= 2; team++ {
+		// A guess by one side reveals the card on the other side's key.
+		other := 3 - team
+		if st.Revealed[other-1][i] && v.layout(other)[i] == gameapi.Tan {
+			marks += "×" + sideName(team)
+		}
+	}
+	if max := cellWidth - len([]rune(marks)) - 1; len([]rune(word)) > max {
+		word = string([]rune(word)[:max])
+	}
+	text := word
+	if marks != "" {
+		text += " " + marks
+	}
+	text += strings.Repeat(" ", cellWidth-len([]rune(text)))
+
+	switch {
+	case v.revealed(i, st, gameapi.Black):
+		return foundBlack + text + reset
+	case v.revealed(i, st, gameapi.Green):
+		return foundGreen + text + reset
+	}
+	if v.team == 0 {
+		return text
+	}
+	switch v.layout(v.team)[i] {
+	case gameapi.Green:
+		return greenText + bold + text + reset
+	case gameapi.Black:
+		return redText + bold + text + reset
+	default:
+		return text
+	}
+}
+
+// revealed returns true if the card at index i has been revealed
+// as the color on either side's key.
+func (v *view) revealed(i int, st gameapi.Status, c gameapi.Color) bool {
+	return (st.Revealed[0][i] && v.game.OneLayout[i] == c) ||
+		(st.Revealed[1][i] && v.game.TwoLayout[i] == c)
+}
+
+func (v *view) layout(team int) []gameapi.Color {
+	if team == 2 {
+		return v.game.TwoLayout
+	}
+	return v.game.OneLayout
+}
+
+// describe formats an event for the log, like the web app does.
+func (v *view) describe(e gameapi.Event) string {
+	switch e.Type {
+	case "join_side":
+		return fmt.Sprintf("%s has joined side %s.", e.Name, sideName(e.Team))
+	case "player_left":
+		return fmt.Sprintf("%s has left the game.", e.Name)
+	case "guess":
+		if e.Index < 0 || e.Index >= len(v.game.Words) {
+			return ""
+		}
+		return fmt.Sprintf("Side %s tapped %s (%s).", sideName(e.Team), v.game.Words[e.Index], coordinate(e.Index))
+	case "chat":
+		if e.Scope == gameapi.ScopeSide {
+			return fmt.Sprintf("%s (%s, to side): %s", e.Name, sideName(e.Team), e.Message)
+		}
+		return fmt.Sprintf("%s (%s): %s", e.Name, sideName(e.Team), e.Message)
+	case "end_turn":
+		return fmt.Sprintf("Side %s took a timer token ending the turn.", sideName(e.Team))
+	case "clue":
+		return fmt.Sprintf("%s gave the clue %s for %d.", e.Name, e.Message, e.Index)
+	case "player_kicked":
+		return fmt.Sprintf("%s was removed from the game.", e.Name)
+	case "player_muted":
+		return fmt.Sprintf("%s was muted by the host.", e.Name)
+	case "game_ended":
+		return "The game was ended by a moderator."
+	case "host_changed":
+		return fmt.Sprintf("%s is now the host.", e.Name)
+	case "teams_locked":
+		return fmt.Sprintf("%s locked the teams.", e.Name)
+	case "teams_unlocked":
+		return fmt.Sprintf("%s unlocked the teams.", e.Name)
+	case "word_list_changed":
+		if e.Message == "" {
+			return fmt.Sprintf("%s chose to play with all of the words.", e.Name)
+		}
+		return fmt.Sprintf("%s chose the %s word list.", e.Name, e.Message)
+	case "passcode_changed":
+		return fmt.Sprintf("%s set a new passcode. Earlier invites no longer work.", e.Name)
+	case "passcode_removed":
+		return fmt.Sprintf("%s made the game public.", e.Name)
+	default:
+		return ""
+	}
+}
+
+// coordinate formats a card's index like "C4".
+func coordinate(i int) string {
+	return fmt.Sprintf("%c%d", 'A'+i%5, i/5+1)
+}
+
+// parseCoordinate parses coordinates like "C4" or "4c" into the
+// index of a card.
+func parseCoordinate(s string) (int, bool) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if len(s) != 2 {
+		return 0, false
+	}
+	col, row := s[0], s[1]
+	if col >= '1' && col <= '5' {
+		col, row = row, col
+	}
+	if col < 'A' || col > 'E' || row < '1' || row > '5' {
+		return 0, false
+	}
+	return int(row-'1')*5 + int(col-'A'), true
+}
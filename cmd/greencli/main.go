@@ -0,0 +1,375 @@
+// Command greencli plays Codenames Green in a terminal.
+//
+//	greencli -name Alice -side a some-game
+//
+// Type "help" once it's running for the commands it understands.
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jbowens/codenamesgreen/gameapi"
+	"github.com/jbowens/codenamesgreen/gameapi/client"
+)
+
+const help = `a, b: join side A or B.  C4: guess the card in column C, row 4.  end: end your side's turn.
+clue <word> <number>: give the other side a clue.  bot [a or b]: add a bot to a side, the other one by default.
+say <message>: chat.  side <message>: chat with your side.  name <name>: change your name.  quit: leave the game.
+practice: start a practice game, guessing on side B at a computer's clues.  score: show how practice is going.
+As the host: new: start the next game.  lock, unlock: lock or unlock the teams.  kick <name>, host <name>.
+invite: invite players to a private game.  stats: show your statistics.
+leaderboard [month]: show the top teams this month, or in a month like 2026-10.`
+
+// update is sent by the goroutine streaming the game's events.
+type update struct {
+	event gameapi.Event
+	reset bool
+	seed  gameapi.Seed
+	err   error
+}
+
+func main() {
+	server := flag.String("server", "https://api.codenamesgreen.com", "URL of the Codenames Green API")
+	name := flag.String("name", os.Getenv("USER"), "your name")
+	side := flag.String("side", "", "the side to join, a or b")
+	passcode := flag.String("passcode", "", "the private game's passcode, which new games are created with")
+	invite := flag.String("invite", "", "an invite to a private game")
+	public := flag.Bool("public", false, "list the game in the lobby if it's created")
+	roomName := flag.String("room-name", "", "the name to list a public game under")
+	match := flag.Bool("match", false, "wait to be paired with another player instead of joining a game by ID")
+	wordList := flag.String("word-list", "", "with -match, the word list you'd like to play with")
+	language := flag.String("language", "", "with -match, the language you'd like to chat in, like en")
+	user := flag.String("user", "", "sign in to this account, with the password in $GREENCLI_PASSWORD")
+	register := flag.Bool("register", false, "with -user, create the account first")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: greencli [flags] <game id>\n       greencli [flags] -match\n       greencli [-server url]   (lists public games)\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 && !*match {
+		if err := listLobby(context.Background(), client.New(*server, nil)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if flag.NArg() != 1 && !*match || flag.NArg() != 0 && *match {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	team, ok := parseSide(*side)
+	if !ok && *side != "" || *register && *user == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// The player who creates the game hosts it.
+	ctx := context.Background()
+	c := client.New(*server, nil)
+	c.Access = *passcode
+	if *invite != "" {
+		c.Access = *invite
+	}
+	var id string
+	if *user != "" {
+		a, err := signIn(ctx, c, *user, *register)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer c.Logout(ctx)
+		id, *name = a.PlayerID, a.DisplayName
+		if *side == "" {
+			team = a.Preferences.Side
+		}
+	}
+	p := c.Player(&client.Game{ID: flag.Arg(0)}, id, *name, team)
+	if *match {
+		fmt.Println("Waiting for another player...")
+		m, err := c.FindMatch(ctx, gameapi.MatchmakingRequest{
+			PlayerID: p.ID,
+			Name:     p.Name,
+			WordList: *wordList,
+			Language: *language,
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		p.GameID, p.Team, team = m.GameID, m.Team, m.Team
+		c.Access = m.Invite
+	}
+	g, err := c.Game(ctx, p.GameID)
+	var apiErr *client.Error
+	if errors.As(err, &apiErr) && apiErr.StatusCode == 404 {
+		g, err = c.NewGame(ctx, p.GameID, nil, gameapi.NewGameRequest{
+			PlayerID: p.ID,
+			Passcode: *passcode,
+			Public:   *public,
+			Name:     *roomName,
+		})
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	p.Seed = g.Seed
+	v := &view{game: g, team: team, message: help}
+
+	lines := make(chan string)
+	go func() {
+		s := bufio.NewScanner(os.Stdin)
+		for s.Scan() {
+			lines <- s.Text()
+		}
+		close(lines)
+	}()
+
+	updates, stop := stream(ctx, *p)
+	restart := func() {
+		stop()
+		v.events = nil
+		updates, stop = stream(ctx, *p)
+	}
+
+	for {
+		v.render(os.Stdout)
+		select {
+		case u := <-updates:
+			if u.err != nil {
+				fmt.Fprintln(os.Stderr, u.err)
+				os.Exit(1)
+			}
+			if u.reset {
+				// Someone started a new game.
+				p.Seed = u.seed
+				if g, err := c.Game(ctx, g.ID); err == nil {
+					v.game = g
+				}
+				v.events = nil
+				v.message = "A new game has started."
+			}
+			v.add(u.event)
+
+		case line, ok := <-lines:
+			if !ok {
+				return
+			}
+			cmd, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+			arg = strings.TrimSpace(arg)
+			v.message = ""
+			switch cmd = strings.ToLower(cmd); {
+			case cmd == "":
+			case cmd == "quit" || cmd == "exit":
+				return
+			case cmd == "help":
+				v.message = help
+			case cmd == "a" || cmd == "b":
+				// Switch sides before restarting the stream
+				// in case the host has locked the teams.
+				moved := *p
+				moved.Team, _ = parseSide(cmd)
+				if err := moved.Ping(ctx); err != nil {
+					v.message = errMessage(err)
+					break
+				}
+				p.Team = moved.Team
+				v.team = p.Team
+				restart()
+			case cmd == "name" && arg != "":
+				p.Name = arg
+				restart()
+			case cmd == "end":
+				v.message = errMessage(p.EndTurn(ctx))
+			case cmd == "clue":
+				word, n, _ := strings.Cut(arg, " ")
+				number, err := strconv.Atoi(strings.TrimSpace(n))
+				switch {
+				case word == "" || err != nil:
+					v.message = `Give a clue as a word and a number, like "clue ocean 2".`
+				case p.Team == 0:
+					v.message = `Join a side with "a" or "b" before giving a clue.`
+				default:
+					v.message = errMessage(p.GiveClue(ctx, word, number))
+				}
+			case cmd == "bot":
+				side, ok := parseSide(arg)
+				if arg == "" && p.Team != 0 {
+					side, ok = 3-p.Team, true
+				}
+				if !ok || side == 0 {
+					v.message = `Add a bot to side "a" or "b".`
+					break
+				}
+				_, err := p.AddBot(ctx, side, "Bot")
+				v.message = errMessage(err)
+			case cmd == "say" && arg != "":
+				v.message = errMessage(p.Chat(ctx, arg))
+			case cmd == "side" && arg != "":
+				v.message = errMessage(p.ChatSide(ctx, arg))
+			case cmd == "new":
+				_, err := p.NextGame(ctx, gameapi.NewGameRequest{})
+				v.message = errMessage(err)
+			case cmd == "practice":
+				_, err := p.NextGame(ctx, gameapi.NewGameRequest{Practice: true})
+				v.message = errMessage(err)
+			case cmd == "score":
+				r, err := c.PracticeResults(ctx, p.GameID)
+				if err != nil {
+					v.message = errMessage(err)
+					break
+				}
+				v.message = fmt.Sprintf("Score %d: %d of %d greens found after %d clues.", r.Score, r.GreensFound, r.GreensTotal, len(r.Rounds))
+				if r.HitBlack {
+					v.message += " You hit the black card."
+				}
+			case cmd == "lock" || cmd == "unlock":
+				locked := cmd == "lock"
+				_, err := p.ChangeSettings(ctx, gameapi.SettingsChange{TeamsLocked: &locked})
+				v.message = errMessage(err)
+			case cmd == "stats":
+				s, err := c.PlayerStats(ctx, p.ID)
+				if err != nil {
+					v.message = errMessage(err)
+					break
+				}
+				v.message = fmt.Sprintf("%d games: %d won, %d lost. %.0f%% of your guesses were green. Current streak %d, longest %d.",
+					s.GamesPlayed, s.Wins, s.Losses, 100*s.GuessAccuracy, s.CurrentStreak, s.LongestStreak)
+			case cmd == "leaderboard":
+				lb, err := c.TeamLeaderboard(ctx, arg)
+				if err != nil {
+					v.message = errMessage(err)
+					break
+				}
+				top := []string{}
+				for _, s := range lb.Teams[:min(len(lb.Teams), 5)] {
+					top = append(top, fmt.Sprintf("%d. %s (%d)", s.Rank, s.Name, s.Score))
+				}
+				if len(top) == 0 {
+					top = append(top, "no teams yet")
+				}
+				v.message = lb.Season + ": " + strings.Join(top, ", ")
+			case cmd == "invite":
+				invite, err := p.Invite(ctx, 0)
+				if err != nil {
+					v.message = errMessage(err)
+					break
+				}
+				v.message = fmt.Sprintf("Invite, valid until %s: greencli -invite %s %s",
+					invite.ExpiresAt.Local().Format(time.Kitchen), invite.Token, p.GameID)
+			case (cmd == "kick" || cmd == "host") && arg != "":
+				id, ok := v.playerID(arg)
+				switch {
+				case !ok:
+					v.message = fmt.Sprintf("No one named %q has joined a side.", arg)
+				case cmd == "kick":
+					v.message = errMessage(p.Kick(ctx, id))
+				default:
+					v.message = errMessage(p.MakeHost(ctx, id))
+				}
+			default:
+				i, ok := parseCoordinate(cmd)
+				switch {
+				case !ok:
+					v.message = fmt.Sprintf("Unknown command %q. %s", line, help)
+				case p.Team == 0:
+					v.message = `Join a side with "a" or "b" before guessing.`
+				default:
+					v.message = errMessage(p.Guess(ctx, i))
+				}
+			}
+		}
+	}
+}
+
+// stream streams the game's events on behalf of the player until
+// stop is called. The player is copied so that changes to their
+// name or side don't race with the stream's requests; the stream
+// must be restarted for them to take effect.
+func stream(ctx context.Context, p client.Player) (updates <-chan update, stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	ch := make(chan update)
+	send := func(u update) bool {
+		select {
+		case ch <- u:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	go func() {
+		s := p.Stream(ctx)
+		for s.Next() {
+			if !send(update{event: s.Event(), reset: s.Reset(), seed: p.Seed}) {
+				return
+			}
+		}
+		if ctx.Err() == nil {
+			send(update{err: s.Err()})
+		}
+	}()
+	return ch, cancel
+}
+
+// signIn signs in to the account, or creates it, with the
+// password in $GREENCLI_PASSWORD.
+func signIn(ctx context.Context, c *client.Client, user string, register bool) (gameapi.Account, error) {
+	password := os.Getenv("GREENCLI_PASSWORD")
+	if password == "" {
+		return gameapi.Account{}, errors.New("set $GREENCLI_PASSWORD to the account's password")
+	}
+	if register {
+		return c.Register(ctx, gameapi.RegisterRequest{Username: user, Password: password})
+	}
+	return c.Login(ctx, user, password)
+}
+
+// listLobby prints the public games.
+func listLobby(ctx context.Context, c *client.Client) error {
+	lobby, err := c.Lobby(ctx, "")
+	if err != nil {
+		return err
+	}
+	if len(lobby.Games) == 0 {
+		fmt.Println("No public games right now. Start one with -public.")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tMODE\tWORDS\tA\tB\t")
+	for _, g := range lobby.Games {
+		looking := ""
+		if g.LookingForPlayers {
+			looking = "looking for players"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%s\n", g.ID, g.Name, g.Mode, g.WordList, g.PlayersA, g.PlayersB, looking)
+	}
+	return w.Flush()
+}
+
+func parseSide(s string) (int, bool) {
+	switch strings.ToLower(s) {
+	case "a":
+		return 1, true
+	case "b":
+		return 2, true
+	default:
+		return 0, false
+	}
+}
+
+func errMessage(err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return ""
+}
@@ -0,0 +1,282 @@
+package gameapi
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// AccessHeader is the request header that carries a private
+// game's passcode, or an invite to the game.
+const AccessHeader = "X-Room-Access"
+
+// Invites expire after DefaultInviteTTL unless the request asks
+// for another lifetime, which may be at most MaxInviteTTL.
+const (
+	DefaultInviteTTL = 24 * time.Hour
+	MaxInviteTTL     = 7 * 24 * time.Hour
+)
+
+// maxPasscodeLength is the longest passcode, in bytes.
+const maxPasscodeLength = 100
+
+// WithInviteKey configures the key that signs invites to private
+// games. Instances that share games must share the key. Without
+// one, each handler generates a key of its own, so invites are
+// only accepted by the instance that issued them.
+func WithInviteKey(key []byte) Option {
+	return func(h *handler) {
+		h.inviteKey = key
+	}
+}
+
+var (
+	errAccessRequired = &apiError{"passcode_required",
+		"This game is private. Send its passcode or an invite in the " + AccessHeader + " header.", 403}
+	errAccessDenied = &apiError{"access_denied", "The passcode or invite isn't valid for this game.", 403}
+	errNotPrivate   = &apiError{"not_private", "The game doesn't have a passcode.", 400}
+	errLongPasscode = &apiError{"passcode_too_long",
+		"Passcodes may be at most " + strconv.Itoa(maxPasscodeLength) + " bytes.", 400}
+)
+
+// A passcode is a private game's passcode, hashed like account
+// passwords so that the hash published to the broker is slow to
+// test guesses against. An empty passcode in a command removes the
+// game's passcode.
+type passcode struct {
+	passwordHash
+
+	// known is the passcode, once a request has matched it, so
+	// that the game's later requests needn't hash it again.
+	known atomic.Pointer[string]
+}
+
+// randomKey returns a key for signing invites.
+func randomKey() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func newPasscode(code string) (*passcode, error) {
+	if len(code) > maxPasscodeLength {
+		return nil, errLongPasscode
+	}
+	h, err := newPasswordHash(code)
+	if err != nil {
+		return nil, err
+	}
+	return &passcode{passwordHash: h}, nil
+}
+
+func (p *passcode) matches(code string) bool {
+	if known := p.known.Load(); known != nil {
+		return subtle.ConstantTimeCompare([]byte(*known), []byte(code)) == 1
+	}
+	if !p.passwordHash.matches(code) {
+		return false
+	}
+	p.known.Store(&code)
+	return true
+}
+
+// checkAccess answers requests for private games that don't include
+// the game's passcode or a valid invite to it, returning true if it
+// has. Attempts are rate limited so that passcodes can't be guessed:
+// each takes from the IP's budget, and successful ones give it back.
+func (h *handler) checkAccess(rw http.ResponseWriter, req *http.Request) bool {
+	id := h.requestGameID(req)
+	if id == "" {
+		return false
+	}
+	g, ok := h.games.get(id)
+	if !ok {
+		return false
+	}
+	g.mu.Lock()
+	pc := g.passcode
+	g.mu.Unlock()
+	if pc == nil {
+		return false
+	}
+
+	access := req.Header.Get(AccessHeader)
+	if access == "" {
+		writeResult(rw, nil, errAccessRequired)
+		return true
+	}
+	now := h.clock.Now()
+	key, limit := "ip:access:"+h.clientIP(req), h.limits.PerIP["access"]
+	if wait := h.limiter.take(key, limit, now); wait > 0 {
+		writeRateLimited(rw, wait)
+		return true
+	}
+	if h.validInvite(id, pc, access, now) || pc.matches(access) {
+		h.limiter.giveBack(key, limit, now)
+		return false
+	}
+	writeResult(rw, nil, errAccessDenied)
+	return true
+}
+
+// requestGameID returns the ID of the game that the request is for:
+// the {id} in a game route's pattern or, for the original routes, the
+// game_id in its body. Admin routes have their own authorization, so
+// it returns "" for them.
+func (h *handler) requestGameID(req *http.Request) string {
+	path := h.routePath(req)
+	if path == "" || strings.HasPrefix(path, "/admin/") {
+		return ""
+	}
+	if strings.Contains(path, "games/{id}") {
+		return h.pathValue(req, "id")
+	}
+
+	if req.Body == nil || req.Body == http.NoBody {
+		return ""
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	var b struct {
+		GameID string `json:"game_id"`
+	}
+	if err != nil || json.Unmarshal(body, &b) != nil {
+		return ""
+	}
+	return b.GameID
+}
+
+// routePath returns the path of the pattern of the route that
+// the request matches, or "" if it doesn't match a route.
+func (h *handler) routePath(req *http.Request) string {
+	_, pattern := h.mux.Handler(req)
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		return path
+	}
+	return pattern
+}
+
+// pathValue returns the value of the named wildcard in the pattern
+// of the route that the request matches. Unlike req.PathValue, it
+// works before the request has been routed.
+func (h *handler) pathValue(req *http.Request, name string) string {
+	segs := strings.Split(req.URL.EscapedPath(), "/")
+	for i, seg := range strings.Split(h.routePath(req), "/") {
+		if seg == "{"+name+"}" && i < len(segs) {
+			v, err := url.PathUnescape(segs[i])
+			if err != nil {
+				return ""
+			}
+			return v
+		}
+	}
+	return ""
+}
+
+// POST /v2/games/{id}/invites
+// Issues an invite to a private game. Only the game's host may
+// invite players. Invites stop working when they expire or the
+// game's passcode changes.
+func (h *handler) handleInvite(rw http.ResponseWriter, req *http.Request) {
+	var body InviteRequest
+	err := decodePlayerRequest(req, &body, &body.PlayerRequest)
+	if err != nil || body.GameID == "" || body.PlayerID == "" || body.TTLSeconds < 0 {
+		writeError(rw, "malformed_body", "Unable to parse request body.", 400)
+		return
+	}
+	ttl := DefaultInviteTTL
+	if body.TTLSeconds > 0 {
+		ttl = time.Duration(body.TTLSeconds) * time.Second
+	}
+	if ttl > MaxInviteTTL {
+		ttl = MaxInviteTTL
+	}
+
+	g, ok := h.games.get(body.GameID)
+	if !ok {
+		writeResult(rw, nil, errNotFound)
+		return
+	}
+	key, session := playerKey(req.Context(), body.PlayerID)
+	g.mu.Lock()
+	host, pc := g.isHost(body.PlayerID, key, session), g.passcode
+	g.mu.Unlock()
+	switch {
+	case !host:
+		writeResult(rw, nil, errNotHost)
+	case pc == nil:
+		writeResult(rw, nil, errNotPrivate)
+	default:
+		expires := h.clock.Now().Add(ttl).Truncate(time.Second)
+		writeCreated(rw, InviteResponse{
+			Token:     h.invite(body.GameID, pc, expires),
+			ExpiresAt: expires,
+		}, nil)
+	}
+}
+
+// invite returns a token that grants access to the game until it
+// expires. Tokens are signed along with the passcode's salt, so
+// changing the passcode revokes them.
+func (h *handler) invite(gameID string, pc *passcode, expires time.Time) string {
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	return exp + "." + base64.RawURLEncoding.EncodeToString(h.inviteMAC(gameID, pc, exp))
+}
+
+func (h *handler) inviteMAC(gameID string, pc *passcode, exp string) []byte {
+	m := hmac.New(sha256.New, h.inviteKey)
+	m.Write([]byte(gameID))
+	m.Write([]byte{0})
+	m.Write([]byte(exp))
+	m.Write([]byte{0})
+	m.Write(pc.Salt)
+	return m.Sum(nil)
+}
+
+// validInvite returns true if token is an unexpired invite to the game.
+func (h *handler) validInvite(gameID string, pc *passcode, token string, now time.Time) bool {
+	exp, mac, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	unix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || !now.Before(time.Unix(unix, 0)) {
+		return false
+	}
+	got, err := base64.RawURLEncoding.DecodeString(mac)
+	return err == nil && hmac.Equal(got, h.inviteMAC(gameID, pc, exp))
+}
+
+// setPasscode sets or, if pc is empty, removes the game's passcode
+// on behalf of the host. The passcode itself isn't recorded.
+func (g *Game) setPasscode(hostName string, pc *passcode) {
+	typ := "passcode_changed"
+	if len(pc.Hash) == 0 {
+		typ = "passcode_removed"
+		if g.passcode == nil {
+			return
+		}
+		pc = nil
+	}
+	g.passcode = pc
+	g.Settings.Private = pc != nil
+	g.addEvent(Event{
+		Type:     typ,
+		PlayerID: g.Host,
+		Name:     hostName,
+		Team:     g.players[g.Host].Team,
+	})
+}
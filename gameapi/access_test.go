@@ -0,0 +1,122 @@
+package gameapi
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func withAccess(h http.Handler, access string) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		req.Header.Set(AccessHeader, access)
+		h.ServeHTTP(rw, req)
+	})
+}
+
+func TestPrivateGame(t *testing.T) {
+	defer func(c argon2Cost) { passwordCost = c }(passwordCost)
+	passwordCost = argon2Cost{Time: 1, Memory: 8, Threads: 1}
+
+	clock := NewFakeClock(time.Now())
+	h := newTestHandler(clock)
+	h.adminToken = "secret"
+	ctx := context.Background()
+
+	var game struct {
+		State GameState `json:"state"`
+	}
+	body := map[string]interface{}{"game_id": "private", "player_id": "alice", "passcode": "hunter2"}
+	if code := request(ctx, t, keyed(h, "alice"), "POST", "/v2/games", body, &game); code != 201 {
+		t.Fatalf("POST /v2/games: status = %d, want 201", code)
+	}
+	if !game.State.Settings.Private {
+		t.Errorf("settings = %+v, want a private game", game.State.Settings)
+	}
+	seed := game.State.Seed
+	alice := map[string]interface{}{"seed": seed, "player_id": "alice", "name": "alice", "team": 1}
+
+	for _, r := range []struct {
+		h    http.Handler
+		want int
+		what string
+	}{
+		{h, 403, "without the passcode"},
+		{withAccess(h, "hunter3"), 403, "with the wrong passcode"},
+		{withAccess(h, "hunter2"), 200, "with the passcode"},
+	} {
+		if code := request(ctx, t, r.h, "GET", "/v2/games/private", nil, nil); code != r.want {
+			t.Errorf("GET %s: status = %d, want %d", r.what, code, r.want)
+		}
+	}
+	chat := map[string]interface{}{"game_id": "private", "seed": seed, "player_id": "alice", "message": "hi"}
+	if code := post(t, h, "/chat", chat, nil); code != 403 {
+		t.Errorf("POST /chat without the passcode: status = %d, want 403", code)
+	}
+	if code := request(ctx, t, withToken(h, "secret"), "GET", "/admin/games/private", nil, nil); code != 200 {
+		t.Errorf("GET /admin/games/private: status = %d, want 200", code)
+	}
+
+	// Only the host may invite players, and invites stop working
+	// when the passcode changes.
+	member := withAccess(keyed(h, "alice"), "hunter2")
+	request(ctx, t, member, "PUT", "/v2/games/private/players/alice", alice, nil)
+	bob := map[string]interface{}{"seed": seed, "player_id": "bob", "name": "bob", "team": 2}
+	if code := request(ctx, t, member, "POST", "/v2/games/private/invites", bob, nil); code != 403 {
+		t.Errorf("invite by a guest: status = %d, want 403", code)
+	}
+	var invite InviteResponse
+	if code := request(ctx, t, member, "POST", "/v2/games/private/invites", alice, &invite); code != 201 {
+		t.Fatalf("invite by the host: status = %d, want 201", code)
+	}
+	if code := request(ctx, t, withAccess(h, invite.Token), "PUT", "/v2/games/private/players/bob", bob, nil); code != 200 {
+		t.Errorf("joining with an invite: status = %d, want 200", code)
+	}
+	change := map[string]interface{}{"seed": seed, "player_id": "alice", "passcode": "swordfish"}
+	if code := request(ctx, t, member, "PATCH", "/v2/games/private/settings", change, nil); code != 200 {
+		t.Fatalf("changing the passcode: status = %d, want 200", code)
+	}
+	if code := request(ctx, t, withAccess(h, invite.Token), "GET", "/v2/games/private", nil, nil); code != 403 {
+		t.Errorf("GET with a revoked invite: status = %d, want 403", code)
+	}
+
+	member = withAccess(keyed(h, "alice"), "swordfish")
+	alice["ttl_seconds"] = 60
+	if code := request(ctx, t, member, "POST", "/v2/games/private/invites", alice, &invite); code != 201 {
+		t.Fatalf("invite by the host: status = %d, want 201", code)
+	}
+	if code := request(ctx, t, withAccess(h, invite.Token), "GET", "/v2/games/private", nil, nil); code != 200 {
+		t.Errorf("GET with an invite: status = %d, want 200", code)
+	}
+	clock.Advance(2 * time.Minute)
+	if code := request(ctx, t, withAccess(h, invite.Token), "GET", "/v2/games/private", nil, nil); code != 403 {
+		t.Errorf("GET with an expired invite: status = %d, want 403", code)
+	}
+
+	// Passcodes can't be guessed quickly.
+	for i := 0; ; i++ {
+		code := request(ctx, t, withAccess(h, "guess"), "GET", "/v2/games/private", nil, nil)
+		if code == 429 {
+			break
+		}
+		if code != 403 || i == 20 {
+			t.Fatalf("guess %d: status = %d, want 403 until 429", i, code)
+		}
+	}
+	if code := request(ctx, t, member, "GET", "/v2/games/private", nil, nil); code != 429 {
+		t.Errorf("GET with the passcode while limited: status = %d, want 429", code)
+	}
+	if code := request(ctx, t, withAccess(h, invite.Token), "GET", "/v2/games/private", nil, nil); code != 429 {
+		t.Errorf("GET with an invite while limited: status = %d, want 429", code)
+	}
+	clock.Advance(time.Minute)
+
+	// Removing the passcode makes the game public.
+	change["passcode"] = ""
+	if code := request(ctx, t, member, "PATCH", "/v2/games/private/settings", change, nil); code != 200 {
+		t.Fatalf("removing the passcode: status = %d, want 200", code)
+	}
+	if code := request(ctx, t, h, "GET", "/v2/games/private", nil, nil); code != 200 {
+		t.Errorf("GET a public game: status = %d, want 200", code)
+	}
+}
@@ -0,0 +1,274 @@
+package gameapi
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// compactAfter is how many records may be appended to an AccountDB's
+// file, beyond as many as it had when it was last compacted, before
+// it's compacted again. Tests lower it.
+var compactAfter = 1000
+
+// AccountDB stores player accounts and their sessions in a local
+// file. The file is a log of JSON records, one per line, that's
+// replayed when it's opened and then compacted. Each change is
+// appended and synced before it takes effect, and the file is
+// compacted again once enough changes have been appended, so that
+// signing in and out doesn't grow it without bound.
+//
+// An AccountDB is local to the instance that opens it, so players
+// are only recognized by that instance. Only one instance at a time
+// may open the file.
+type AccountDB struct {
+	mu       sync.Mutex
+	path     string
+	f        *os.File
+	accounts map[string]*accountRecord // by username
+	players  map[string]*accountRecord // by player ID
+	sessions map[string]sessionRecord  // by the token's hash
+
+	kept     int // records in the file when it was last compacted
+	appended int // records appended since then
+}
+
+// accountRecord is an account as it's stored, with its password.
+type accountRecord struct {
+	Account
+	Password passwordHash `json:"password"`
+}
+
+// sessionRecord is a session as it's stored. Only the hash of
+// its token is kept, so a copy of the file can't be used to
+// sign in.
+type sessionRecord struct {
+	Hash      string    `json:"hash"`
+	Username  string    `json:"username"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// dbRecord is a line of the file.
+type dbRecord struct {
+	Account      *accountRecord `json:"account,omitempty"`
+	Session      *sessionRecord `json:"session,omitempty"`
+	EndedSession string         `json:"ended_session,omitempty"`
+}
+
+var errUsernameTaken = &apiError{"username_taken", "That username is taken.", 409}
+
+// OpenAccountDB opens the database at path, creating it if it
+// doesn't exist.
+func OpenAccountDB(path string) (*AccountDB, error) {
+	db := &AccountDB{
+		path:     path,
+		accounts: make(map[string]*accountRecord),
+		players:  make(map[string]*accountRecord),
+		sessions: make(map[string]sessionRecord),
+	}
+	err := replayLog(path, func(line []byte) error {
+		var r dbRecord
+		if err := json.Unmarshal(line, &r); err != nil {
+			return err
+		}
+		db.apply(r)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("gameapi: reading %s: %w", path, err)
+	}
+	if err := db.compact(time.Now()); err != nil {
+		return nil, fmt.Errorf("gameapi: compacting %s: %w", path, err)
+	}
+	return db, nil
+}
+
+// Close closes the database's file.
+func (db *AccountDB) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.f.Close()
+}
+
+// apply applies a record to the database's maps.
+func (db *AccountDB) apply(r dbRecord) {
+	if a := r.Account; a != nil {
+		db.accounts[a.Username] = a
+		db.players[a.PlayerID] = a
+	}
+	if s := r.Session; s != nil {
+		db.sessions[s.Hash] = *s
+	}
+	if r.EndedSession != "" {
+		delete(db.sessions, r.EndedSession)
+	}
+}
+
+// compact rewrites the file with just the accounts and the
+// sessions that haven't expired, and opens it for appending.
+// The caller must hold db.mu, if others may use it.
+func (db *AccountDB) compact(now time.Time) error {
+	tmp := db.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, a := range db.accounts {
+		if err := enc.Encode(dbRecord{Account: a}); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	for hash, s := range db.sessions {
+		if !now.Before(s.ExpiresAt) {
+			delete(db.sessions, hash)
+			continue
+		}
+		if err := enc.Encode(dbRecord{Session: &s}); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, db.path); err != nil {
+		return err
+	}
+	if f, err = os.OpenFile(db.path, os.O_APPEND|os.O_WRONLY, 0600); err != nil {
+		return err
+	}
+	if db.f != nil {
+		db.f.Close()
+	}
+	db.f = f
+	db.kept, db.appended = len(db.accounts)+len(db.sessions), 0
+	return nil
+}
+
+// write appends a record to the file and then applies it.
+// The caller must hold db.mu.
+func (db *AccountDB) write(r dbRecord) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := db.f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	if err := db.f.Sync(); err != nil {
+		return err
+	}
+	db.apply(r)
+
+	// The change has been made, so failing to compact
+	// the file only leaves it longer than it needs to be.
+	if db.appended++; db.appended > max(db.kept, compactAfter) {
+		if err := db.compact(time.Now()); err != nil {
+			log.Printf("gameapi: compacting %s: %s", db.path, err)
+			db.appended = 0 // try again after as many more
+		}
+	}
+	return nil
+}
+
+// create adds an account, unless its username is taken.
+func (db *AccountDB) create(a *accountRecord) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if _, ok := db.accounts[a.Username]; ok {
+		return errUsernameTaken
+	}
+	return db.write(dbRecord{Account: a})
+}
+
+// update replaces an account, returning the new account.
+func (db *AccountDB) update(username string, fn func(*accountRecord)) (Account, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	a, ok := db.accounts[username]
+	if !ok {
+		return Account{}, errInvalidSession
+	}
+	updated := *a
+	fn(&updated)
+	if err := db.write(dbRecord{Account: &updated}); err != nil {
+		return Account{}, err
+	}
+	return updated.Account, nil
+}
+
+// account returns the account with the username.
+func (db *AccountDB) account(username string) (accountRecord, bool) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	a, ok := db.accounts[strings.ToLower(username)]
+	if !ok {
+		return accountRecord{}, false
+	}
+	return *a, true
+}
+
+// owned returns true if the player ID belongs to an account.
+func (db *AccountDB) owned(playerID string) bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	_, ok := db.players[playerID]
+	return ok
+}
+
+// startSession records a new session for the account.
+func (db *AccountDB) startSession(username, token string, expires time.Time) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.write(dbRecord{Session: &sessionRecord{
+		Hash:      tokenHash(token),
+		Username:  username,
+		ExpiresAt: expires,
+	}})
+}
+
+// session returns the account signed in to the session, if it
+// hasn't ended or expired.
+func (db *AccountDB) session(token string, now time.Time) (Account, bool) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	s, ok := db.sessions[tokenHash(token)]
+	if !ok || !now.Before(s.ExpiresAt) {
+		return Account{}, false
+	}
+	a, ok := db.accounts[s.Username]
+	if !ok {
+		return Account{}, false
+	}
+	return a.Account, true
+}
+
+// endSession ends the session.
+func (db *AccountDB) endSession(token string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.write(dbRecord{EndedSession: tokenHash(token)})
+}
+
+func tokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,345 @@
+package gameapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// SessionTTL is how long players stay signed in.
+const SessionTTL = 30 * 24 * time.Hour
+
+// Passwords must be between minPasswordLength and
+// maxPasswordLength bytes long.
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 200
+)
+
+// passwordCost is the cost that new passwords are hashed with:
+// the second of the argon2id settings that RFC 9106 recommends.
+// Tests lower it to keep them fast.
+var passwordCost = argon2Cost{Time: 3, Memory: 64 * 1024, Threads: 4}
+
+// usernamePattern matches valid usernames, which are lowercased
+// before they're checked.
+var usernamePattern = regexp.MustCompile(`^[a-z0-9_-]{3,32}$`)
+
+// WithAccounts lets players create accounts, stored in db, and sign
+// in to them. A signed-in player always plays as their account's
+// player ID and display name, and no one else may play as them.
+// Without accounts, the account routes respond as if they don't
+// exist and every player is anonymous.
+func WithAccounts(db *AccountDB) Option {
+	return func(h *handler) {
+		h.accounts = db
+	}
+}
+
+var (
+	errInvalidLogin    = &apiError{"invalid_login", "The username or password is wrong.", 401}
+	errInvalidSession  = &apiError{"invalid_session", "The session has expired or ended. Sign in again.", 401}
+	errSessionRequired = &apiError{"session_required", "That player belongs to an account. Sign in to play as them.", 401}
+	errNotYourPlayer   = &apiError{"not_your_player", "You may only act as your account's player.", 403}
+	errBadUsername     = &apiError{"invalid_username",
+		"Usernames must be 3 to 32 letters, digits, dashes or underscores.", 400}
+	errBadPassword = &apiError{"invalid_password",
+		"Passwords must be " + strconv.Itoa(minPasswordLength) + " to " + strconv.Itoa(maxPasswordLength) + " bytes.", 400}
+)
+
+// argon2Cost is how much work hashing a password with argon2id takes.
+type argon2Cost struct {
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory"` // in KiB
+	Threads uint8  `json:"threads"`
+}
+
+// A passwordHash is a password, salted and hashed with argon2id.
+// The cost is kept with each hash so that it can be raised without
+// invalidating existing passwords.
+type passwordHash struct {
+	Salt []byte     `json:"salt"`
+	Cost argon2Cost `json:"cost"`
+	Hash []byte     `json:"hash"`
+}
+
+func newPasswordHash(password string) (passwordHash, error) {
+	p := passwordHash{Salt: make([]byte, 16), Cost: passwordCost}
+	if _, err := rand.Read(p.Salt); err != nil {
+		return p, err
+	}
+	p.Hash = p.hash(password)
+	return p, nil
+}
+
+func (p passwordHash) hash(password string) []byte {
+	return argon2.IDKey([]byte(password), p.Salt, p.Cost.Time, p.Cost.Memory, p.Cost.Threads, 32)
+}
+
+func (p passwordHash) matches(password string) bool {
+	// argon2 panics without at least a pass and a thread.
+	if p.Cost.Time == 0 || p.Cost.Threads == 0 {
+		return false
+	}
+	return subtle.ConstantTimeCompare(p.hash(password), p.Hash) == 1
+}
+
+// accountKey is the context key of the signed-in player's account.
+type accountKey struct{}
+
+// requestAccount returns the account of the player who made the
+// request, if they're signed in.
+func requestAccount(req *http.Request) (Account, bool) {
+	a, ok := req.Context().Value(accountKey{}).(Account)
+	return a, ok
+}
+
+// authenticate recognizes the player's session, if the request has
+// one, and makes the request on behalf of their account: the body's
+// player_id and the query's player_id and name are replaced with the
+// account's. Anonymous requests may not use an account's player ID.
+// It returns the request with the account in its context, and true
+// if the request has been answered because it isn't allowed.
+func (h *handler) authenticate(rw http.ResponseWriter, req *http.Request) (*http.Request, bool) {
+	// Admin routes have their own tokens.
+	if path := h.routePath(req); h.accounts == nil || path == "" || strings.HasPrefix(path, "/admin/") {
+		return req, false
+	}
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		body, _ = io.ReadAll(req.Body)
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		var b struct {
+			PlayerID string `json:"player_id"`
+		}
+		json.Unmarshal(body, &b)
+		for _, id := range []string{b.PlayerID, req.URL.Query().Get("player_id"), h.pathValue(req, "player_id")} {
+			if id != "" && h.accounts.owned(id) {
+				rw.Header().Set("WWW-Authenticate", `Bearer realm="players"`)
+				writeResult(rw, nil, errSessionRequired)
+				return req, true
+			}
+		}
+		return req, false
+	}
+
+	a, ok := h.accounts.session(token, h.clock.Now())
+	if !ok {
+		rw.Header().Set("WWW-Authenticate", `Bearer realm="players"`)
+		writeResult(rw, nil, errInvalidSession)
+		return req, true
+	}
+	if id := h.pathValue(req, "player_id"); id != "" && id != a.PlayerID {
+		writeResult(rw, nil, errNotYourPlayer)
+		return req, true
+	}
+
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(body, &fields) == nil && fields != nil {
+		fields["player_id"], _ = json.Marshal(a.PlayerID)
+		body, _ = json.Marshal(fields)
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+	}
+	if q := req.URL.Query(); q.Has("player_id") {
+		q.Set("player_id", a.PlayerID)
+		q.Set("name", a.DisplayName)
+		req.URL.RawQuery = q.Encode()
+	}
+	return req.WithContext(context.WithValue(req.Context(), accountKey{}, a)), false
+}
+
+func (h *handler) registerAccounts() {
+	h.handle("POST /accounts", h.accountRoute(h.handleRegister))
+	h.handle("POST /login", h.accountRoute(h.handleLogin))
+	h.handle("POST /logout", h.accountRoute(h.handleLogout))
+	h.handle("GET /accounts/me", h.accountRoute(h.handleGetAccount))
+	h.handle("PATCH /accounts/me", h.accountRoute(h.handleUpdateAccount))
+}
+
+// accountRoute wraps a handler for an account route, which
+// doesn't exist unless the handler has accounts.
+func (h *handler) accountRoute(fn http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		if h.accounts == nil {
+			writeError(rw, "not_found", "No such route.", 404)
+			return
+		}
+		fn(rw, req)
+	}
+}
+
+// POST /accounts
+// Creates an account and signs in to it. The account gets a player
+// ID of its own, and its display name defaults to its username.
+func (h *handler) handleRegister(rw http.ResponseWriter, req *http.Request) {
+	var body RegisterRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(rw, "malformed_body", "Unable to parse request body.", 400)
+		return
+	}
+	username := strings.ToLower(body.Username)
+	if !usernamePattern.MatchString(username) {
+		writeResult(rw, nil, errBadUsername)
+		return
+	}
+	if len(body.Password) < minPasswordLength || len(body.Password) > maxPasswordLength {
+		writeResult(rw, nil, errBadPassword)
+		return
+	}
+	name := body.DisplayName
+	if name == "" {
+		name = username
+	}
+	name, err := h.moderateName(name)
+	if err != nil {
+		writeResult(rw, nil, err)
+		return
+	}
+	password, err := newPasswordHash(body.Password)
+	if err != nil {
+		writeResult(rw, nil, err)
+		return
+	}
+	a := &accountRecord{
+		Account: Account{
+			Username:    username,
+			PlayerID:    "account-" + randomToken(12),
+			DisplayName: name,
+			CreatedAt:   h.clock.Now(),
+		},
+		Password: password,
+	}
+	if err := h.accounts.create(a); err != nil {
+		writeResult(rw, nil, err)
+		return
+	}
+	resp, err := h.startSession(a.Account)
+	writeCreated(rw, resp, err)
+}
+
+// POST /login
+// Signs in to an account, starting a new session.
+func (h *handler) handleLogin(rw http.ResponseWriter, req *http.Request) {
+	var body LoginRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(rw, "malformed_body", "Unable to parse request body.", 400)
+		return
+	}
+	a, ok := h.accounts.account(body.Username)
+	if !ok {
+		// Take as long as a wrong password would.
+		a.Password.Cost = passwordCost
+	}
+	if !a.Password.matches(body.Password) {
+		writeResult(rw, nil, errInvalidLogin)
+		return
+	}
+	resp, err := h.startSession(a.Account)
+	writeCreated(rw, resp, err)
+}
+
+// startSession starts a session for the account.
+func (h *handler) startSession(a Account) (SessionResponse, error) {
+	token := randomToken(32)
+	expires := h.clock.Now().Add(SessionTTL).Truncate(time.Second)
+	if err := h.accounts.startSession(a.Username, token, expires); err != nil {
+		return SessionResponse{}, err
+	}
+	return SessionResponse{Token: token, ExpiresAt: expires, Account: a}, nil
+}
+
+// POST /logout
+// Ends the request's session.
+func (h *handler) handleLogout(rw http.ResponseWriter, req *http.Request) {
+	if _, ok := requestAccount(req); !ok {
+		writeResult(rw, nil, errInvalidSession)
+		return
+	}
+	token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if err := h.accounts.endSession(token); err != nil {
+		writeResult(rw, nil, err)
+		return
+	}
+	writeJSON(rw, StatusResponse{Status: "ok"})
+}
+
+// GET /accounts/me
+func (h *handler) handleGetAccount(rw http.ResponseWriter, req *http.Request) {
+	a, ok := requestAccount(req)
+	if !ok {
+		writeResult(rw, nil, errInvalidSession)
+		return
+	}
+	writeJSON(rw, a)
+}
+
+// PATCH /accounts/me
+// Changes the signed-in player's display name or preferences. The
+// new name is used from the player's next request on.
+func (h *handler) handleUpdateAccount(rw http.ResponseWriter, req *http.Request) {
+	a, ok := requestAccount(req)
+	if !ok {
+		writeResult(rw, nil, errInvalidSession)
+		return
+	}
+	var body AccountChange
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil ||
+		(body.Preferences != nil && (body.Preferences.Side < 0 || body.Preferences.Side > 2 ||
+			len(body.Preferences.Language) > maxLanguageLength)) {
+		writeError(rw, "malformed_body", "Unable to parse request body.", 400)
+		return
+	}
+	var name string
+	if body.DisplayName != nil {
+		var err error
+		if name, err = h.moderateName(*body.DisplayName); err != nil {
+			writeResult(rw, nil, err)
+			return
+		}
+		if name == "" {
+			writeError(rw, "malformed_body", "The display name can't be empty.", 400)
+			return
+		}
+	}
+	if p := body.Preferences; p != nil {
+		if _, ok := h.wordLists[p.WordList]; p.WordList != "" && !ok {
+			writeError(rw, "unknown_word_list", "No word list named "+p.WordList+".", 400)
+			return
+		}
+		p.Language = strings.ToLower(strings.TrimSpace(p.Language))
+	}
+	updated, err := h.accounts.update(a.Username, func(r *accountRecord) {
+		if body.DisplayName != nil {
+			r.DisplayName = name
+		}
+		if body.Preferences != nil {
+			r.Preferences = *body.Preferences
+		}
+	})
+	writeResult(rw, updated, err)
+}
+
+// randomToken returns n random bytes, encoded for use in URLs.
+func randomToken(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
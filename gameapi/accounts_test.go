@@ -0,0 +1,181 @@
+package gameapi
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccounts(t *testing.T) {
+	defer func(c argon2Cost) { passwordCost = c }(passwordCost)
+	passwordCost = argon2Cost{Time: 1, Memory: 8, Threads: 1}
+
+	clock := NewFakeClock(time.Now())
+	h := newTestHandler(clock)
+	ctx := context.Background()
+	if code := post(t, h, "/accounts", map[string]interface{}{"username": "alice", "password": "correct horse"}, nil); code != 404 {
+		t.Errorf("POST /accounts without accounts: status = %d, want 404", code)
+	}
+
+	path := filepath.Join(t.TempDir(), "accounts")
+	db, err := OpenAccountDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.accounts = db
+
+	var alice SessionResponse
+	if code := post(t, h, "/accounts", map[string]interface{}{"username": "Alice", "password": "correct horse"}, &alice); code != 201 {
+		t.Fatalf("register alice: status = %d, want 201", code)
+	}
+	if alice.Account.Username != "alice" || alice.Account.DisplayName != "alice" || alice.Token == "" {
+		t.Errorf("alice's session = %+v, want a token for alice", alice)
+	}
+	for _, tc := range []struct {
+		username, password string
+		want               int
+	}{
+		{"ALICE", "another password", 409},
+		{"al", "another password", 400},
+		{"bob", "short", 400},
+	} {
+		body := map[string]interface{}{"username": tc.username, "password": tc.password}
+		if code := post(t, h, "/accounts", body, nil); code != tc.want {
+			t.Errorf("register %v: status = %d, want %d", body, code, tc.want)
+		}
+	}
+	if code := post(t, h, "/login", map[string]interface{}{"username": "alice", "password": "wrong horse"}, nil); code != 401 {
+		t.Errorf("login with the wrong password: status = %d, want 401", code)
+	}
+	var session SessionResponse
+	if code := post(t, h, "/login", map[string]interface{}{"username": "ALICE", "password": "correct horse"}, &session); code != 201 {
+		t.Fatalf("login: status = %d, want 201", code)
+	}
+	signedIn := withToken(h, session.Token)
+
+	var account Account
+	if code := request(ctx, t, signedIn, "PATCH", "/accounts/me",
+		map[string]interface{}{"display_name": "Alice W", "preferences": map[string]interface{}{"side": 2}}, &account); code != 200 {
+		t.Fatalf("PATCH /accounts/me: status = %d, want 200", code)
+	}
+	if account.DisplayName != "Alice W" || account.Preferences.Side != 2 {
+		t.Errorf("account = %+v, want Alice W preferring side B", account)
+	}
+
+	// Signed-in players play as their account, whatever the
+	// request says, and no one else may play as them.
+	seed := newTestGame(t, h, "game")
+	if code := post(t, signedIn, "/ping", map[string]interface{}{"game_id": "game", "seed": seed, "player_id": "mallory", "name": "Mallory", "team": 1}, nil); code != 200 {
+		t.Fatalf("ping as alice: status = %d, want 200", code)
+	}
+	g, _ := h.games.get("game")
+	g.mu.Lock()
+	p, ok := g.players[alice.Account.PlayerID]
+	_, mallory := g.players["mallory"]
+	g.mu.Unlock()
+	if !ok || p.Name != "Alice W" || mallory {
+		t.Errorf("players after alice's ping: alice = %+v, mallory joined = %t; want only Alice W", p, mallory)
+	}
+	if code := post(t, h, "/ping", map[string]interface{}{"game_id": "game", "seed": seed, "player_id": alice.Account.PlayerID, "team": 1}, nil); code != 401 {
+		t.Errorf("anonymous ping as alice's player: status = %d, want 401", code)
+	}
+	if code := request(ctx, t, signedIn, "PUT", "/v2/games/game/players/mallory", map[string]interface{}{"seed": seed, "team": 1}, nil); code != 403 {
+		t.Errorf("PUT another player as alice: status = %d, want 403", code)
+	}
+
+	// Accounts and sessions survive restarts.
+	db.Close()
+	if db, err = OpenAccountDB(path); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	h.accounts = db
+	if code := request(ctx, t, signedIn, "GET", "/accounts/me", nil, &account); code != 200 || account.DisplayName != "Alice W" {
+		t.Errorf("GET /accounts/me after reopening: status = %d, account = %+v; want Alice W", code, account)
+	}
+
+	if code := post(t, signedIn, "/logout", nil, nil); code != 200 {
+		t.Errorf("logout: status = %d, want 200", code)
+	}
+	if code := request(ctx, t, signedIn, "GET", "/accounts/me", nil, nil); code != 401 {
+		t.Errorf("GET /accounts/me after logging out: status = %d, want 401", code)
+	}
+	clock.Advance(SessionTTL)
+	if code := request(ctx, t, withToken(h, alice.Token), "GET", "/accounts/me", nil, nil); code != 401 {
+		t.Errorf("GET /accounts/me once the session has expired: status = %d, want 401", code)
+	}
+}
+
+func TestPasswordHash(t *testing.T) {
+	defer func(c argon2Cost) { passwordCost = c }(passwordCost)
+	passwordCost = argon2Cost{Time: 1, Memory: 8, Threads: 1}
+
+	p, err := newPasswordHash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.matches("correct horse") || p.matches("wrong horse") {
+		t.Errorf("hash matches the right password = %t, the wrong one = %t", p.matches("correct horse"), p.matches("wrong horse"))
+	}
+
+	// Hashes keep the cost they were made with.
+	passwordCost = argon2Cost{Time: 2, Memory: 16, Threads: 1}
+	if !p.matches("correct horse") {
+		t.Error("hash doesn't match after raising the cost")
+	}
+	if (passwordHash{}).matches("") {
+		t.Error("a hash without a cost matches")
+	}
+}
+
+func TestAccountDBCompaction(t *testing.T) {
+	defer func(n int) { compactAfter = n }(compactAfter)
+	compactAfter = 10
+
+	path := filepath.Join(t.TempDir(), "accounts")
+	db, err := OpenAccountDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { db.Close() }()
+	if err := db.create(&accountRecord{Account: Account{Username: "alice", PlayerID: "alice-id"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Signing in and out over and over, with sessions that have
+	// since expired, doesn't grow the file without bound.
+	now := time.Now()
+	for i := 0; i < 100; i++ {
+		token := "token-" + strconv.Itoa(i)
+		if err := db.startSession("alice", token, now.Add(-time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+		if i%2 == 0 {
+			if err := db.endSession(token); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := db.startSession("alice", "current", now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(b), "\n"); lines > 2+compactAfter {
+		t.Errorf("the file has %d lines, want at most %d", lines, 2+compactAfter)
+	}
+
+	db.Close()
+	if db, err = OpenAccountDB(path); err != nil {
+		t.Fatal(err)
+	}
+	if a, ok := db.session("current", now); !ok || a.Username != "alice" {
+		t.Errorf("the current session after compacting = %+v, %t; want alice's", a, ok)
+	}
+}
@@ -0,0 +1,225 @@
+package gameapi
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WithAdminToken enables the admin API, which lets moderators
+// inspect and intervene in running games. Requests to it must
+// include the token in an "Authorization: Bearer" header. Without
+// a token, the admin API responds as if it doesn't exist.
+func WithAdminToken(token string) Option {
+	return func(h *handler) {
+		h.adminToken = token
+	}
+}
+
+// The operations that admins may perform.
+const (
+	opKick      = "kick"
+	opPurgeChat = "purge_chat"
+	opEndGame   = "end_game"
+	opDelete    = "delete_game"
+)
+
+var (
+	errKicked         = &apiError{"kicked", "You've been removed from this game.", 403}
+	errGameFinished   = &apiError{"game_finished", "The game is already over.", 409}
+	errPlayerNotFound = &apiError{"player_not_found", "The player isn't in the game.", 404}
+)
+
+func (h *handler) registerAdmin() {
+	h.handle("GET /admin/games", h.admin(h.handleAdminGames))
+	h.handle("GET /admin/games/{id}", h.admin(h.handleAdminGame))
+	h.handle("DELETE /admin/games/{id}", h.admin(h.handleAdminDelete))
+	h.handle("POST /admin/games/{id}/end", h.admin(h.handleAdminEnd))
+	h.handle("DELETE /admin/games/{id}/players/{player_id}", h.admin(h.handleAdminKick))
+	h.handle("DELETE /admin/games/{id}/messages", h.admin(h.handleAdminPurgeChat))
+	h.handle("DELETE /admin/teams/{id}", h.admin(h.handleAdminDeleteTeam))
+}
+
+// admin wraps a handler for an admin route, rejecting
+// requests that don't include the admin token.
+func (h *handler) admin(fn http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		if h.adminToken == "" {
+			writeError(rw, "not_found", "No such route.", 404)
+			return
+		}
+		token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) != 1 {
+			rw.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			writeError(rw, "unauthorized", "A valid admin token is required.", 401)
+			return
+		}
+		fn(rw, req)
+	}
+}
+
+// GET /admin/games
+// Lists the games held in memory, ordered by ID.
+func (h *handler) handleAdminGames(rw http.ResponseWriter, req *http.Request) {
+	now := h.clock.Now()
+	resp := AdminGamesResponse{Games: []AdminGameSummary{}}
+	h.games.each(func(id string, g *Game) {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		summary := AdminGameSummary{
+			ID:         id,
+			Seed:       g.Seed,
+			CreatedAt:  g.CreatedAt,
+			AgeSeconds: int64(now.Sub(g.CreatedAt) / time.Second),
+			Events:     len(g.Events),
+			Practice:   len(g.Practice) > 0,
+			Private:    g.passcode != nil,
+			Finished:   g.status().Finished(),
+		}
+		for _, p := range g.players {
+			summary.Players++
+			if p.Bot {
+				summary.Bots++
+			}
+		}
+		resp.Games = append(resp.Games, summary)
+	})
+	sort.Slice(resp.Games, func(i, j int) bool { return resp.Games[i].ID < resp.Games[j].ID })
+	writeJSON(rw, resp)
+}
+
+// GET /admin/games/{id}
+// Reports everything about a game, including its players,
+// which aren't otherwise exposed.
+func (h *handler) handleAdminGame(rw http.ResponseWriter, req *http.Request) {
+	g, ok := h.games.get(req.PathValue("id"))
+	if !ok {
+		writeResult(rw, nil, errNotFound)
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	game, err := json.Marshal(g)
+	if err != nil {
+		writeResult(rw, nil, err)
+		return
+	}
+	resp := AdminGame{
+		Game:    game,
+		Status:  g.status(),
+		Players: make(map[string]Player, len(g.players)),
+		Kicked:  []string{},
+		Muted:   []string{},
+	}
+	for id, p := range g.players {
+		resp.Players[id] = p
+	}
+	for id := range g.kicked {
+		resp.Kicked = append(resp.Kicked, id)
+	}
+	for id, muted := range g.muted {
+		if muted {
+			resp.Muted = append(resp.Muted, id)
+		}
+	}
+	sort.Strings(resp.Kicked)
+	sort.Strings(resp.Muted)
+	writeJSON(rw, resp)
+}
+
+// DELETE /admin/games/{id}
+// Deletes a game. Its players are told that it no longer exists.
+func (h *handler) handleAdminDelete(rw http.ResponseWriter, req *http.Request) {
+	resp, err := h.exec(req.Context(), command{Op: opDelete, GameID: req.PathValue("id")})
+	writeResult(rw, resp, err)
+}
+
+// POST /admin/games/{id}/end
+// Ends a game that hasn't been won or lost.
+func (h *handler) handleAdminEnd(rw http.ResponseWriter, req *http.Request) {
+	h.execAdmin(rw, req, command{Op: opEndGame})
+}
+
+// DELETE /admin/games/{id}/players/{player_id}
+// Removes a player from a game. They can't rejoin it, or any game
+// that replaces it, until the game is deleted.
+func (h *handler) handleAdminKick(rw http.ResponseWriter, req *http.Request) {
+	h.execAdmin(rw, req, command{Op: opKick, PlayerID: req.PathValue("player_id")})
+}
+
+// DELETE /admin/games/{id}/messages?player_id=...
+// Removes a game's chat messages, or only those sent by a player.
+func (h *handler) handleAdminPurgeChat(rw http.ResponseWriter, req *http.Request) {
+	h.execAdmin(rw, req, command{Op: opPurgeChat, PlayerID: req.URL.Query().Get("player_id")})
+}
+
+// execAdmin executes an admin's command against the current
+// game with the ID in the request's path.
+func (h *handler) execAdmin(rw http.ResponseWriter, req *http.Request, cmd command) {
+	g, ok := h.games.get(req.PathValue("id"))
+	if !ok {
+		writeResult(rw, nil, errNotFound)
+		return
+	}
+	g.mu.Lock()
+	cmd.Seed = g.Seed
+	g.mu.Unlock()
+
+	cmd.GameID = req.PathValue("id")
+	cmd.Name = "Admin"
+	resp, err := h.exec(req.Context(), cmd)
+	writeResult(rw, resp, err)
+}
+
+// applyDelete removes a game from the registry.
+func (h *handler) applyDelete(cmd command) (interface{}, error) {
+	g, ok := h.games.remove(cmd.GameID)
+	if !ok {
+		return nil, errNotFound
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.stats.playersChanged(cmd.At, len(g.players), 0)
+	g.stats = nil
+	g.notifyAll()
+	return StatusResponse{Status: "ok"}, nil
+}
+
+// kick removes a player from the game and keeps them from rejoining.
+func (g *Game) kick(playerID, by string, when time.Time) error {
+	p, ok := g.players[playerID]
+	if !ok && !g.kicked[playerID] {
+		return errPlayerNotFound
+	}
+	g.kicked[playerID] = true
+	if !ok {
+		return nil
+	}
+	delete(g.players, playerID)
+	g.stats.playersChanged(when, len(g.players)+1, len(g.players))
+	g.addEvent(Event{
+		Type:     "player_kicked",
+		PlayerID: playerID,
+		Name:     p.Name,
+		Team:     p.Team,
+		Message:  by,
+	})
+	if g.Host == playerID {
+		g.passHost()
+	}
+	return nil
+}
+
+// end ends the game before it's won or lost.
+func (g *Game) end(by string, when time.Time) error {
+	if g.status().Finished() {
+		return errGameFinished
+	}
+	g.addEvent(Event{Type: "game_ended", Message: by})
+	g.checkFinished(when)
+	return nil
+}
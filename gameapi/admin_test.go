@@ -0,0 +1,148 @@
+package gameapi
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// withToken returns a handler that makes requests with the token.
+func withToken(h http.Handler, token string) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+token)
+		h.ServeHTTP(rw, req)
+	})
+}
+
+func TestAdminAuth(t *testing.T) {
+	h := newTestHandler(NewFakeClock(time.Now()))
+	ctx := context.Background()
+
+	if code := request(ctx, t, withToken(h, ""), "GET", "/admin/games", nil, nil); code != 404 {
+		t.Errorf("without an admin token configured: status = %d, want 404", code)
+	}
+	h.adminToken = "secret"
+	if code := request(ctx, t, h, "GET", "/admin/games", nil, nil); code != 401 {
+		t.Errorf("without a token: status = %d, want 401", code)
+	}
+	if code := request(ctx, t, withToken(h, "guess"), "GET", "/admin/games", nil, nil); code != 401 {
+		t.Errorf("with the wrong token: status = %d, want 401", code)
+	}
+	if code := request(ctx, t, withToken(h, "secret"), "GET", "/admin/games", nil, nil); code != 200 {
+		t.Errorf("with the token: status = %d, want 200", code)
+	}
+}
+
+func TestAdmin(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC))
+	h := newTestHandler(clock)
+	h.adminToken = "secret"
+	admin := withToken(h, "secret")
+	ctx := context.Background()
+
+	seed := newTestGame(t, h, "moderated")
+	newTestGame(t, h, "another")
+	player := func(id string) map[string]interface{} {
+		return map[string]interface{}{"game_id": "moderated", "seed": seed, "player_id": id, "name": id, "team": 1}
+	}
+	chat := func(id, msg string) {
+		body := player(id)
+		body["message"] = msg
+		if code := post(t, h, "/chat", body, nil); code != 200 {
+			t.Fatalf("%s's chat: status = %d, want 200", id, code)
+		}
+	}
+	chat("alice", "hi")
+	chat("mallory", "spam")
+	chat("mallory", "more spam")
+	clock.Advance(time.Minute)
+
+	var list AdminGamesResponse
+	if code := request(ctx, t, admin, "GET", "/admin/games", nil, &list); code != 200 {
+		t.Fatalf("GET /admin/games: status = %d, want 200", code)
+	}
+	if len(list.Games) != 2 || list.Games[1].ID != "moderated" {
+		t.Fatalf("games = %+v, want another and moderated", list.Games)
+	}
+	if got := list.Games[1]; got.Players != 2 || got.AgeSeconds != 60 || got.Events != 5 {
+		t.Errorf("moderated = %+v, want 2 players, 60 seconds old with 5 events", got)
+	}
+
+	// Kicked players can't rejoin.
+	if code := request(ctx, t, admin, "DELETE", "/admin/games/moderated/players/mallory", nil, nil); code != 200 {
+		t.Fatalf("kick: status = %d, want 200", code)
+	}
+	if code := request(ctx, t, admin, "DELETE", "/admin/games/moderated/players/nobody", nil, nil); code != 404 {
+		t.Errorf("kicking a stranger: status = %d, want 404", code)
+	}
+	if code := post(t, h, "/ping", player("mallory"), nil); code != 403 {
+		t.Errorf("kicked player's ping: status = %d, want 403", code)
+	}
+	if code := post(t, h, "/events", player("mallory"), nil); code != 403 {
+		t.Errorf("kicked player's events: status = %d, want 403", code)
+	}
+
+	// Remove mallory's messages but not alice's.
+	var purged AdminPurgeResponse
+	if code := request(ctx, t, admin, "DELETE", "/admin/games/moderated/messages?player_id=mallory", nil, &purged); code != 200 {
+		t.Fatalf("purge: status = %d, want 200", code)
+	}
+	if purged.Removed != 2 {
+		t.Errorf("removed %d messages, want 2", purged.Removed)
+	}
+
+	var game AdminGame
+	if code := request(ctx, t, admin, "GET", "/admin/games/moderated", nil, &game); code != 200 {
+		t.Fatalf("GET /admin/games/moderated: status = %d, want 200", code)
+	}
+	if _, ok := game.Players["alice"]; !ok || len(game.Players) != 1 {
+		t.Errorf("players = %v, want only alice", game.Players)
+	}
+	if len(game.Kicked) != 1 || game.Kicked[0] != "mallory" {
+		t.Errorf("kicked = %v, want mallory", game.Kicked)
+	}
+	g, _ := h.games.get("moderated")
+	var types []string
+	for _, e := range g.Events {
+		types = append(types, e.Type)
+		if e.Type == "chat" && e.PlayerID == "mallory" && e.Message != "" {
+			t.Errorf("removed message %q is still visible", e.Message)
+		}
+	}
+	want := []string{"join_side", "chat", "join_side", "chat", "chat", "player_kicked", "chat_removed", "chat_removed"}
+	if len(types) != len(want) {
+		t.Fatalf("events = %v, want %v", types, want)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("events = %v, want %v", types, want)
+		}
+	}
+
+	// Ended games can't be played any further.
+	if code := request(ctx, t, admin, "POST", "/admin/games/moderated/end", nil, nil); code != 200 {
+		t.Fatalf("end: status = %d, want 200", code)
+	}
+	if code := request(ctx, t, admin, "POST", "/admin/games/moderated/end", nil, nil); code != 409 {
+		t.Errorf("ending it again: status = %d, want 409", code)
+	}
+	if st := g.Status(); !st.Ended || !st.Finished() || st.Won {
+		t.Errorf("status = %+v, want ended", st)
+	}
+	guess := player("alice")
+	guess["index"] = 0
+	if code := post(t, h, "/guess", guess, nil); code != 409 {
+		t.Errorf("guess after the game ended: status = %d, want 409", code)
+	}
+
+	if code := request(ctx, t, admin, "DELETE", "/admin/games/moderated", nil, nil); code != 200 {
+		t.Fatalf("delete: status = %d, want 200", code)
+	}
+	if _, ok := h.games.get("moderated"); ok {
+		t.Error("game still exists after it was deleted")
+	}
+	if code := request(ctx, t, admin, "DELETE", "/admin/games/moderated", nil, nil); code != 404 {
+		t.Errorf("deleting it again: status = %d, want 404", code)
+	}
+}
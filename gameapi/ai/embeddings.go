@@ -0,0 +1,157 @@
+// Package ai implements computer players for Codenames Green
+// using word embeddings.
+package ai
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Embeddings maps words to vectors, such that words with similar
+// meanings have similar vectors. Vectors are normalized when they're
+// loaded so that the similarity of two words is the dot product of
+// their vectors.
+type Embeddings struct {
+	dim   int
+	words []string
+	vecs  [][]float32
+	index map[string]int
+}
+
+// LoadEmbeddingsFile loads embeddings from a file in the GloVe or
+// word2vec text format.
+func LoadEmbeddingsFile(path string) (*Embeddings, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadEmbeddings(f)
+}
+
+// LoadEmbeddings reads embeddings in the GloVe or word2vec text
+// format: one word per line, followed by the components of its
+// vector separated by spaces. The word2vec format's header line,
+// holding the number of words and dimensions, is skipped.
+//
+// Word order is preserved. Both formats conventionally list words
+// from most to least frequent, which the spymaster relies on when
+// choosing clue candidates.
+func LoadEmbeddings(r io.Reader) (*Embeddings, error) {
+	e := &Embeddings{index: make(map[string]int)}
+
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; s.Scan(); line++ {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 || (line == 1 && len(fields) == 2) {
+			continue
+		}
+		if e.dim == 0 {
+			e.dim = len(fields) - 1
+		}
+		if len(fields)-1 != e.dim {
+			return nil, fmt.Errorf("ai: line %d: got %d dimensions, want %d", line, len(fields)-1, e.dim)
+		}
+
+		word := strings.ToLower(fields[0])
+		if _, ok := e.index[word]; ok {
+			continue
+		}
+		vec := make([]float32, e.dim)
+		for i, f := range fields[1:] {
+			v, err := strconv.ParseFloat(f, 32)
+			if err != nil {
+				return nil, fmt.Errorf("ai: line %d: %w", line, err)
+			}
+			vec[i] = float32(v)
+		}
+		normalize(vec)
+		e.index[word] = len(e.words)
+		e.words = append(e.words, word)
+		e.vecs = append(e.vecs, vec)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if len(e.words) == 0 {
+		return nil, fmt.Errorf("ai: no embeddings found")
+	}
+	return e, nil
+}
+
+// Len returns the number of words with embeddings.
+func (e *Embeddings) Len() int {
+	return len(e.words)
+}
+
+// Vector returns the normalized vector for the provided word or
+// phrase. Phrases without their own vector, like "ICE CREAM", use
+// the average of their words' vectors.
+func (e *Embeddings) Vector(word string) ([]float32, bool) {
+	word = strings.ToLower(strings.TrimSpace(word))
+	if i, ok := e.index[word]; ok {
+		return e.vecs[i], true
+	}
+	if i, ok := e.index[strings.ReplaceAll(word, " ", "_")]; ok {
+		return e.vecs[i], true
+	}
+
+	parts := strings.Fields(word)
+	if len(parts) < 2 {
+		return nil, false
+	}
+	avg := make([]float32, e.dim)
+	for _, p := range parts {
+		i, ok := e.index[p]
+		if !ok {
+			return nil, false
+		}
+		for j, v := range e.vecs[i] {
+			avg[j] += v
+		}
+	}
+	normalize(avg)
+	return avg, true
+}
+
+// Similarity returns the cosine similarity of two words, and
+// false if either word doesn't have an embedding.
+func (e *Embeddings) Similarity(a, b string) (float64, bool) {
+	va, ok := e.Vector(a)
+	if !ok {
+		return 0, false
+	}
+	vb, ok := e.Vector(b)
+	if !ok {
+		return 0, false
+	}
+	return dot(va, vb), true
+}
+
+func dot(a, b []float32) float64 {
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return float64(sum)
+}
+
+func normalize(v []float32) {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range v {
+		v[i] /= norm
+	}
+}
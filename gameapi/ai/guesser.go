@@ -0,0 +1,42 @@
+package ai
+
+import (
+	"sort"
+
+	"github.com/jbowens/codenamesgreen/gameapi"
+)
+
+// Guesser ranks the hidden cards on a board by the similarity of
+// their words to a clue. It implements gameapi.Guesser.
+type Guesser struct {
+	emb *Embeddings
+}
+
+// NewGuesser constructs a Guesser using the provided embeddings.
+func NewGuesser(e *Embeddings) *Guesser {
+	return &Guesser{emb: e}
+}
+
+// RankGuesses returns the board's hidden cards ordered by their
+// similarity to the clue, most similar first. Cards whose words
+// don't have embeddings are never guessed.
+func (g *Guesser) RankGuesses(b gameapi.Board, clue string) []gameapi.Guess {
+	vec, ok := g.emb.Vector(clue)
+	if !ok {
+		return nil
+	}
+
+	var guesses []gameapi.Guess
+	for i, w := range b.Words {
+		if !b.Hidden[i] {
+			continue
+		}
+		if v, ok := g.emb.Vector(w); ok {
+			guesses = append(guesses, gameapi.Guess{Index: i, Confidence: dot(vec, v)})
+		}
+	}
+	sort.SliceStable(guesses, func(i, j int) bool {
+		return guesses[i].Confidence > guesses[j].Confidence
+	})
+	return guesses
+}
@@ -0,0 +1,29 @@
+package ai
+
+import (
+	"testing"
+
+	"github.com/jbowens/codenamesgreen/gameapi"
+)
+
+func TestRankGuesses(t *testing.T) {
+	g := NewGuesser(loadTestEmbeddings(t))
+	b := gameapi.Board{
+		Words:  []string{"CAR", "IRON", "APPLE", "ORANGE", "UNKNOWN"},
+		Hidden: []bool{true, true, false, true, true},
+	}
+
+	guesses := g.RankGuesses(b, "fruit")
+	var order []int
+	for _, guess := range guesses {
+		order = append(order, guess.Index)
+	}
+	// APPLE is already revealed, and UNKNOWN has no embedding.
+	if len(order) != 3 || order[0] != 3 {
+		t.Errorf("guess order = %v, want ORANGE (3) first of 3", order)
+	}
+
+	if guesses := g.RankGuesses(b, "zebra"); len(guesses) != 0 {
+		t.Errorf("guesses for an unknown clue = %+v, want none", guesses)
+	}
+}
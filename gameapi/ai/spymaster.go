@@ -0,0 +1,156 @@
+package ai
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/jbowens/codenamesgreen/gameapi"
+)
+
+// DefaultVocabulary is the number of most frequent words in
+// the embeddings that a Spymaster considers as clues.
+const DefaultVocabulary = 50000
+
+// DefaultBlackMargin is how much less similar to a clue than
+// any green card the black cards must be, by default.
+const DefaultBlackMargin = 0.1
+
+// Spymaster suggests clues using word embeddings. A clue is chosen
+// to be similar to as many of the side's hidden green cards as
+// possible, while being less similar to them than to any hidden
+// tan card, and much less similar to them than to any black card.
+//
+// Spymaster implements gameapi.Spymaster.
+type Spymaster struct {
+	// BlackMargin is how much less similar to a clue than any
+	// targeted green card the black cards must be.
+	BlackMargin float64
+
+	emb        *Embeddings
+	candidates []int
+}
+
+// NewSpymaster constructs a Spymaster that chooses clues from the
+// DefaultVocabulary most frequent words in the embeddings.
+func NewSpymaster(e *Embeddings) *Spymaster {
+	s := &Spymaster{BlackMargin: DefaultBlackMargin, emb: e}
+	for i, w := range e.words {
+		if i >= DefaultVocabulary {
+			break
+		}
+		if isClueWord(w) {
+			s.candidates = append(s.candidates, i)
+		}
+	}
+	return s
+}
+
+// isClueWord returns true for words made up only of
+// letters, excluding abbreviations and punctuation.
+func isClueWord(w string) bool {
+	if len(w) < 2 {
+		return false
+	}
+	for _, r := range w {
+		if r < 'a' || r > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+type card struct {
+	word  string
+	color gameapi.Color
+	vec   []float32
+}
+
+// SuggestClues returns up to max clues for the board, best first.
+func (s *Spymaster) SuggestClues(b gameapi.Board, max int) []gameapi.Clue {
+	var cards []card
+	for i, w := range b.Words {
+		if !b.Hidden[i] {
+			continue
+		}
+		vec, ok := s.emb.Vector(w)
+		if !ok {
+			continue // nothing we can say about this card
+		}
+		cards = append(cards, card{word: w, color: b.Key[i], vec: vec})
+	}
+
+	var clues []gameapi.Clue
+	for _, i := range s.candidates {
+		word := s.emb.words[i]
+		if !validClue(word, b.Words) {
+			continue
+		}
+		if clue, ok := s.evaluate(word, s.emb.vecs[i], cards); ok {
+			clues = append(clues, clue)
+		}
+	}
+
+	sort.Slice(clues, func(i, j int) bool {
+		if clues[i].Score != clues[j].Score {
+			return clues[i].Score > clues[j].Score
+		}
+		return clues[i].Word < clues[j].Word
+	})
+	if len(clues) > max {
+		clues = clues[:max]
+	}
+	return clues
+}
+
+// evaluate scores a clue against the hidden cards. The clue targets
+// every green card that's more similar to it than the threshold set
+// by the tan and black cards, and it scores higher the more targets
+// it has and the further they are above the threshold.
+func (s *Spymaster) evaluate(word string, vec []float32, cards []card) (gameapi.Clue, bool) {
+	threshold := math.Inf(-1)
+	type target struct {
+		word string
+		sim  float64
+	}
+	var greens []target
+	for _, c := range cards {
+		sim := dot(vec, c.vec)
+		switch c.color {
+		case gameapi.Green:
+			greens = append(greens, target{c.word, sim})
+		case gameapi.Black:
+			threshold = math.Max(threshold, sim+s.BlackMargin)
+		default:
+			threshold = math.Max(threshold, sim)
+		}
+	}
+
+	sort.Slice(greens, func(i, j int) bool { return greens[i].sim > greens[j].sim })
+	clue := gameapi.Clue{Word: word}
+	for _, g := range greens {
+		if g.sim > threshold {
+			clue.Targets = append(clue.Targets, g.word)
+			clue.Score += g.sim - math.Max(threshold, 0)
+		}
+	}
+	clue.Number = len(clue.Targets)
+	return clue, clue.Number > 0
+}
+
+// validClue returns false if the clue is one of the words on the
+// board, or shares a root with one of them.
+func validClue(clue string, words []string) bool {
+	for _, w := range words {
+		for _, part := range strings.Fields(strings.ToLower(w)) {
+			if clue == part {
+				return false
+			}
+			if len(part) >= 3 && len(clue) >= 3 &&
+				(strings.Contains(clue, part) || strings.Contains(part, clue)) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+package ai
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jbowens/codenamesgreen/gameapi"
+)
+
+const testVectors = `9 4
+apple 1 0 0 0
+orange 0.9 0.1 0 0
+fruit 1 0.05 0 0.1
+iron 0 1 0 0
+copper 0 0.9 0.1 0
+metal 0.05 1 0 0
+car 0 0 0 1
+juice 0.8 0 0 0.3
+ice 0 0 1 0.2
+`
+
+func loadTestEmbeddings(t *testing.T) *Embeddings {
+	t.Helper()
+	e, err := LoadEmbeddings(strings.NewReader(testVectors))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestLoadEmbeddings(t *testing.T) {
+	e := loadTestEmbeddings(t)
+	if e.Len() != 9 {
+		t.Errorf("Len() = %d, want 9", e.Len())
+	}
+	if sim, ok := e.Similarity("APPLE", "apple"); !ok || sim < 0.999 {
+		t.Errorf("Similarity(APPLE, apple) = %v, %t, want 1, true", sim, ok)
+	}
+	if _, ok := e.Vector("ICE CAR"); !ok {
+		t.Error("phrases should average their words' vectors")
+	}
+	if _, ok := e.Vector("ICE CREAM"); ok {
+		t.Error("phrases with unknown words shouldn't have vectors")
+	}
+	if _, err := LoadEmbeddings(strings.NewReader("a 1 2\nb 1\n")); err == nil {
+		t.Error("mismatched dimensions should be rejected")
+	}
+}
+
+func TestSuggestClues(t *testing.T) {
+	s := NewSpymaster(loadTestEmbeddings(t))
+	b := gameapi.Board{
+		Words:  []string{"APPLE", "ORANGE", "IRON", "COPPER", "CAR"},
+		Key:    []gameapi.Color{gameapi.Green, gameapi.Green, gameapi.Green, gameapi.Black, gameapi.Tan},
+		Hidden: []bool{true, true, true, true, true},
+	}
+
+	clues := s.SuggestClues(b, 3)
+	if len(clues) == 0 {
+		t.Fatal("no clues suggested")
+	}
+	if c := clues[0]; c.Word != "fruit" || c.Number != 2 || strings.Join(c.Targets, ",") != "APPLE,ORANGE" {
+		t.Errorf("best clue = %+v, want fruit for APPLE and ORANGE", c)
+	}
+	for _, c := range clues {
+		if c.Word == "metal" {
+			t.Errorf("suggested %+v, which is too close to the black COPPER", c)
+		}
+	}
+
+	// Once the apple and orange are revealed, fruit isn't a useful clue.
+	b.Hidden[0], b.Hidden[1] = false, false
+	for _, c := range s.SuggestClues(b, 3) {
+		if c.Word == "fruit" || c.Word == "juice" {
+			t.Errorf("suggested %+v for revealed cards", c)
+		}
+	}
+}
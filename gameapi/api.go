@@ -0,0 +1,354 @@
+package gameapi
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// This file holds the bodies of the API's requests and responses.
+// They're described by the OpenAPI document served at /openapi.json,
+// so changing them changes the document; see openapi.go.
+
+// PlayerRequest holds the fields common to requests made on behalf
+// of a player. Routes under /v2 take the game ID, and sometimes the
+// player ID, from the path instead.
+type PlayerRequest struct {
+	GameID   string `json:"game_id"`
+	Seed     Seed   `json:"seed"`
+	PlayerID string `json:"player_id"`
+	Name     string `json:"name"`
+	Team     int    `json:"team"`
+}
+
+// NewGameRequest is the body of requests that create games. Words
+// takes precedence over WordList, and if neither is set the game's
+// words are drawn from the word list chosen in the settings of the
+// game being replaced, or from all of the word lists.
+//
+// PlayerID identifies the player creating the game, who hosts it.
+// Once a game has a host, only the host may replace it.
+//
+// If Passcode is set, the game is private: requests for it must
+// send the passcode or an invite in the X-Room-Access header. A
+// private game's replacements are private too.
+//
+// If Public is set, the game is listed in the lobby under Name, or
+// its ID if Name is empty. Replacements keep the original game's
+// listing, and private games are never listed.
+type NewGameRequest struct {
+	GameID   string   `json:"game_id"`
+	PlayerID string   `json:"player_id,omitempty"`
+	Passcode string   `json:"passcode,omitempty"`
+	Public   bool     `json:"public,omitempty"`
+	Name     string   `json:"name,omitempty"`
+	Words    []string `json:"words,omitempty"`
+	WordList string   `json:"word_list,omitempty"`
+	Practice bool     `json:"practice,omitempty"`
+	PrevSeed *Seed    `json:"prev_seed,omitempty"` // a string because of js number precision
+}
+
+// GuessRequest is the body of requests that guess a card.
+type GuessRequest struct {
+	PlayerRequest
+	Index int `json:"index"`
+}
+
+// ChatRequest is the body of requests that send a chat message.
+// Scope is ScopeAll, the default, to send the message to everyone
+// or ScopeSide to send it to the player's side.
+type ChatRequest struct {
+	PlayerRequest
+	Message string `json:"message"`
+	Scope   string `json:"scope,omitempty"`
+}
+
+// ClueRequest is the body of requests that give a clue.
+type ClueRequest struct {
+	PlayerRequest
+	Word   string `json:"word"`
+	Number int    `json:"number"`
+}
+
+// MuteRequest is the body of requests in which a game's host mutes
+// or unmutes another player, optionally removing their messages.
+type MuteRequest struct {
+	PlayerRequest
+	TargetID       string `json:"target_id"`
+	Mute           bool   `json:"mute"`
+	RemoveMessages bool   `json:"remove_messages,omitempty"`
+}
+
+// TargetRequest is the body of requests in which a game's host
+// kicks another player or makes them the host.
+type TargetRequest struct {
+	PlayerRequest
+	TargetID string `json:"target_id"`
+}
+
+// SettingsRequest is the body of requests in which a game's
+// host changes its settings.
+type SettingsRequest struct {
+	PlayerRequest
+	SettingsChange
+}
+
+// SettingsChange holds the settings to change. Settings that
+// are nil are left as they are. An empty Passcode makes the
+// game public.
+type SettingsChange struct {
+	TeamsLocked *bool   `json:"teams_locked,omitempty"`
+	WordList    *string `json:"word_list,omitempty"`
+	Passcode    *string `json:"passcode,omitempty"`
+}
+
+// InviteRequest is the body of requests for invites to a private
+// game. If TTLSeconds is zero, the invite lasts DefaultInviteTTL.
+type InviteRequest struct {
+	PlayerRequest
+	TTLSeconds int `json:"ttl_seconds,omitempty"`
+}
+
+// InviteResponse is the response to requests for invites.
+type InviteResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// EventsRequest is the body of requests that long poll for
+// a game's events.
+type EventsRequest struct {
+	PlayerRequest
+	LastEvent int `json:"last_event"`
+}
+
+// AddBotRequest is the body of requests that add a bot to a game.
+// PlayerID identifies the player adding the bot, who may only add
+// it to their own side while the teams are locked.
+type AddBotRequest struct {
+	Seed     Seed     `json:"seed"`
+	PlayerID string   `json:"player_id"`
+	Team     int      `json:"team"`
+	Name     string   `json:"name"`
+	Risk     *float64 `json:"risk,omitempty"`
+}
+
+// SuggestClueRequest is the body of requests for suggested clues.
+type SuggestClueRequest struct {
+	Seed  Seed `json:"seed"`
+	Team  int  `json:"team"`
+	Count int  `json:"count"`
+}
+
+// StatusResponse is the response to requests that change a game.
+type StatusResponse struct {
+	Status string `json:"status"`
+}
+
+// IndexResponse is the response to POST /index.
+type IndexResponse struct {
+	AutogeneratedID string `json:"autogenerated_id"`
+}
+
+// GameUpdate holds the events that happened in a game
+// since the last event a client saw.
+type GameUpdate struct {
+	Seed   Seed    `json:"seed"`
+	Events []Event `json:"events"`
+}
+
+// AddBotResponse is the response to requests that add a bot.
+type AddBotResponse struct {
+	PlayerID string `json:"player_id"`
+}
+
+// SuggestClueResponse is the response to requests for
+// suggested clues.
+type SuggestClueResponse struct {
+	Clues []Clue `json:"clues"`
+}
+
+// MatchmakingRequest is the body of requests to be paired with
+// another player. Players are only paired if their preferences
+// agree, and an empty preference agrees with any other. Language
+// is the language the player wants to chat in, like "en".
+type MatchmakingRequest struct {
+	PlayerID string `json:"player_id"`
+	Name     string `json:"name,omitempty"`
+	WordList string `json:"word_list,omitempty"`
+	Language string `json:"language,omitempty"`
+}
+
+// MatchmakingResponse is the response to matchmaking requests.
+// Status is "waiting" until the player has been paired, and then
+// "matched", when GameID and Team are the game and side to join.
+// The player who waited longer hosts the game and plays side A,
+// and their partner plays side B.
+//
+// Matched games are private, so the players send Invite in the
+// X-Room-Access header with their requests for the game.
+type MatchmakingResponse struct {
+	Status  string `json:"status"`
+	GameID  string `json:"game_id,omitempty"`
+	Team    int    `json:"team,omitempty"`
+	Partner string `json:"partner,omitempty"` // the other player's name
+	Invite  string `json:"invite,omitempty"`
+}
+
+// Account is a player's account. Its player ID is used by every
+// request made while signed in to it, along with its display name.
+// Username is lowercase.
+type Account struct {
+	Username    string      `json:"username"`
+	PlayerID    string      `json:"player_id"`
+	DisplayName string      `json:"display_name"`
+	Preferences Preferences `json:"preferences"`
+	CreatedAt   time.Time   `json:"created_at"`
+}
+
+// Preferences are the settings that a player with an account keeps
+// between games. Side is the side they'd like to join, 1 for A or 2
+// for B, or 0 if they don't mind. WordList and Language are their
+// defaults for matchmaking.
+type Preferences struct {
+	Side     int    `json:"side,omitempty"`
+	WordList string `json:"word_list,omitempty"`
+	Language string `json:"language,omitempty"`
+}
+
+// RegisterRequest is the body of requests that create accounts.
+// If DisplayName is empty, the account is displayed as its username.
+type RegisterRequest struct {
+	Username    string `json:"username"`
+	Password    string `json:"password"`
+	DisplayName string `json:"display_name,omitempty"`
+}
+
+// LoginRequest is the body of requests that sign in to an account.
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// SessionResponse is the response to requests that sign in. The
+// token is sent in an "Authorization: Bearer" header to make
+// requests as the account's player.
+type SessionResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Account   Account   `json:"account"`
+}
+
+// AccountChange is the body of requests that change an account.
+// Fields that are nil are left as they are, and Preferences
+// replaces all of the account's preferences.
+type AccountChange struct {
+	DisplayName *string      `json:"display_name,omitempty"`
+	Preferences *Preferences `json:"preferences,omitempty"`
+}
+
+// Team is a named group of players who compete on the team
+// leaderboards. A game counts toward a team if at least two of its
+// members played it and no one else did, apart from bots.
+type Team struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	PlayerIDs []string  `json:"player_ids"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TeamRequest is the body of requests to create a team.
+type TeamRequest struct {
+	Name      string   `json:"name"`
+	PlayerIDs []string `json:"player_ids"`
+}
+
+// TeamsResponse is the response to GET /teams.
+type TeamsResponse struct {
+	Teams []Team `json:"teams"`
+}
+
+// Leaderboard ranks the teams by their scores over a season. Season
+// is a calendar month in UTC, like "2026-10", made up of the games
+// that finished from Start until End, or "all" for every game.
+type Leaderboard struct {
+	Season string         `json:"season"`
+	Start  *time.Time     `json:"start,omitempty"`
+	End    *time.Time     `json:"end,omitempty"`
+	Teams  []TeamStanding `json:"teams"`
+}
+
+// TeamStanding is a team's place on a leaderboard. Each win scores
+// WinPoints plus a point for every timer token left over, and losses
+// score nothing. Teams with the same score share a rank.
+type TeamStanding struct {
+	Rank            int    `json:"rank"`
+	TeamID          string `json:"team_id"`
+	Name            string `json:"name"`
+	GamesPlayed     int    `json:"games_played"`
+	Wins            int    `json:"wins"`
+	Losses          int    `json:"losses"`
+	TokensRemaining int    `json:"tokens_remaining"` // in the games it won
+	Score           int    `json:"score"`
+}
+
+// LobbyResponse is the response to GET /lobby. Version identifies
+// the listing, so that clients can wait for it to change.
+type LobbyResponse struct {
+	Version string      `json:"version"`
+	Games   []LobbyGame `json:"games"`
+}
+
+// LobbyGame describes a public game in the lobby. Mode is "standard",
+// "bots" if bots are playing or "practice". PlayersA and PlayersB
+// count the players on each side, including bots.
+type LobbyGame struct {
+	ID                string    `json:"id"`
+	Name              string    `json:"name"`
+	Mode              string    `json:"mode"`
+	WordList          string    `json:"word_list"`
+	PlayersA          int       `json:"players_a"`
+	PlayersB          int       `json:"players_b"`
+	LookingForPlayers bool      `json:"looking_for_players"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// AdminGamesResponse is the response to GET /admin/games.
+type AdminGamesResponse struct {
+	Games []AdminGameSummary `json:"games"`
+}
+
+// AdminGameSummary describes a game for admins.
+type AdminGameSummary struct {
+	ID         string    `json:"id"`
+	Seed       Seed      `json:"seed"`
+	CreatedAt  time.Time `json:"created_at"`
+	AgeSeconds int64     `json:"age_seconds"`
+	Players    int       `json:"players"` // including bots
+	Bots       int       `json:"bots"`
+	Events     int       `json:"events"`
+	Practice   bool      `json:"practice"`
+	Private    bool      `json:"private"`
+	Finished   bool      `json:"finished"`
+}
+
+// AdminGame is the response to GET /admin/games/{id}. Game is the
+// game as players see it.
+type AdminGame struct {
+	Game    json.RawMessage   `json:"game"`
+	Status  Status            `json:"status"`
+	Players map[string]Player `json:"players"`
+	Kicked  []string          `json:"kicked"`
+	Muted   []string          `json:"muted"`
+}
+
+// AdminPurgeResponse is the response to requests that
+// remove chat messages.
+type AdminPurgeResponse struct {
+	Removed int `json:"removed"`
+}
+
+// ErrorResponse is the body of every error response.
+type ErrorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,198 @@
+package gameapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultRisk is the risk tolerance of bots that
+// don't specify one.
+const DefaultRisk = 0.5
+
+// A Guesser ranks the cards on a board by how likely they are
+// to be the targets of a clue. Package gameapi/ai provides an
+// implementation based on word embeddings.
+type Guesser interface {
+	// RankGuesses returns guesses for the board's hidden cards,
+	// most confident first. The board's Key is nil.
+	RankGuesses(b Board, clue string) []Guess
+}
+
+// Guess is a card that a Guesser might guess.
+type Guess struct {
+	Index      int     `json:"index"`
+	Confidence float64 `json:"confidence"`
+}
+
+// WithGuesser configures the handler to allow bot players,
+// which make guesses with the provided guesser.
+func WithGuesser(g Guesser) Option {
+	return func(h *handler) {
+		h.guesser = g
+	}
+}
+
+// POST /games/{id}/bots
+// Adds a bot player to a side. The bot guesses whenever a player
+// on the other side gives a clue.
+func (h *handler) handleAddBot(rw http.ResponseWriter, req *http.Request) {
+	var body AddBotRequest
+	err := json.NewDecoder(req.Body).Decode(&body)
+	if err != nil || body.PlayerID == "" || (body.Team != 1 && body.Team != 2) || (body.Risk != nil && (*body.Risk < 0 || *body.Risk > 1)) {
+		writeError(rw, "malformed_body", "Unable to parse request body.", 400)
+		return
+	}
+	if h.guesser == nil {
+		writeError(rw, "not_implemented", "This server isn't configured to play bots.", 501)
+		return
+	}
+	if body.Name == "" {
+		body.Name = "Bot"
+	}
+	risk := DefaultRisk
+	if body.Risk != nil {
+		risk = *body.Risk
+	}
+
+	h.mu.Lock()
+	botID := "bot-" + strconv.FormatInt(h.rand.Int63(), 36)
+	h.mu.Unlock()
+
+	_, err = h.exec(req.Context(), command{
+		Op:       opAddBot,
+		GameID:   req.PathValue("id"),
+		Seed:     body.Seed,
+		PlayerID: body.PlayerID,
+		TargetID: botID,
+		Name:     body.Name,
+		Team:     body.Team,
+		Risk:     risk,
+	})
+	writeResult(rw, AddBotResponse{PlayerID: botID}, err)
+}
+
+// POST /clue
+// Records a clue given by a player, for the other side to guess.
+// Any bots on the other side guess before the response is written.
+func (h *handler) handleClue(rw http.ResponseWriter, req *http.Request) {
+	var body ClueRequest
+	err := decodePlayerRequest(req, &body, &body.PlayerRequest)
+	body.Word = strings.TrimSpace(body.Word)
+	if err != nil || body.GameID == "" || body.Team == 0 || body.PlayerID == "" ||
+		body.Word == "" || strings.ContainsAny(body.Word, " \t\n") || body.Number < 0 {
+		writeError(rw, "malformed_body", "Unable to parse request body.", 400)
+		return
+	}
+
+	cmd := body.command(opClue)
+	cmd.Message = body.Word
+	cmd.Index = body.Number
+	resp, err := h.exec(req.Context(), cmd)
+	if err == nil {
+		err = h.playBots(req.Context(), body.GameID, body.Seed, otherTeam(body.Team), body.Word, body.Number)
+	}
+	writeResult(rw, resp, err)
+}
+
+// playBots has a bot on the provided team respond to a clue, if the
+// team has any bots. The bot guesses cards in order of confidence
+// until it guesses a card that isn't green, or until it has made as
+// many guesses as the clue's number. Bots with a risk tolerance over
+// one half take a bonus guess. A bot stops early if it's less than
+// (1 - risk) times as confident in a guess as in its first guess.
+// If the bot stops guessing of its own accord, it ends the turn.
+func (h *handler) playBots(ctx context.Context, gameID string, seed Seed, team int, clue string, number int) error {
+	if h.guesser == nil {
+		return nil
+	}
+	g, ok := h.games.get(gameID)
+	if !ok {
+		return nil
+	}
+
+	g.mu.Lock()
+	botID, bot := g.bot(team)
+	b := g.board(otherTeam(team))
+	g.mu.Unlock()
+	if botID == "" || !h.botsTurn(gameID, seed, team) {
+		return nil
+	}
+	b.Key = nil
+
+	maxGuesses := number
+	if maxGuesses == 0 {
+		maxGuesses = 1
+	}
+	if bot.Risk > 0.5 {
+		maxGuesses++
+	}
+
+	guesses := h.guesser.RankGuesses(b, clue)
+	for i, guess := range guesses {
+		if i >= maxGuesses || (i > 0 && guess.Confidence < (1-bot.Risk)*guesses[0].Confidence) {
+			break
+		}
+		_, err := h.exec(ctx, command{
+			Op:       opGuess,
+			GameID:   gameID,
+			Seed:     seed,
+			PlayerID: botID,
+			Name:     bot.Name,
+			Team:     team,
+			Index:    guess.Index,
+		})
+		if err != nil {
+			return err
+		}
+		if !h.botsTurn(gameID, seed, team) {
+			return nil
+		}
+	}
+
+	if !h.botsTurn(gameID, seed, team) {
+		return nil
+	}
+	_, err := h.exec(ctx, command{
+		Op:       opEndTurn,
+		GameID:   gameID,
+		Seed:     seed,
+		PlayerID: botID,
+		Name:     bot.Name,
+		Team:     team,
+	})
+	return err
+}
+
+// botsTurn returns true if the provided team may guess, either
+// because it's their turn or because no one has guessed yet.
+func (h *handler) botsTurn(gameID string, seed Seed, team int) bool {
+	g, ok := h.games.get(gameID)
+	if !ok {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	st := g.status()
+	return g.Seed == seed && (st.Turn == team || st.Turn == 0) && !st.Finished()
+}
+
+// bot returns the bot player on the provided team with the lowest
+// ID, or an empty ID if the team has no bots. The caller must
+// hold g.mu.
+func (g *Game) bot(team int) (string, Player) {
+	var ids []string
+	for id, p := range g.players {
+		if p.Bot && p.Team == team {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return "", Player{}
+	}
+	sort.Strings(ids)
+	return ids[0], g.players[ids[0]]
+}
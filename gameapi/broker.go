@@ -0,0 +1,187 @@
+package gameapi
+
+import (
+	"hash/maphash"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// brokerRetention is how long a broker retains the messages
+// for a game after the last message published about it. The
+// handler forgets games that have been inactive for 24 hours,
+// so there's no need for brokers to hold onto them for longer.
+const brokerRetention = 48 * time.Hour
+
+// A Broker distributes messages between greenapid instances so
+// that several instances can serve the same games. Every message
+// published to a broker is delivered to every subscriber, including
+// the publisher, and all subscribers observe the messages about
+// each game in the same order.
+//
+// Brokers retain the messages about each game since the game's
+// most recent reset, apart from transient ones, so that an instance
+// that subscribes late can catch up on games that are already in
+// progress. Messages with an empty GameID, which aren't about any
+// game, are retained for as long as the broker runs.
+type Broker interface {
+	// Publish sends m to every subscriber. It may return
+	// before m has been delivered.
+	Publish(m Message) error
+	// Subscribe registers fn to be called with every message,
+	// beginning with any retained messages. Calls to fn about
+	// the same game are never made concurrently, but calls
+	// about different games may be.
+	Subscribe(fn func(Message))
+}
+
+// Message is a unit of data distributed by a Broker.
+type Message struct {
+	Seq       uint64 // assigned by the broker when published
+	GameID    string
+	Reset     bool // discard any retained messages for the game
+	Transient bool // deliver the message, but don't retain it
+	Data      []byte
+}
+
+// deliveryShards is the number of queues that each subscriber's
+// messages are delivered through. Messages about the same game go
+// through the same queue, in order, and each queue is delivered
+// from its own goroutine so that a busy game doesn't hold up the
+// games in other queues.
+const deliveryShards = 64
+
+// deliverer delivers messages to a subscriber.
+type deliverer struct {
+	seed   maphash.Seed
+	queues [deliveryShards]*messageQueue
+}
+
+func newDeliverer(fn func(Message)) *deliverer {
+	d := &deliverer{seed: maphash.MakeSeed()}
+	for i := range d.queues {
+		q := newMessageQueue()
+		d.queues[i] = q
+		go func() {
+			for {
+				m, ok := q.pop()
+				if !ok {
+					return
+				}
+				fn(m)
+			}
+		}()
+	}
+	return d
+}
+
+// deliver queues m for delivery without waiting for it.
+func (d *deliverer) deliver(m Message) {
+	if !d.queues[maphash.String(d.seed, m.GameID)%deliveryShards].push(m) {
+		log.Printf("gameapi: dropping message %d for game %s: the subscriber has fallen too far behind", m.Seq, m.GameID)
+	}
+}
+
+func (d *deliverer) close() {
+	for _, q := range d.queues {
+		q.close()
+	}
+}
+
+// LocalBroker is a Broker that delivers messages to
+// subscribers within the same process.
+type LocalBroker struct {
+	mu       sync.Mutex
+	seq      uint64
+	lastSub  int
+	subs     map[int]func(Message) // called with b.mu held
+	retained map[string]*retainedGame
+	lastGC   time.Time
+}
+
+type retainedGame struct {
+	msgs       []Message
+	lastActive time.Time
+}
+
+// NewLocalBroker constructs a new in-process broker.
+func NewLocalBroker() *LocalBroker {
+	return &LocalBroker{
+		// Sequence numbers start from the current time so that they
+		// keep increasing if the broker is restarted, and subscribers
+		// that outlive it can tell new messages from replayed ones.
+		seq:      uint64(time.Now().UnixNano()),
+		subs:     make(map[int]func(Message)),
+		retained: make(map[string]*retainedGame),
+		lastGC:   time.Now(),
+	}
+}
+
+func (b *LocalBroker) Publish(m Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.seq++
+	m.Seq = b.seq
+
+	r, ok := b.retained[m.GameID]
+	if !ok || m.Reset {
+		r = &retainedGame{}
+		b.retained[m.GameID] = r
+	}
+	if !m.Transient {
+		r.msgs = append(r.msgs, m)
+	}
+	r.lastActive = now
+
+	if now.Sub(b.lastGC) > time.Hour {
+		for id, r := range b.retained {
+			if id != "" && now.Sub(r.lastActive) > brokerRetention {
+				delete(b.retained, id)
+			}
+		}
+		b.lastGC = now
+	}
+
+	for _, fn := range b.subs {
+		fn(m)
+	}
+	return nil
+}
+
+// Subscribe registers fn like the Broker interface describes. The
+// retained messages are delivered before Subscribe returns, so that
+// the subscriber has caught up, and later messages are delivered
+// from other goroutines, outside the broker's lock.
+func (b *LocalBroker) Subscribe(fn func(Message)) {
+	b.subscribe(fn, newDeliverer(fn).deliver)
+}
+
+// subscribe calls replay with each retained message, in order, and
+// then registers deliver to be called with every message published.
+// Both are called with the broker's lock held, so deliver mustn't
+// block. It returns a function that removes the subscription.
+func (b *LocalBroker) subscribe(replay, deliver func(Message)) (cancel func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var msgs []Message
+	for _, r := range b.retained {
+		msgs = append(msgs, r.msgs...)
+	}
+	sort.Slice(msgs, func(i, j int) bool { return msgs[i].Seq < msgs[j].Seq })
+	for _, m := range msgs {
+		replay(m)
+	}
+
+	b.lastSub++
+	id := b.lastSub
+	b.subs[id] = deliver
+	return func() {
+		b.mu.Lock()
+		delete(b.subs, id)
+		b.mu.Unlock()
+	}
+}
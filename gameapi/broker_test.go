@@ -0,0 +1,123 @@
+package gameapi
+
+import (
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// testReplicas creates a game on one handler, guesses on the
+// other and checks that both handlers observe the guess.
+func testReplicas(t *testing.T, a, b http.Handler) {
+	var game struct {
+		State GameState `json:"state"`
+	}
+	post(t, a, "/new-game", map[string]interface{}{"game_id": "replicated"}, &game)
+
+	// Start long-polling on the first instance before the guess
+	// is made through the second instance.
+	done := make(chan GameUpdate)
+	go func() {
+		var up GameUpdate
+		post(t, a, "/events", map[string]interface{}{
+			"game_id":   "replicated",
+			"seed":      game.State.Seed,
+			"player_id": "bob",
+		}, &up)
+		done <- up
+	}()
+
+	code := post(t, b, "/guess", map[string]interface{}{
+		"game_id":   "replicated",
+		"seed":      game.State.Seed,
+		"player_id": "alice",
+		"team":      1,
+		"index":     3,
+	}, nil)
+	if code != 200 {
+		t.Fatalf("guess status = %d, want 200", code)
+	}
+
+	for {
+		select {
+		case up := <-done:
+			for _, e := range up.Events {
+				if e.Type == "guess" && e.Index == 3 {
+					return
+				}
+			}
+			t.Fatalf("events = %+v, missing guess", up.Events)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the guess")
+		}
+	}
+}
+
+func TestLocalBrokerReplicas(t *testing.T) {
+	broker := NewLocalBroker()
+	a := Handler(map[string][]string{"example": exampleWords}, WithBroker(broker))
+	b := Handler(map[string][]string{"example": exampleWords}, WithBroker(broker))
+	testReplicas(t, a, b)
+
+	// An instance that starts later catches up on existing games.
+	c := Handler(map[string][]string{"example": exampleWords}, WithBroker(broker))
+	var up GameUpdate
+	post(t, c, "/events", map[string]interface{}{"game_id": "replicated", "player_id": "carol"}, &up)
+	if len(up.Events) != 2 {
+		t.Errorf("len(events) = %d, want 2", len(up.Events))
+	}
+}
+
+func TestLocalBrokerPresence(t *testing.T) {
+	broker := NewLocalBroker()
+	a := Handler(map[string][]string{"example": exampleWords}, WithBroker(broker))
+	seed := newTestGame(t, a, "presence")
+	retained := func() int {
+		broker.mu.Lock()
+		defer broker.mu.Unlock()
+		return len(broker.retained["presence"].msgs)
+	}
+
+	// Only the first ping, which joins alice to side A,
+	// is retained for instances that start later.
+	for i := 0; i < 10; i++ {
+		if code := post(t, a, "/ping", map[string]interface{}{"game_id": "presence", "seed": seed, "player_id": "alice", "name": "Alice", "team": 1}, nil); code != 200 {
+			t.Fatalf("ping status = %d, want 200", code)
+		}
+	}
+	if n := retained(); n != 2 {
+		t.Errorf("retained %d messages, want the new game and alice joining", n)
+	}
+	post(t, a, "/ping", map[string]interface{}{"game_id": "presence", "seed": seed, "player_id": "alice", "name": "Alice", "team": 2}, nil)
+	if n := retained(); n != 3 {
+		t.Errorf("retained %d messages after alice changed sides, want 3", n)
+	}
+
+	b := Handler(map[string][]string{"example": exampleWords}, WithBroker(broker))
+	var up GameUpdate
+	post(t, b, "/events", map[string]interface{}{"game_id": "presence", "player_id": "bob"}, &up)
+	if len(up.Events) != 2 || up.Events[1].Team != 2 {
+		t.Errorf("events on the later instance = %+v, want alice joining A and then B", up.Events)
+	}
+}
+
+func TestTCPBrokerReplicas(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go ServeBroker(l)
+
+	var handlers []http.Handler
+	for i := 0; i < 2; i++ {
+		broker, err := DialBroker(l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer broker.Close()
+		handlers = append(handlers, Handler(map[string][]string{"example": exampleWords}, WithBroker(broker)))
+	}
+	testReplicas(t, handlers[0], handlers[1])
+}
@@ -0,0 +1,216 @@
+package gameapi
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jbowens/dictionary"
+)
+
+// DefaultMaxMessageLength is the longest chat message, in
+// characters, accepted by handlers that aren't configured
+// with WithMaxMessageLength.
+const DefaultMaxMessageLength = 500
+
+// WithMaxMessageLength configures the handler to reject chat
+// messages longer than n characters.
+func WithMaxMessageLength(n int) Option {
+	return func(h *handler) {
+		h.maxMessageLength = n
+	}
+}
+
+// A ChatFilter moderates chat messages before they're sent. It
+// returns the message to send in place of the original, or false
+// if the message should be rejected.
+type ChatFilter interface {
+	Filter(message string) (string, bool)
+}
+
+// WithChatFilter configures the handler to moderate chat
+// messages with the provided filter.
+func WithChatFilter(f ChatFilter) Option {
+	return func(h *handler) {
+		h.chatFilter = f
+	}
+}
+
+// WordFilter is a ChatFilter that looks for words from a list,
+// ignoring case. It either masks the words or rejects messages
+// that contain them.
+type WordFilter struct {
+	words  map[string]bool
+	reject bool
+}
+
+// NewWordFilter returns a filter for the words. If reject is true,
+// it rejects messages containing them instead of masking them.
+func NewWordFilter(words []string, reject bool) *WordFilter {
+	f := &WordFilter{words: make(map[string]bool, len(words)), reject: reject}
+	for _, w := range words {
+		f.words[strings.ToLower(w)] = true
+	}
+	return f
+}
+
+// LoadWordFilter returns a filter for the words listed in
+// the file at path, one per line.
+func LoadWordFilter(path string, reject bool) (*WordFilter, error) {
+	d, err := dictionary.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewWordFilter(d.Words(), reject), nil
+}
+
+// Filter implements ChatFilter.
+func (f *WordFilter) Filter(message string) (string, bool) {
+	var b strings.Builder
+	for len(message) > 0 {
+		// Split the message into runs of letters and
+		// runs of everything else.
+		letters := unicode.IsLetter(firstRune(message))
+		i := strings.IndexFunc(message, func(r rune) bool { return unicode.IsLetter(r) != letters })
+		if i < 0 {
+			i = len(message)
+		}
+		word := message[:i]
+		message = message[i:]
+		if !f.words[strings.ToLower(word)] {
+			b.WriteString(word)
+			continue
+		}
+		if f.reject {
+			return "", false
+		}
+		b.WriteString(strings.Repeat("*", utf8.RuneCountInString(word)))
+	}
+	return b.String(), true
+}
+
+func firstRune(s string) rune {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r
+}
+
+// moderateMessage checks a chat message's length and filters it,
+// returning the message to send.
+func (h *handler) moderateMessage(message string) (string, error) {
+	if h.maxMessageLength > 0 && utf8.RuneCountInString(message) > h.maxMessageLength {
+		return "", &apiError{"message_too_long",
+			"Messages may be at most " + strconv.Itoa(h.maxMessageLength) + " characters.", 400}
+	}
+	if h.chatFilter == nil {
+		return message, nil
+	}
+	message, ok := h.chatFilter.Filter(message)
+	if !ok {
+		return "", &apiError{"message_rejected", "The message contains words that aren't allowed.", 400}
+	}
+	return message, nil
+}
+
+// POST /v2/games/{id}/mutes
+// Mutes or unmutes a player. Only the game's host may mute players.
+func (h *handler) handleMute(rw http.ResponseWriter, req *http.Request) {
+	var body MuteRequest
+	err := decodePlayerRequest(req, &body, &body.PlayerRequest)
+	if err != nil || body.GameID == "" || body.PlayerID == "" || body.TargetID == "" {
+		writeError(rw, "malformed_body", "Unable to parse request body.", 400)
+		return
+	}
+
+	cmd := body.command(opMute)
+	cmd.TargetID = body.TargetID
+	cmd.Mute = body.Mute
+	cmd.RemoveMessages = body.RemoveMessages
+	resp, err := h.exec(req.Context(), cmd)
+	writeResult(rw, resp, err)
+}
+
+// The scopes of chat messages. Messages to a side
+// are only shown to the players on that side.
+const (
+	ScopeAll  = "all"
+	ScopeSide = "side"
+)
+
+var errMuted = &apiError{"muted", "The host has muted you.", 403}
+
+// chat records a chat message, unless the player has been muted.
+func (g *Game) chat(playerID, name string, team int, message, scope string, when time.Time) error {
+	if g.muted[playerID] {
+		return errMuted
+	}
+	if scope == ScopeAll {
+		scope = ""
+	}
+	g.markSeen(playerID, name, team, when)
+	g.addEvent(Event{
+		Type:     "chat",
+		Team:     team,
+		PlayerID: playerID,
+		Name:     name,
+		Message:  message,
+		Scope:    scope,
+	})
+	return nil
+}
+
+// mute mutes or unmutes a player on behalf of the host, and
+// optionally removes the player's messages.
+func (g *Game) mute(hostID, hostName, targetID string, mute, removeMessages bool) error {
+	if hostID != g.Host {
+		return errNotHost
+	}
+	p, ok := g.players[targetID]
+	if !ok {
+		return errPlayerNotFound
+	}
+	if g.muted[targetID] != mute {
+		g.muted[targetID] = mute
+		typ := "player_unmuted"
+		if mute {
+			typ = "player_muted"
+		}
+		g.addEvent(Event{
+			Type:     typ,
+			PlayerID: targetID,
+			Name:     p.Name,
+			Team:     p.Team,
+			Message:  hostName,
+		})
+	}
+	if removeMessages {
+		g.removeMessages(targetID, hostName)
+	}
+	return nil
+}
+
+// removeMessages removes the game's chat messages, or only those
+// sent by playerID if it's non-empty, and returns how many were
+// removed. The messages' text is erased, and for each message a
+// chat_removed event refers to it by number so that clients that
+// have already shown it can hide it.
+func (g *Game) removeMessages(playerID, by string) (removed int) {
+	for i, e := range g.Events {
+		if e.Type != "chat" || e.Message == "" || (playerID != "" && e.PlayerID != playerID) {
+			continue
+		}
+		g.Events[i].Message = ""
+		g.addEvent(Event{
+			Type:     "chat_removed",
+			PlayerID: e.PlayerID,
+			Team:     e.Team,
+			Index:    e.Number,
+			Message:  by,
+			Scope:    e.Scope,
+		})
+		removed++
+	}
+	return removed
+}
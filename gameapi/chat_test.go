@@ -0,0 +1,197 @@
+package gameapi
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWordFilter(t *testing.T) {
+	testCases := []struct {
+		message, want string
+		reject        bool
+	}{
+		{message: "what a darn shame", want: "what a **** shame"},
+		{message: "DARN it, heck!", want: "**** it, ****!"},
+		{message: "darned if I know", want: "darned if I know"},
+		{message: "no problem here", want: "no problem here"},
+		{message: "heck", reject: true},
+		{message: "checkmate", want: "checkmate", reject: true},
+	}
+	for _, tc := range testCases {
+		got, ok := NewWordFilter([]string{"darn", "Heck"}, tc.reject).Filter(tc.message)
+		if want := tc.want != ""; ok != want || got != tc.want {
+			t.Errorf("Filter(%q) = %q, %t; want %q, %t", tc.message, got, ok, tc.want, want)
+		}
+	}
+}
+
+func TestChatModeration(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	h := newTestHandler(clock)
+	h.chatFilter = NewWordFilter([]string{"darn"}, false)
+	seed := newTestGame(t, h, "moderated")
+	player := func(id string, fields ...interface{}) map[string]interface{} {
+		m := map[string]interface{}{"game_id": "moderated", "seed": seed, "player_id": id, "name": id, "team": 1}
+		for i := 0; i < len(fields); i += 2 {
+			m[fields[i].(string)] = fields[i+1]
+		}
+		return m
+	}
+	chat := func(id, message string) int {
+		return post(t, keyed(h, id), "/chat", player(id, "message", message), nil)
+	}
+
+	// The first player seen hosts the game.
+	if code := chat("alice", "darn, hello"); code != 200 {
+		t.Fatalf("alice's chat: status = %d, want 200", code)
+	}
+	if code := chat("mallory", strings.Repeat("a", DefaultMaxMessageLength+1)); code != 400 {
+		t.Errorf("long message: status = %d, want 400", code)
+	}
+	chat("mallory", "spam")
+	chat("mallory", "more spam")
+
+	g, _ := h.games.get("moderated")
+	g.mu.Lock()
+	host, first := g.Host, g.Events[1].Message
+	g.mu.Unlock()
+	if host != "alice" {
+		t.Errorf("host = %q, want alice", host)
+	}
+	if first != "****, hello" {
+		t.Errorf("filtered message = %q, want %q", first, "****, hello")
+	}
+
+	if code := post(t, keyed(h, "mallory"), "/v2/games/moderated/mutes", player("mallory", "target_id", "alice", "mute", true), nil); code != 403 {
+		t.Errorf("mute by a guest: status = %d, want 403", code)
+	}
+	if code := post(t, keyed(h, "alice"), "/v2/games/moderated/mutes", player("alice", "target_id", "mallory", "mute", true, "remove_messages", true), nil); code != 200 {
+		t.Fatalf("mute by the host: status = %d, want 200", code)
+	}
+	if code := chat("mallory", "let me talk"); code != 403 {
+		t.Errorf("muted player's chat: status = %d, want 403", code)
+	}
+
+	g.mu.Lock()
+	var removed []int
+	for _, e := range g.Events {
+		if e.Type == "chat_removed" {
+			removed = append(removed, e.Index)
+			if m := g.Events[e.Index-1]; m.PlayerID != "mallory" || m.Message != "" {
+				t.Errorf("removed message %+v, want mallory's message without its text", m)
+			}
+		}
+	}
+	g.mu.Unlock()
+	if len(removed) != 2 {
+		t.Errorf("removed messages %v, want mallory's 2 messages", removed)
+	}
+
+	if code := post(t, keyed(h, "alice"), "/v2/games/moderated/mutes", player("alice", "target_id", "mallory"), nil); code != 200 {
+		t.Fatalf("unmute: status = %d, want 200", code)
+	}
+	clock.Advance(time.Second) // for mallory's rate limit
+	if code := chat("mallory", "sorry"); code != 200 {
+		t.Errorf("unmuted player's chat: status = %d, want 200", code)
+	}
+}
+
+func TestSideChat(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	h := newTestHandler(clock)
+	seed := newTestGame(t, h, "sides")
+	player := func(id string, team int, fields ...interface{}) map[string]interface{} {
+		m := map[string]interface{}{"game_id": "sides", "seed": seed, "player_id": id, "name": id, "team": team}
+		for i := 0; i < len(fields); i += 2 {
+			m[fields[i].(string)] = fields[i+1]
+		}
+		return m
+	}
+	messages := func(evts []Event) (msgs []string) {
+		for _, e := range evts {
+			if e.Type == "chat" {
+				msgs = append(msgs, e.Message)
+			}
+		}
+		return msgs
+	}
+	post(t, keyed(h, "bob"), "/ping", player("bob", 2), nil)
+	post(t, keyed(h, "carol"), "/ping", player("carol", 1), nil)
+	if code := post(t, h, "/chat", player("alice", 1, "message", "psst", "scope", "side"), nil); code != 200 {
+		t.Fatalf("side chat: status = %d, want 200", code)
+	}
+	if code := post(t, h, "/chat", player("alice", 1, "message", "hi", "scope", "everyone"), nil); code != 400 {
+		t.Errorf("chat with an unknown scope: status = %d, want 400", code)
+	}
+
+	var carol, bob GameUpdate
+	post(t, keyed(h, "carol"), "/events", player("carol", 1), &carol)
+	post(t, keyed(h, "bob"), "/events", player("bob", 2), &bob)
+	if got := messages(carol.Events); len(got) != 1 || got[0] != "psst" {
+		t.Errorf("side A saw %q, want the side message", got)
+	}
+	if got := messages(bob.Events); len(got) != 0 {
+		t.Errorf("side B saw %q, want no messages", got)
+	}
+	var game struct {
+		State GameState `json:"state"`
+	}
+	request(context.Background(), t, h, "GET", "/v2/games/sides", nil, &game)
+	if got := messages(game.State.Events); len(got) != 0 {
+		t.Errorf("the game's events include %q, want no messages", got)
+	}
+
+	// Players who reload the game see their own side's messages.
+	for _, r := range []struct {
+		method, path string
+		body         interface{}
+	}{
+		{"GET", "/v2/games/sides?player_id=carol", nil},
+		{"POST", "/new-game", map[string]interface{}{"game_id": "sides", "player_id": "carol"}},
+	} {
+		request(context.Background(), t, keyed(h, "carol"), r.method, r.path, r.body, &game)
+		if got := messages(game.State.Events); len(got) != 1 || got[0] != "psst" {
+			t.Errorf("%s %s: events include %q, want the side message", r.method, r.path, got)
+		}
+
+		// Player IDs are public, so strangers who use carol's
+		// don't see her side's messages.
+		for _, stranger := range []http.Handler{h, keyed(h, "mallory")} {
+			game.State.Events = nil
+			request(context.Background(), t, stranger, r.method, r.path, r.body, &game)
+			if got := messages(game.State.Events); len(got) != 0 {
+				t.Errorf("%s %s without carol's key: events include %q, want no messages", r.method, r.path, got)
+			}
+		}
+	}
+	for _, stranger := range []http.Handler{h, keyed(h, "mallory")} {
+		var update GameUpdate
+		post(t, stranger, "/events", player("carol", 1), &update)
+		if got := messages(update.Events); len(got) != 0 {
+			t.Errorf("polling as carol without her key saw %q, want no messages", got)
+		}
+	}
+	request(context.Background(), t, keyed(h, "bob"), "GET", "/v2/games/sides?player_id=bob", nil, &game)
+	if got := messages(game.State.Events); len(got) != 0 {
+		t.Errorf("side B's view of the game includes %q, want no messages", got)
+	}
+
+	// Side A's messages wake up side B's long polls,
+	// but they keep waiting for an event they can see.
+	last := bob.Events[len(bob.Events)-1].Number
+	polled := make(chan GameUpdate)
+	go func() {
+		var update GameUpdate
+		post(t, keyed(h, "bob"), "/events", player("bob", 2, "last_event", last), &update)
+		polled <- update
+	}()
+	clock.BlockUntil(2) // the pruning ticker and bob's long poll
+	post(t, h, "/chat", player("alice", 1, "message", "secret", "scope", "side"), nil)
+	post(t, h, "/chat", player("alice", 1, "message", "hello"), nil)
+	if got := messages((<-polled).Events); len(got) != 1 || got[0] != "hello" {
+		t.Errorf("side B's long poll saw %q, want only the message to everyone", got)
+	}
+}
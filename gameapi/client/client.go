@@ -0,0 +1,491 @@
+// Package client implements a client for the Codenames Green API,
+// for bots and other tools written in Go.
+package client
+
+import (
+	"bytes"
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jbowens/codenamesgreen/gameapi"
+)
+
+// Client makes requests to a Codenames Green server.
+type Client struct {
+	// Access is a private game's passcode, or an invite to it. It's
+	// sent with every request.
+	Access string
+	// Session is a signed-in player's session token. While it's
+	// set, requests are made as the account's player.
+	Session string
+	// Key is the players' key, which New makes up. Players are
+	// bound to the key that they join games with, and hosts need
+	// theirs to act as host. It's sent with every request.
+	Key string
+
+	baseURL string
+	http    *http.Client
+}
+
+// New returns a client for the server at baseURL, like
+// "https://api.codenamesgreen.com". If httpClient is nil,
+// http.DefaultClient is used.
+func New(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	key := make([]byte, 16)
+	if _, err := crand.Read(key); err != nil {
+		panic(err)
+	}
+	return &Client{
+		Key:     hex.EncodeToString(key),
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		http:    httpClient,
+	}
+}
+
+// Error is an error response from the server.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("codenames green: %s (%d %s)", e.Message, e.StatusCode, e.Code)
+}
+
+// Game is a game's board and the events that have happened in it.
+type Game struct {
+	ID        string
+	Seed      gameapi.Seed
+	CreatedAt time.Time
+	Words     []string
+	OneLayout []gameapi.Color // side A's key
+	TwoLayout []gameapi.Color // side B's key
+	Events    []gameapi.Event
+	Host      string // the host's player ID
+	Settings  gameapi.Settings
+}
+
+// UnmarshalJSON decodes a game in the format the server writes it.
+func (g *Game) UnmarshalJSON(b []byte) error {
+	var resp struct {
+		State struct {
+			Seed     gameapi.Seed     `json:"seed"`
+			Events   []gameapi.Event  `json:"events"`
+			Host     string           `json:"host"`
+			Settings gameapi.Settings `json:"settings"`
+		} `json:"state"`
+		CreatedAt time.Time       `json:"created_at"`
+		Words     []string        `json:"words"`
+		OneLayout []gameapi.Color `json:"one_layout"`
+		TwoLayout []gameapi.Color `json:"two_layout"`
+	}
+	if err := json.Unmarshal(b, &resp); err != nil {
+		return err
+	}
+	*g = Game{
+		ID:        g.ID,
+		Seed:      resp.State.Seed,
+		CreatedAt: resp.CreatedAt,
+		Words:     resp.Words,
+		OneLayout: resp.OneLayout,
+		TwoLayout: resp.TwoLayout,
+		Events:    resp.State.Events,
+		Host:      resp.State.Host,
+		Settings:  resp.State.Settings,
+	}
+	return nil
+}
+
+// CreateGame creates a game. If req.GameID is empty, the server
+// chooses an ID for the game.
+func (c *Client) CreateGame(ctx context.Context, req gameapi.NewGameRequest) (*Game, error) {
+	var g Game
+	header, err := c.send(ctx, "POST", "/v2/games", req, &g)
+	if err != nil {
+		return nil, err
+	}
+	id, err := url.PathUnescape(path.Base(header.Get("Location")))
+	if err != nil || id == "" || id == "." {
+		return nil, fmt.Errorf("codenames green: unable to determine the new game's ID from %q", header.Get("Location"))
+	}
+	g.ID = id
+	return &g, nil
+}
+
+// NewGame creates the game with the ID, or replaces it with a new
+// game. To replace a game, prevSeed must be the game's current seed
+// and, if the game has a host, req.PlayerID must be the host's ID.
+func (c *Client) NewGame(ctx context.Context, id string, prevSeed *gameapi.Seed, req gameapi.NewGameRequest) (*Game, error) {
+	req.GameID = id
+	req.PrevSeed = prevSeed
+	g := Game{ID: id}
+	if err := c.do(ctx, "PUT", "/v2/games/"+url.PathEscape(id), req, &g); err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// Game retrieves the game with the ID.
+func (c *Client) Game(ctx context.Context, id string) (*Game, error) {
+	g := Game{ID: id}
+	if err := c.do(ctx, "GET", "/v2/games/"+url.PathEscape(id), nil, &g); err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// PracticeResults returns the results of a practice game so far.
+func (c *Client) PracticeResults(ctx context.Context, id string) (gameapi.PracticeResults, error) {
+	var r gameapi.PracticeResults
+	err := c.do(ctx, "GET", "/v2/games/"+url.PathEscape(id)+"/practice", nil, &r)
+	return r, err
+}
+
+// Lobby lists the public games. If version is the version of the
+// current listing, it waits for the listing to change, returning the
+// same listing if it doesn't change for a while. Pass an empty
+// version to get the listing right away.
+func (c *Client) Lobby(ctx context.Context, version string) (gameapi.LobbyResponse, error) {
+	var lobby gameapi.LobbyResponse
+	err := c.do(ctx, "GET", "/lobby?"+url.Values{"version": {version}}.Encode(), nil, &lobby)
+	return lobby, err
+}
+
+// FindMatch waits until the player has been paired with another
+// player, returning the game that was created for them and the
+// side to join.
+func (c *Client) FindMatch(ctx context.Context, req gameapi.MatchmakingRequest) (gameapi.MatchmakingResponse, error) {
+	for {
+		var match gameapi.MatchmakingResponse
+		if err := c.do(ctx, "POST", "/matchmaking/join", req, &match); err != nil || match.Status != "waiting" {
+			return match, err
+		}
+	}
+}
+
+// PlayerStats retrieves a player's lifetime statistics.
+func (c *Client) PlayerStats(ctx context.Context, playerID string) (gameapi.PlayerStats, error) {
+	var s gameapi.PlayerStats
+	err := c.do(ctx, "GET", "/players/"+url.PathEscape(playerID)+"/stats", nil, &s)
+	return s, err
+}
+
+// CreateTeam creates a team of players for the leaderboards.
+func (c *Client) CreateTeam(ctx context.Context, name string, playerIDs ...string) (gameapi.Team, error) {
+	var t gameapi.Team
+	err := c.do(ctx, "POST", "/teams", gameapi.TeamRequest{Name: name, PlayerIDs: playerIDs}, &t)
+	return t, err
+}
+
+// Teams lists the teams.
+func (c *Client) Teams(ctx context.Context) ([]gameapi.Team, error) {
+	var resp gameapi.TeamsResponse
+	err := c.do(ctx, "GET", "/teams", nil, &resp)
+	return resp.Teams, err
+}
+
+// TeamLeaderboard ranks the teams over a season: a month, like
+// "2026-10", or "all". An empty season is the current month.
+func (c *Client) TeamLeaderboard(ctx context.Context, season string) (gameapi.Leaderboard, error) {
+	var lb gameapi.Leaderboard
+	err := c.do(ctx, "GET", "/leaderboards/teams?"+url.Values{"season": {season}}.Encode(), nil, &lb)
+	return lb, err
+}
+
+// Register creates an account and signs in to it, setting c.Session.
+func (c *Client) Register(ctx context.Context, req gameapi.RegisterRequest) (gameapi.Account, error) {
+	return c.signIn(ctx, "/accounts", req)
+}
+
+// Login signs in to an account, setting c.Session.
+func (c *Client) Login(ctx context.Context, username, password string) (gameapi.Account, error) {
+	return c.signIn(ctx, "/login", gameapi.LoginRequest{Username: username, Password: password})
+}
+
+func (c *Client) signIn(ctx context.Context, path string, body interface{}) (gameapi.Account, error) {
+	var session gameapi.SessionResponse
+	if err := c.do(ctx, "POST", path, body, &session); err != nil {
+		return gameapi.Account{}, err
+	}
+	c.Session = session.Token
+	return session.Account, nil
+}
+
+// Logout ends c.Session.
+func (c *Client) Logout(ctx context.Context) error {
+	if err := c.do(ctx, "POST", "/logout", nil, nil); err != nil {
+		return err
+	}
+	c.Session = ""
+	return nil
+}
+
+// Account retrieves the signed-in player's account.
+func (c *Client) Account(ctx context.Context) (gameapi.Account, error) {
+	var a gameapi.Account
+	err := c.do(ctx, "GET", "/accounts/me", nil, &a)
+	return a, err
+}
+
+// UpdateAccount changes the signed-in player's account.
+func (c *Client) UpdateAccount(ctx context.Context, change gameapi.AccountChange) (gameapi.Account, error) {
+	var a gameapi.Account
+	err := c.do(ctx, "PATCH", "/accounts/me", change, &a)
+	return a, err
+}
+
+// do makes a request, encoding body as its JSON body if it's non-nil
+// and decoding the JSON response into resp if it's non-nil.
+func (c *Client) do(ctx context.Context, method, path string, body, resp interface{}) error {
+	_, err := c.send(ctx, method, path, body, resp)
+	return err
+}
+
+// send makes a request like do, returning the response's header.
+func (c *Client) send(ctx context.Context, method, path string, body, resp interface{}) (http.Header, error) {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, r)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Access != "" {
+		req.Header.Set(gameapi.AccessHeader, c.Access)
+	}
+	if c.Session != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Session)
+	}
+	if c.Key != "" {
+		req.Header.Set(gameapi.PlayerKeyHeader, c.Key)
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode/100 != 2 {
+		apiErr := &Error{StatusCode: res.StatusCode}
+		var e gameapi.ErrorResponse
+		if json.Unmarshal(b, &e) == nil && e.Code != "" {
+			apiErr.Code, apiErr.Message = e.Code, e.Message
+		} else {
+			apiErr.Message = strings.TrimSpace(string(b))
+		}
+		return nil, apiErr
+	}
+	if resp == nil {
+		return res.Header, nil
+	}
+	return res.Header, json.Unmarshal(b, resp)
+}
+
+// Player makes requests on behalf of a player in a game.
+type Player struct {
+	c      *Client
+	GameID string
+	Seed   gameapi.Seed
+	ID     string
+	Name   string
+	Team   int // 1 for side A, 2 for side B, or 0 before choosing
+}
+
+// Player returns a Player for making requests on behalf of a player
+// in the game. If id is empty, a random ID is generated. The player
+// isn't added to the game until it makes its first request.
+func (c *Client) Player(g *Game, id, name string, team int) *Player {
+	if id == "" {
+		id = strconv.FormatInt(rand.Int63(), 36)
+	}
+	return &Player{c: c, GameID: g.ID, Seed: g.Seed, ID: id, Name: name, Team: team}
+}
+
+func (p *Player) request() gameapi.PlayerRequest {
+	return gameapi.PlayerRequest{
+		GameID:   p.GameID,
+		Seed:     p.Seed,
+		PlayerID: p.ID,
+		Name:     p.Name,
+		Team:     p.Team,
+	}
+}
+
+func (p *Player) route(resource string) string {
+	return "/v2/games/" + url.PathEscape(p.GameID) + resource
+}
+
+// Guess guesses the card at index.
+func (p *Player) Guess(ctx context.Context, index int) error {
+	return p.c.do(ctx, "POST", p.route("/guesses"), gameapi.GuessRequest{
+		PlayerRequest: p.request(),
+		Index:         index,
+	}, nil)
+}
+
+// EndTurn ends the player's side's turn.
+func (p *Player) EndTurn(ctx context.Context) error {
+	return p.c.do(ctx, "POST", p.route("/end-turn"), p.request(), nil)
+}
+
+// Chat sends a chat message to everyone in the game.
+func (p *Player) Chat(ctx context.Context, message string) error {
+	return p.chat(ctx, message, gameapi.ScopeAll)
+}
+
+// ChatSide sends a chat message that only the
+// player's side can see.
+func (p *Player) ChatSide(ctx context.Context, message string) error {
+	return p.chat(ctx, message, gameapi.ScopeSide)
+}
+
+func (p *Player) chat(ctx context.Context, message, scope string) error {
+	return p.c.do(ctx, "POST", p.route("/messages"), gameapi.ChatRequest{
+		PlayerRequest: p.request(),
+		Message:       message,
+		Scope:         scope,
+	}, nil)
+}
+
+// Mute mutes another player in the game, which the player may do
+// if they're the game's host. If removeMessages is true, the other
+// player's messages are removed too.
+func (p *Player) Mute(ctx context.Context, targetID string, removeMessages bool) error {
+	return p.c.do(ctx, "POST", p.route("/mutes"), gameapi.MuteRequest{
+		PlayerRequest:  p.request(),
+		TargetID:       targetID,
+		Mute:           true,
+		RemoveMessages: removeMessages,
+	}, nil)
+}
+
+// Unmute unmutes another player in the game.
+func (p *Player) Unmute(ctx context.Context, targetID string) error {
+	return p.c.do(ctx, "POST", p.route("/mutes"), gameapi.MuteRequest{
+		PlayerRequest: p.request(),
+		TargetID:      targetID,
+	}, nil)
+}
+
+// Kick removes another player from the game, which the
+// player may do if they're the game's host.
+func (p *Player) Kick(ctx context.Context, targetID string) error {
+	return p.c.do(ctx, "POST", p.route("/kicks"), gameapi.TargetRequest{
+		PlayerRequest: p.request(),
+		TargetID:      targetID,
+	}, nil)
+}
+
+// MakeHost hands the game over to another player, which the
+// player may do if they're the game's host.
+func (p *Player) MakeHost(ctx context.Context, targetID string) error {
+	return p.c.do(ctx, "PUT", p.route("/host"), gameapi.TargetRequest{
+		PlayerRequest: p.request(),
+		TargetID:      targetID,
+	}, nil)
+}
+
+// ChangeSettings changes the game's settings, which the player may
+// do if they're the game's host, and returns the new settings.
+func (p *Player) ChangeSettings(ctx context.Context, change gameapi.SettingsChange) (gameapi.Settings, error) {
+	var settings gameapi.Settings
+	err := p.c.do(ctx, "PATCH", p.route("/settings"), gameapi.SettingsRequest{
+		PlayerRequest:  p.request(),
+		SettingsChange: change,
+	}, &settings)
+	return settings, err
+}
+
+// Invite returns an invite to the game that lasts for ttl, which
+// the player may request if they're the game's host and the game
+// is private. If ttl is zero, the invite lasts for
+// gameapi.DefaultInviteTTL.
+func (p *Player) Invite(ctx context.Context, ttl time.Duration) (gameapi.InviteResponse, error) {
+	var invite gameapi.InviteResponse
+	err := p.c.do(ctx, "POST", p.route("/invites"), gameapi.InviteRequest{
+		PlayerRequest: p.request(),
+		TTLSeconds:    int(ttl / time.Second),
+	}, &invite)
+	return invite, err
+}
+
+// NextGame replaces the game with a new one, which the player may
+// do if they're the game's host or it doesn't have one.
+func (p *Player) NextGame(ctx context.Context, req gameapi.NewGameRequest) (*Game, error) {
+	req.PlayerID = p.ID
+	return p.c.NewGame(ctx, p.GameID, &p.Seed, req)
+}
+
+// GiveClue gives a clue for the other side to guess.
+func (p *Player) GiveClue(ctx context.Context, word string, number int) error {
+	return p.c.do(ctx, "POST", p.route("/clues"), gameapi.ClueRequest{
+		PlayerRequest: p.request(),
+		Word:          word,
+		Number:        number,
+	}, nil)
+}
+
+// AddBot adds a bot named name to side team, which guesses whenever
+// the other side gives a clue, and returns the bot's player ID. The
+// server must be configured to play bots.
+func (p *Player) AddBot(ctx context.Context, team int, name string) (string, error) {
+	var resp gameapi.AddBotResponse
+	err := p.c.do(ctx, "POST", p.route("/bots"), gameapi.AddBotRequest{
+		Seed:     p.Seed,
+		PlayerID: p.ID,
+		Team:     team,
+		Name:     name,
+	}, &resp)
+	return resp.PlayerID, err
+}
+
+// Ping records that the player is still playing, along with any
+// change to their name or side. Players that stop making requests
+// are soon removed from the game; streaming the game's events
+// keeps the player in the game.
+func (p *Player) Ping(ctx context.Context) error {
+	return p.c.do(ctx, "PUT", p.route("/players/"+url.PathEscape(p.ID)), p.request(), nil)
+}
+
+// Poll waits for events after lastEvent, returning the game's seed
+// and the events. If the game has been replaced, the seed differs
+// from the player's and the events are from the new game.
+func (p *Player) Poll(ctx context.Context, lastEvent int) (gameapi.GameUpdate, error) {
+	q := url.Values{
+		"seed":       {strconv.FormatInt(int64(p.Seed), 10)},
+		"player_id":  {p.ID},
+		"name":       {p.Name},
+		"team":       {strconv.Itoa(p.Team)},
+		"last_event": {strconv.Itoa(lastEvent)},
+	}
+	var update gameapi.GameUpdate
+	err := p.c.do(ctx, "GET", p.route("/events?"+q.Encode()), nil, &update)
+	return update, err
+}
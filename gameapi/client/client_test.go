@@ -0,0 +1,164 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jbowens/codenamesgreen/gameapi"
+)
+
+func newServer(t *testing.T, wrap func(http.Handler) http.Handler) *Client {
+	var words []string
+	for i := 0; i < 30; i++ {
+		words = append(words, fmt.Sprintf("WORD%d", i))
+	}
+	var h http.Handler = gameapi.Handler(map[string][]string{"test": words})
+	if wrap != nil {
+		h = wrap(h)
+	}
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return New(srv.URL, srv.Client())
+}
+
+func TestGameplay(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c := newServer(t, nil)
+
+	g, err := c.CreateGame(ctx, gameapi.NewGameRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.ID == "" || len(g.Words) != 25 || len(g.OneLayout) != 25 {
+		t.Fatalf("created game = %+v", g)
+	}
+	if got, err := c.Game(ctx, g.ID); err != nil || got.Seed != g.Seed || got.TwoLayout[3] != g.TwoLayout[3] {
+		t.Errorf("Game(%q) = %+v, %v", g.ID, got, err)
+	}
+
+	alice := c.Player(g, "alice", "Alice", 1)
+	bob := c.Player(g, "", "Bob", 2)
+	for _, step := range []func(context.Context) error{
+		alice.Ping,
+		bob.Ping,
+		func(ctx context.Context) error { return bob.GiveClue(ctx, "fruit", 2) },
+		func(ctx context.Context) error { return alice.Guess(ctx, 7) },
+		func(ctx context.Context) error { return alice.Chat(ctx, "hmm") },
+		alice.EndTurn,
+	} {
+		if err := step(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := alice.Stream(ctx)
+	var got []string
+	for len(got) < 6 && s.Next() {
+		e := s.Event()
+		got = append(got, e.Type+":"+e.Name)
+	}
+	want := "join_side:Alice join_side:Bob clue:Bob guess:Alice chat:Alice end_turn:Alice"
+	if strings.Join(got, " ") != want {
+		t.Errorf("events = %v, want %s (err %v)", got, want, s.Err())
+	}
+}
+
+func TestStreamReset(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c := newServer(t, nil)
+
+	g, err := c.NewGame(ctx, "reset", nil, gameapi.NewGameRequest{WordList: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := c.Player(g, "alice", "Alice", 1)
+	s := p.Stream(ctx)
+	if !s.Next() || s.Reset() || s.Event().Type != "join_side" {
+		t.Fatalf("first event = %+v, reset %t, err %v", s.Event(), s.Reset(), s.Err())
+	}
+
+	if _, err := c.NewGame(ctx, "reset", nil, gameapi.NewGameRequest{}); err == nil {
+		t.Error("replacing a game without its seed succeeded")
+	}
+	if _, err := c.NewGame(ctx, "reset", &g.Seed, gameapi.NewGameRequest{PlayerID: "mallory"}); err == nil {
+		t.Error("replacing a game as a player other than its host succeeded")
+	}
+	next, err := p.NextGame(ctx, gameapi.NewGameRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The stream follows the player into the new game.
+	if !s.Next() || !s.Reset() || s.Event().Type != "join_side" || s.Event().Number != 1 {
+		t.Fatalf("event after reset = %+v, reset %t, err %v", s.Event(), s.Reset(), s.Err())
+	}
+	if p.Seed != next.Seed {
+		t.Errorf("player's seed = %d, want %d", p.Seed, next.Seed)
+	}
+}
+
+func TestStreamRetries(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var failures int32 = 2
+	c := newServer(t, func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if strings.HasSuffix(req.URL.Path, "/events") && atomic.AddInt32(&failures, -1) >= 0 {
+				http.Error(rw, "unavailable", 503)
+				return
+			}
+			h.ServeHTTP(rw, req)
+		})
+	})
+
+	g, err := c.CreateGame(ctx, gameapi.NewGameRequest{GameID: "retries"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := c.Player(g, "alice", "Alice", 2).Stream(ctx)
+	if !s.Next() || s.Event().Type != "join_side" {
+		t.Fatalf("event = %+v, err %v", s.Event(), s.Err())
+	}
+	if atomic.LoadInt32(&failures) >= 0 {
+		t.Error("the stream didn't retry the failed requests")
+	}
+
+	// Requests that fail for reasons that won't go away aren't retried.
+	s = c.Player(&Game{ID: "missing"}, "alice", "Alice", 2).Stream(ctx)
+	var apiErr *Error
+	if s.Next() || !errors.As(s.Err(), &apiErr) || apiErr.Code != "not_found" {
+		t.Errorf("stream of a missing game: err = %v", s.Err())
+	}
+
+	// Streams end when their context is done.
+	ctx, cancel = context.WithCancel(ctx)
+	s = c.Player(g, "bob", "Bob", 1).Stream(ctx)
+	for s.Next() && s.Event().PlayerID != "bob" {
+	}
+	cancel()
+	if s.Next() || !errors.Is(s.Err(), context.Canceled) {
+		t.Errorf("stream after cancellation: err = %v", s.Err())
+	}
+}
+
+func TestErrors(t *testing.T) {
+	ctx := context.Background()
+	c := newServer(t, nil)
+	_, err := c.CreateGame(ctx, gameapi.NewGameRequest{WordList: "missing"})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 400 || apiErr.Code != "unknown_word_list" {
+		t.Errorf("err = %v, want unknown_word_list", err)
+	}
+	if err := c.Player(&Game{ID: "missing"}, "", "", 1).Guess(ctx, 0); !errors.As(err, &apiErr) || apiErr.StatusCode != 404 {
+		t.Errorf("err = %v, want a 404", err)
+	}
+}
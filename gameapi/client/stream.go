@@ -0,0 +1,128 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"github.com/jbowens/codenamesgreen/gameapi"
+)
+
+// Retry delays used by streams after failed requests.
+const (
+	minRetryDelay = 100 * time.Millisecond
+	maxRetryDelay = 10 * time.Second
+)
+
+// Stream iterates over a game's events, long polling the server for
+// new events as they happen:
+//
+//	s := p.Stream(ctx)
+//	for s.Next() {
+//		if s.Reset() {
+//			// The game was replaced with a new game.
+//		}
+//		handle(s.Event())
+//	}
+//	if err := s.Err(); err != nil { ... }
+//
+// Requests that fail because the server is unreachable or
+// unavailable are retried with backoff until the context is done.
+type Stream struct {
+	ctx     context.Context
+	p       *Player
+	last    int
+	pending []gameapi.Event
+	event   gameapi.Event
+	reset   bool
+	err     error
+}
+
+// Stream returns a stream of the game's events, starting with
+// the first event.
+func (p *Player) Stream(ctx context.Context) *Stream {
+	return &Stream{ctx: ctx, p: p}
+}
+
+// Next waits for the next event. It returns false when the stream's
+// context is done or a request fails and can't be retried; Err
+// reports why.
+func (s *Stream) Next() bool {
+	s.reset = false
+	for len(s.pending) == 0 {
+		if s.err != nil {
+			return false
+		}
+		s.poll()
+	}
+	s.event, s.pending = s.pending[0], s.pending[1:]
+	s.last = s.event.Number
+	return true
+}
+
+// poll makes one long polling request, retrying it if necessary.
+func (s *Stream) poll() {
+	delay := minRetryDelay
+	for {
+		update, err := s.p.Poll(s.ctx, s.last)
+		if err == nil {
+			if update.Seed != s.p.Seed {
+				// The game has been replaced. Start again from the
+				// beginning of the new game.
+				s.p.Seed = update.Seed
+				s.last = 0
+				s.reset = true
+				return
+			}
+			s.pending = update.Events
+			return
+		}
+		if s.ctx.Err() != nil {
+			s.err = s.ctx.Err()
+			return
+		}
+		if !retryable(err) {
+			s.err = err
+			return
+		}
+
+		select {
+		case <-s.ctx.Done():
+			s.err = s.ctx.Err()
+			return
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
+
+// Event returns the event that Next waited for.
+func (s *Stream) Event() gameapi.Event {
+	return s.event
+}
+
+// Reset returns true if the game was replaced with a new game
+// before the current event, which is the new game's first.
+func (s *Stream) Reset() bool {
+	return s.reset
+}
+
+// Err returns the error that ended the stream.
+func (s *Stream) Err() error {
+	return s.err
+}
+
+// retryable returns true for errors that may not recur
+// if the request is retried.
+func retryable(err error) bool {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,115 @@
+package gameapi
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock provides the current time and timers to the handler, so that
+// tests can control the passage of time.
+//
+// Games themselves never consult a clock. Every change to a game
+// carries the time it was made, so that instances sharing games
+// through a broker agree on when things happened.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	Tick(d time.Duration) <-chan time.Time
+}
+
+// WithClock configures the handler to use the provided clock
+// instead of the system's clock.
+func WithClock(c Clock) Option {
+	return func(h *handler) {
+		h.clock = c
+	}
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (systemClock) Tick(d time.Duration) <-chan time.Time  { return time.Tick(d) }
+
+// FakeClock is a Clock whose time only moves when
+// it's advanced explicitly.
+type FakeClock struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	deadline time.Time
+	period   time.Duration // zero for timers that fire once
+	ch       chan time.Time
+}
+
+// NewFakeClock returns a FakeClock set to the provided time.
+func NewFakeClock(now time.Time) *FakeClock {
+	c := &FakeClock{now: now}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	return c.addTimer(d, 0)
+}
+
+func (c *FakeClock) Tick(d time.Duration) <-chan time.Time {
+	return c.addTimer(d, d)
+}
+
+func (c *FakeClock) addTimer(d, period time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{deadline: c.now.Add(d), period: period, ch: make(chan time.Time, 1)}
+	c.timers = append(c.timers, t)
+	c.cond.Broadcast()
+	return t.ch
+}
+
+// Advance moves the clock forward by d, firing any
+// timers that expire along the way.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+
+	remaining := c.timers[:0]
+	for _, t := range c.timers {
+		if t.deadline.After(c.now) {
+			remaining = append(remaining, t)
+			continue
+		}
+		// Like time.Ticker, drop ticks for slow receivers.
+		select {
+		case t.ch <- c.now:
+		default:
+		}
+		if t.period > 0 {
+			for !t.deadline.After(c.now) {
+				t.deadline = t.deadline.Add(t.period)
+			}
+			remaining = append(remaining, t)
+		}
+	}
+	c.timers = remaining
+}
+
+// BlockUntil waits until at least n timers and tickers
+// are waiting on the clock.
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.timers) < n {
+		c.cond.Wait()
+	}
+}
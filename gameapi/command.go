@@ -0,0 +1,455 @@
+package gameapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A command describes a change to a game. Rather than modifying
+// games directly, handlers publish commands to the broker, and every
+// instance applies them in the order the broker delivers them. That
+// keeps each instance's copy of a game identical, so any instance
+// can serve requests for any game.
+type command struct {
+	ID        string    `json:"id"`
+	Op        string    `json:"op"`
+	At        time.Time `json:"at"`
+	GameID    string    `json:"game_id"`
+	Seed      Seed      `json:"seed"`
+	Generator int       `json:"generator,omitempty"`
+	Words     []string  `json:"words,omitempty"`
+	WordList  string    `json:"word_list,omitempty"`
+	PlayerID  string    `json:"player_id,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	Team      int       `json:"team,omitempty"`
+	Index     int       `json:"index,omitempty"`
+	Message   string    `json:"message,omitempty"`
+	Risk      float64   `json:"risk,omitempty"`
+	Clues     []Clue    `json:"clues,omitempty"`
+	Scope     string    `json:"scope,omitempty"`
+	Players   []string  `json:"players,omitempty"`
+
+	// KeyHash is the hash of the player's key, and Session is true
+	// if they made the request with their account's session.
+	KeyHash []byte `json:"key_hash,omitempty"`
+	Session bool   `json:"session,omitempty"`
+
+	TargetID       string          `json:"target_id,omitempty"`
+	Mute           bool            `json:"mute,omitempty"`
+	RemoveMessages bool            `json:"remove_messages,omitempty"`
+	Settings       *SettingsChange `json:"settings,omitempty"`
+	Passcode       *passcode       `json:"passcode,omitempty"`
+	Public         bool            `json:"public,omitempty"`
+	RoomName       string          `json:"room_name,omitempty"`
+	NewTeam        *Team           `json:"new_team,omitempty"`
+}
+
+// The operations that a command may perform.
+const (
+	opNewGame = "new_game"
+	opSeen    = "seen"
+	opGuess   = "guess"
+	opEndTurn = "end_turn"
+	opChat    = "chat"
+	opPrune   = "prune"
+	opAddBot  = "add_bot"
+	opClue    = "clue"
+	opMute    = "mute"
+
+	// opPresence records that a player is still around. Unlike
+	// opSeen, it never changes the game, so brokers don't retain
+	// it. It fails with errNotPresent if the player would join,
+	// change sides or change names, which takes an opSeen.
+	opPresence = "presence"
+)
+
+// execTimeout bounds how long a request waits for
+// its command to make it through the broker.
+const execTimeout = 10 * time.Second
+
+// apiError is an error that's reported to the client.
+type apiError struct {
+	Code       string
+	Message    string
+	StatusCode int
+}
+
+func (e *apiError) Error() string { return e.Message }
+
+var (
+	errNotFound = &apiError{"not_found", "Game not found", 404}
+	errBadSeed  = &apiError{"bad_seed", "Request intended for a different game seed.", 400}
+
+	errNotPresent = errors.New("gameapi: the player needs to be seen")
+)
+
+// commandResult holds the outcome of applying a command.
+type commandResult struct {
+	resp interface{}
+	err  error
+}
+
+// exec publishes cmd and waits until this instance has applied it,
+// returning the result of applying it.
+func (h *handler) exec(ctx context.Context, cmd command) (interface{}, error) {
+	ch := make(chan commandResult, 1)
+	h.pendingMu.Lock()
+	h.lastID++
+	cmd.ID = fmt.Sprintf("%s-%d", h.instanceID, h.lastID)
+	h.pending[cmd.ID] = ch
+	h.pendingMu.Unlock()
+	cmd.KeyHash, cmd.Session = playerKey(ctx, cmd.PlayerID)
+	defer func() {
+		h.pendingMu.Lock()
+		delete(h.pending, cmd.ID)
+		h.pendingMu.Unlock()
+	}()
+
+	if err := h.publish(cmd); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, execTimeout)
+	defer cancel()
+	select {
+	case res := <-ch:
+		return res.resp, res.err
+	case <-ctx.Done():
+		return nil, &apiError{"unavailable", "Timed out waiting for the update to be applied.", 503}
+	}
+}
+
+// publish sends cmd to the broker without waiting
+// for it to be applied.
+func (h *handler) publish(cmd command) error {
+	if cmd.At.IsZero() {
+		cmd.At = h.clock.Now()
+	}
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		return err
+	}
+	err = h.broker.Publish(Message{
+		GameID:    cmd.GameID,
+		Reset:     cmd.Op == opNewGame,
+		Transient: cmd.Op == opPresence,
+		Data:      data,
+	})
+	if err != nil {
+		return &apiError{"unavailable", "Unable to publish the update: " + err.Error(), 503}
+	}
+	return nil
+}
+
+// seqTracker records the sequence number of the last message
+// received about each game. Brokers may deliver messages about
+// different games concurrently, so there's no single sequence to
+// follow. Games are forgotten once brokers stop retaining their
+// messages, since those messages won't be replayed.
+type seqTracker struct {
+	mu    sync.Mutex
+	games map[string]seqMark
+}
+
+type seqMark struct {
+	seq uint64
+	at  time.Time
+}
+
+// advance records seq as the latest message about the game. It
+// returns false if the message has already been received.
+func (t *seqTracker) advance(gameID string, seq uint64, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if seq <= t.games[gameID].seq {
+		return false
+	}
+	if t.games == nil {
+		t.games = make(map[string]seqMark)
+	}
+	t.games[gameID] = seqMark{seq: seq, at: now}
+	return true
+}
+
+// forget forgets the games that haven't had any messages
+// for longer than brokers retain them.
+func (t *seqTracker) forget(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id, m := range t.games {
+		if now.Sub(m.at) > brokerRetention {
+			delete(t.games, id)
+		}
+	}
+}
+
+// receive is subscribed to the broker. It applies each command
+// and hands the result to the request waiting on it, if any.
+func (h *handler) receive(m Message) {
+	// Brokers replay retained messages when an instance reconnects,
+	// some of which this instance will have already applied.
+	if !h.seq.advance(m.GameID, m.Seq, h.clock.Now()) {
+		return
+	}
+
+	var cmd command
+	if err := json.Unmarshal(m.Data, &cmd); err != nil {
+		log.Printf("gameapi: discarding malformed message %d: %s", m.Seq, err)
+		return
+	}
+	resp, err := h.apply(cmd)
+
+	h.pendingMu.Lock()
+	ch, ok := h.pending[cmd.ID]
+	h.pendingMu.Unlock()
+	if ok {
+		ch <- commandResult{resp: resp, err: err}
+	}
+}
+
+// apply applies a command to this instance's games. It must be
+// deterministic: every instance applies the same commands and
+// must arrive at the same state.
+func (h *handler) apply(cmd command) (interface{}, error) {
+	if cmd.Op == opNewGame {
+		// An instance running an older version may not
+		// know how to generate the new game's board.
+		if !SupportedGenerator(cmd.Generator) {
+			return nil, &apiError{"unsupported_generator",
+				fmt.Sprintf("Board generator version %d is not supported.", cmd.Generator), 500}
+		}
+		defer h.lobby.notify()
+		return h.applyNewGame(cmd)
+	}
+
+	if cmd.Op == opDelete {
+		defer h.lobby.notify()
+		return h.applyDelete(cmd)
+	}
+	if cmd.Op == opCreateTeam || cmd.Op == opDeleteTeam {
+		return h.applyTeam(cmd)
+	}
+
+	g, ok := h.games.get(cmd.GameID)
+	if !ok {
+		return nil, errNotFound
+	}
+	if cmd.Op == opPrune {
+		g.removePlayers(cmd.Players, cmd.At)
+		h.games.removeIfAbandoned(cmd.GameID, cmd.At)
+		h.lobby.notify()
+		return nil, nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if cmd.Seed != g.Seed {
+		return nil, errBadSeed
+	}
+
+	// Clients browsing the lobby are told when players join or
+	// leave and when games progress, but not about chat.
+	events := len(g.Events)
+	defer func() {
+		if cmd.Op != opChat && len(g.Events) != events {
+			h.lobby.notify()
+		}
+	}()
+
+	switch cmd.Op {
+	case opSeen, opPresence, opGuess, opEndTurn, opClue, opChat, opMute, opHostKick, opTransferHost, opSettings, opAddBot:
+		if g.kicked[cmd.PlayerID] {
+			return nil, errKicked
+		}
+		if err := g.checkSide(cmd.PlayerID, cmd.Team); err != nil {
+			return nil, err
+		}
+	}
+	switch cmd.Op {
+	case opMute, opHostKick, opTransferHost, opSettings:
+		if !g.isHost(cmd.PlayerID, cmd.KeyHash, cmd.Session) {
+			return nil, errNotHost
+		}
+	}
+	if _, ok := g.players[cmd.PlayerID]; !ok {
+		defer g.bindKey(cmd.PlayerID, cmd.KeyHash)
+	}
+	switch cmd.Op {
+	case opGuess, opEndTurn, opClue:
+		// Games ended by an admin can't be played any further.
+		if g.finished && g.status().Ended {
+			return nil, errGameFinished
+		}
+	}
+	switch cmd.Op {
+	case opClue, opAddBot:
+		// Practice games' clues are planned when they're created,
+		// and they're played alone.
+		if len(g.Practice) > 0 {
+			return nil, errPracticeGame
+		}
+	}
+
+	switch cmd.Op {
+	case opSeen:
+		g.markSeen(cmd.PlayerID, cmd.Name, cmd.Team, cmd.At)
+	case opPresence:
+		if !g.present(cmd.PlayerID, cmd.Name, cmd.Team, cmd.At) {
+			return nil, errNotPresent
+		}
+	case opGuess:
+		g.guess(cmd.PlayerID, cmd.Name, cmd.Team, cmd.Index, cmd.At)
+	case opEndTurn:
+		g.endTurn(cmd.PlayerID, cmd.Name, cmd.Team, cmd.At)
+	case opAddBot:
+		g.addBot(cmd.TargetID, cmd.Name, cmd.Team, cmd.Risk, cmd.At)
+	case opClue:
+		g.giveClue(cmd.PlayerID, cmd.Name, cmd.Team, cmd.Message, cmd.Index, cmd.At)
+	case opChat:
+		if err := g.chat(cmd.PlayerID, cmd.Name, cmd.Team, cmd.Message, cmd.Scope, cmd.At); err != nil {
+			return nil, err
+		}
+	case opMute:
+		g.markSeen(cmd.PlayerID, cmd.Name, cmd.Team, cmd.At)
+		if err := g.mute(cmd.PlayerID, cmd.Name, cmd.TargetID, cmd.Mute, cmd.RemoveMessages); err != nil {
+			return nil, err
+		}
+	case opHostKick:
+		g.markSeen(cmd.PlayerID, cmd.Name, cmd.Team, cmd.At)
+		if err := g.hostKick(cmd.PlayerID, cmd.Name, cmd.TargetID, cmd.At); err != nil {
+			return nil, err
+		}
+	case opTransferHost:
+		g.markSeen(cmd.PlayerID, cmd.Name, cmd.Team, cmd.At)
+		if err := g.transferHost(cmd.PlayerID, cmd.Name, cmd.TargetID); err != nil {
+			return nil, err
+		}
+	case opSettings:
+		g.markSeen(cmd.PlayerID, cmd.Name, cmd.Team, cmd.At)
+		if cmd.Settings == nil {
+			return nil, fmt.Errorf("settings command without settings")
+		}
+		return g.changeSettings(cmd.PlayerID, cmd.Name, *cmd.Settings, cmd.Passcode)
+	case opKick:
+		if err := g.kick(cmd.PlayerID, cmd.Name, cmd.At); err != nil {
+			return nil, err
+		}
+	case opPurgeChat:
+		return AdminPurgeResponse{Removed: g.removeMessages(cmd.PlayerID, cmd.Name)}, nil
+	case opEndGame:
+		if err := g.end(cmd.Name, cmd.At); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown op %q", cmd.Op)
+	}
+	return StatusResponse{Status: "ok"}, nil
+}
+
+// applyNewGame replaces any existing game with the ID with a new
+// game. Whether an existing game should be replaced is decided
+// before the command is published, so that every instance and
+// the broker's retained messages agree on the current game.
+func (h *handler) applyNewGame(cmd command) (json.RawMessage, error) {
+	game, err := ReconstructGame(newState(int64(cmd.Seed), cmd.Words, cmd.Generator))
+	if err != nil {
+		return nil, err
+	}
+	shard := h.games.shard(cmd.GameID)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	game.WordList = cmd.WordList
+	game.Practice = cmd.Clues
+	game.PracticeClues = len(cmd.Clues)
+	if oldGame, ok := shard.games[cmd.GameID]; ok {
+		oldGame.mu.Lock()
+		defer oldGame.mu.Unlock()
+
+		// Carry over the players but without teams in case
+		// they want to switch them up, unless the host has
+		// locked the teams. Bots don't come along; they're
+		// added to a side rather than joining one.
+		ids := make([]string, 0, len(oldGame.players))
+		for id, p := range oldGame.players {
+			if !p.Bot {
+				ids = append(ids, id)
+			}
+		}
+		sort.Strings(ids)
+		oldGame.stats.playersChanged(cmd.At, len(oldGame.players), len(ids))
+		for _, id := range ids {
+			p := oldGame.players[id]
+			if !oldGame.Settings.TeamsLocked || p.Team == 0 {
+				game.players[id] = Player{LastSeen: p.LastSeen, key: p.key}
+				continue
+			}
+			game.players[id] = Player{Team: p.Team, Name: p.Name, LastSeen: p.LastSeen, key: p.key}
+			game.addEvent(Event{
+				Type:     "join_side",
+				PlayerID: id,
+				Name:     p.Name,
+				Team:     p.Team,
+			})
+		}
+		for id := range oldGame.kicked {
+			game.kicked[id] = true
+		}
+		for id, muted := range oldGame.muted {
+			game.muted[id] = muted
+		}
+		game.Host, game.hostKey = oldGame.Host, oldGame.hostKey
+		game.Settings = oldGame.Settings
+		game.passcode = oldGame.passcode
+
+		// Wake up any clients waiting on this game, and stop
+		// recording stats for it now that its players have
+		// moved over to the new game.
+		oldGame.notifyAll()
+		oldGame.stats = nil
+		oldGame.history = nil
+	} else {
+		game.Settings.Public = cmd.Public
+		game.Settings.Name = cmd.RoomName
+	}
+
+	if game.Host == "" {
+		game.Host, game.hostKey = cmd.PlayerID, cmd.KeyHash
+	}
+	if game.passcode == nil && cmd.Passcode != nil {
+		game.passcode = cmd.Passcode
+		game.Settings.Private = true
+	}
+
+	g := &game
+	g.CreatedAt = cmd.At
+	g.id = cmd.GameID
+	g.stats = h.stats
+	g.history = h.history
+	if len(g.Practice) > 0 {
+		g.givePracticeClue(0)
+	}
+	shard.games[cmd.GameID] = g
+	h.stats.gameStarted(g.CreatedAt, cmd.WordList)
+
+	b, err := json.Marshal(g)
+	return b, err
+}
+
+func writeResult(rw http.ResponseWriter, resp interface{}, err error) {
+	if apiErr, ok := err.(*apiError); ok {
+		writeError(rw, apiErr.Code, apiErr.Message, apiErr.StatusCode)
+		return
+	} else if err != nil {
+		writeError(rw, "internal", err.Error(), 500)
+		return
+	}
+	writeJSON(rw, resp)
+}
@@ -0,0 +1,171 @@
+package gameapi
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSPolicy controls which web pages may make requests to the API
+// from another origin.
+type CORSPolicy struct {
+	// AllowedOrigins lists the origins that may make requests, like
+	// "https://www.codenamesgreen.com". An origin's host may start
+	// with a wildcard to allow all of its subdomains, as in
+	// "https://*.codenamesgreen.com". A lone "*" allows any origin,
+	// but only for requests without credentials.
+	AllowedOrigins []string
+	// AllowedHeaders lists the request headers that pages may send,
+	// besides those that browsers always allow.
+	AllowedHeaders []string
+	// AllowCredentials allows pages on the allowed origins to send
+	// cookies with their requests.
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache preflight responses.
+	MaxAge time.Duration
+}
+
+// DefaultCORSPolicy is used by handlers that aren't configured with
+// WithCORS. It only allows requests from the app's own site, without
+// credentials.
+var DefaultCORSPolicy = CORSPolicy{
+	AllowedOrigins: []string{"https://www.codenamesgreen.com"},
+	AllowedHeaders: []string{"Content-Type", "Authorization", AccessHeader, PlayerKeyHeader},
+	MaxAge:         20 * 24 * time.Hour,
+}
+
+// WithCORS configures the handler to allow cross-origin
+// requests according to the provided policy.
+func WithCORS(p CORSPolicy) Option {
+	return func(h *handler) {
+		h.cors = p
+	}
+}
+
+// allowOrigin returns the value of the Access-Control-Allow-Origin
+// header for a request from origin, or "" if the origin isn't allowed.
+func (p CORSPolicy) allowOrigin(origin string) string {
+	if origin == "" {
+		return ""
+	}
+	for _, allowed := range p.AllowedOrigins {
+		switch {
+		case allowed == "*" && !p.AllowCredentials:
+			return "*"
+		case allowed == "*":
+			// Credentialed requests from any origin would let every
+			// site on the web act on behalf of signed in players.
+			continue
+		case strings.EqualFold(allowed, origin), matchWildcardOrigin(allowed, origin):
+			return origin
+		}
+	}
+	return ""
+}
+
+// matchWildcardOrigin returns true if origin is a subdomain of a
+// pattern like "https://*.example.com". The scheme and any port
+// must match exactly.
+func matchWildcardOrigin(pattern, origin string) bool {
+	scheme, host, ok := strings.Cut(pattern, "://*.")
+	if !ok {
+		return false
+	}
+	prefix := scheme + "://"
+	if len(origin) <= len(prefix) || !strings.EqualFold(origin[:len(prefix)], prefix) {
+		return false
+	}
+	sub, ok := strings.CutSuffix(strings.ToLower(origin[len(prefix):]), "."+strings.ToLower(host))
+	return ok && sub != "" && !strings.ContainsAny(sub, "/:")
+}
+
+// varyOnOrigin returns true if the policy's responses
+// depend on the requesting origin.
+func (p CORSPolicy) varyOnOrigin() bool {
+	return p.AllowCredentials || len(p.AllowedOrigins) != 1 || p.AllowedOrigins[0] != "*"
+}
+
+// corsMethods are the methods that routes may be declared with.
+var corsMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+
+// handle registers a route with the mux. Patterns that don't name a
+// method match requests with any method, so methods declares the
+// ones that browsers should be told are allowed.
+func (h *handler) handle(pattern string, fn http.HandlerFunc, methods ...string) {
+	h.mux.HandleFunc(pattern, fn)
+	if len(methods) > 0 {
+		h.routeMethods[pattern] = methods
+	}
+	h.patterns = append(h.patterns, pattern)
+}
+
+// allowedMethods returns the methods that may be used with the
+// request's path.
+func (h *handler) allowedMethods(req *http.Request) []string {
+	var allowed []string
+	for _, m := range corsMethods {
+		r := req.Clone(req.Context())
+		r.Method = m
+		_, pattern := h.mux.Handler(r)
+		if pattern == "" {
+			continue
+		}
+		if declared, ok := h.routeMethods[pattern]; ok && !contains(declared, m) {
+			continue
+		}
+		allowed = append(allowed, m)
+	}
+	return allowed
+}
+
+// serveCORS sets the CORS headers on the response. It returns true
+// if the request was a preflight or other OPTIONS request that has
+// been answered.
+func (h *handler) serveCORS(rw http.ResponseWriter, req *http.Request) bool {
+	header := rw.Header()
+	if h.cors.varyOnOrigin() {
+		header.Add("Vary", "Origin")
+	}
+	origin := h.cors.allowOrigin(req.Header.Get("Origin"))
+	if origin != "" {
+		header.Set("Access-Control-Allow-Origin", origin)
+		if h.cors.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+	}
+	if req.Method != "OPTIONS" {
+		return false
+	}
+
+	methods := h.allowedMethods(req)
+	if len(methods) == 0 {
+		writeError(rw, "not_found", "No such route.", 404)
+		return true
+	}
+	header.Set("Allow", strings.Join(append(methods, "OPTIONS"), ", "))
+
+	requested := req.Header.Get("Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+	if origin != "" && requested != "" && contains(methods, requested) {
+		header.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+		if len(h.cors.AllowedHeaders) > 0 {
+			header.Set("Access-Control-Allow-Headers", strings.Join(h.cors.AllowedHeaders, ", "))
+		}
+		if h.cors.MaxAge > 0 {
+			header.Set("Access-Control-Max-Age", strconv.Itoa(int(h.cors.MaxAge/time.Second)))
+		}
+	}
+	rw.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package gameapi
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAllowOrigin(t *testing.T) {
+	p := CORSPolicy{AllowedOrigins: []string{"https://www.codenamesgreen.com", "https://*.example.com"}}
+	for origin, want := range map[string]string{
+		"https://www.codenamesgreen.com": "https://www.codenamesgreen.com",
+		"https://WWW.codenamesgreen.com": "https://WWW.codenamesgreen.com",
+		"http://www.codenamesgreen.com":  "",
+		"https://codenamesgreen.com":     "",
+		"https://a.example.com":          "https://a.example.com",
+		"https://a.b.example.com":        "https://a.b.example.com",
+		"https://example.com":            "",
+		"https://evilexample.com":        "",
+		"https://a.example.com:8443":     "",
+		"https://a.example.com.evil.com": "",
+		"http://a.example.com":           "",
+		"":                               "",
+	} {
+		if got := p.allowOrigin(origin); got != want {
+			t.Errorf("allowOrigin(%q) = %q, want %q", origin, got, want)
+		}
+	}
+
+	wildcard := CORSPolicy{AllowedOrigins: []string{"*"}}
+	if got := wildcard.allowOrigin("https://anywhere.com"); got != "*" {
+		t.Errorf("wildcard allowOrigin = %q, want *", got)
+	}
+	wildcard.AllowCredentials = true
+	if got := wildcard.allowOrigin("https://anywhere.com"); got != "" {
+		t.Errorf("credentialed wildcard allowOrigin = %q, want none", got)
+	}
+	if got := DefaultCORSPolicy.allowOrigin("https://anywhere.com"); got != "" {
+		t.Errorf("default allowOrigin = %q, want none", got)
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	h := newTestHandler(NewFakeClock(time.Now()))
+	h.cors = CORSPolicy{
+		AllowedOrigins:   []string{"https://www.codenamesgreen.com"},
+		AllowedHeaders:   []string{"Content-Type"},
+		AllowCredentials: true,
+		MaxAge:           time.Hour,
+	}
+
+	testCases := []struct {
+		path, origin, method string
+		code                 int
+		allowOrigin          string
+		allowMethods         string
+	}{
+		{"/guess", "https://www.codenamesgreen.com", "POST", 204, "https://www.codenamesgreen.com", "POST"},
+		{"/stats", "https://www.codenamesgreen.com", "GET", 204, "https://www.codenamesgreen.com", "GET"},
+		{"/games/x/practice", "https://www.codenamesgreen.com", "GET", 204, "https://www.codenamesgreen.com", "GET, HEAD"},
+		{"/games/x/bots", "https://www.codenamesgreen.com", "DELETE", 204, "https://www.codenamesgreen.com", ""},
+		{"/guess", "https://evil.com", "POST", 204, "", ""},
+		{"/nowhere", "https://www.codenamesgreen.com", "POST", 404, "https://www.codenamesgreen.com", ""},
+	}
+	for _, tc := range testCases {
+		req := httptest.NewRequest("OPTIONS", tc.path, nil)
+		req.Header.Set("Origin", tc.origin)
+		req.Header.Set("Access-Control-Request-Method", tc.method)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		header := rec.Header()
+		if rec.Code != tc.code {
+			t.Errorf("%s %s: status = %d, want %d", tc.method, tc.path, rec.Code, tc.code)
+		}
+		if got := header.Get("Access-Control-Allow-Origin"); got != tc.allowOrigin {
+			t.Errorf("%s %s: allowed origin = %q, want %q", tc.method, tc.path, got, tc.allowOrigin)
+		}
+		if got := header.Get("Access-Control-Allow-Methods"); got != tc.allowMethods {
+			t.Errorf("%s %s: allowed methods = %q, want %q", tc.method, tc.path, got, tc.allowMethods)
+		}
+		if got := header.Values("Vary"); len(got) == 0 || got[0] != "Origin" {
+			t.Errorf("%s %s: Vary = %q, want Origin first", tc.method, tc.path, got)
+		}
+		if tc.allowMethods != "" {
+			if header.Get("Access-Control-Allow-Credentials") != "true" || header.Get("Access-Control-Max-Age") != "3600" {
+				t.Errorf("%s %s: headers = %v", tc.method, tc.path, header)
+			}
+		}
+	}
+
+	// Simple requests get the origin headers too.
+	req := httptest.NewRequest("POST", "/index", nil)
+	req.Header.Set("Origin", "https://www.codenamesgreen.com")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://www.codenamesgreen.com" {
+		t.Errorf("allowed origin = %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Methods"); got != "" {
+		t.Errorf("allowed methods on a simple request = %q, want none", got)
+	}
+}
+
+func TestDefaultPreflight(t *testing.T) {
+	h := newTestHandler(NewFakeClock(time.Now()))
+
+	// The web app sends these headers with its requests.
+	req := httptest.NewRequest("OPTIONS", "/v2/games/x/guesses", nil)
+	req.Header.Set("Origin", "https://www.codenamesgreen.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	req.Header.Set("Access-Control-Request-Headers", "authorization,content-type,x-player-key,x-room-access")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	allowed := map[string]bool{}
+	for _, name := range strings.Split(rec.Header().Get("Access-Control-Allow-Headers"), ",") {
+		allowed[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+	for _, name := range strings.Split(req.Header.Get("Access-Control-Request-Headers"), ",") {
+		if !allowed[name] {
+			t.Errorf("allowed headers = %q, missing %s", rec.Header().Get("Access-Control-Allow-Headers"), name)
+		}
+	}
+}
@@ -2,7 +2,8 @@ package gameapi
 
 import (
 	"encoding/json"
-	"math/rand"
+	"fmt"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
@@ -31,6 +32,24 @@ func (c Color) MarshalJSON() ([]byte, error) {
 	return json.Marshal(c.String())
 }
 
+func (c *Color) UnmarshalJSON(b []byte) error {
+	var str string
+	if err := json.Unmarshal(b, &str); err != nil {
+		return err
+	}
+	switch str {
+	case "t":
+		*c = Tan
+	case "g":
+		*c = Green
+	case "b":
+		*c = Black
+	default:
+		return fmt.Errorf("unknown color %q", str)
+	}
+	return nil
+}
+
 // Seed wraps an int64 with a custom JSON marshaller to marshal
 // it as a string. We use the full 64-bit range, but Javascript
 // Numbers aren't capable of representing the full range of 64-bit
@@ -55,12 +74,43 @@ func (s Seed) MarshalJSON() ([]byte, error) {
 // a Game's state. It's used to recreate games after
 // a process restart.
 type GameState struct {
-	mu      sync.Mutex        `json:"-"`
-	changed chan struct{}     `json:"-"`
-	players map[string]Player `json:"-"`
-	Seed    Seed              `json:"seed"`
-	Events  []Event           `json:"events"`
-	WordSet []string          `json:"word_set"`
+	mu       sync.Mutex        `json:"-"`
+	changed  chan struct{}     `json:"-"`
+	players  map[string]Player `json:"-"`
+	kicked   map[string]bool   `json:"-"` // players removed by an admin
+	muted    map[string]bool   `json:"-"` // players muted by the host
+	passcode *passcode         `json:"-"` // set for private games
+	hostKey  []byte            `json:"-"` // the hash of the host's player key
+	Seed     Seed              `json:"seed"`
+	Events   []Event           `json:"events"`
+	WordSet  []string          `json:"word_set"`
+
+	// GeneratorVersion identifies the algorithm used to turn
+	// the seed into a board. States saved before the version
+	// was recorded use version 0.
+	GeneratorVersion int `json:"generator_version"`
+
+	// WordList names the word list that the game's words came
+	// from: one of the handler's lists, "default" for all of
+	// them or "custom" for words chosen by the game's creator.
+	WordList string `json:"word_list,omitempty"`
+
+	// Practice holds the clues planned for a practice game, and
+	// is empty for other games. The plan would give the answers
+	// away, so it's only sent to instances, with the command that
+	// creates the game; players only learn how many clues there
+	// are, and the clues themselves as they're given.
+	Practice      []Clue `json:"-"`
+	PracticeClues int    `json:"practice_clues,omitempty"`
+
+	// Host is the ID of the player who moderates the game: the
+	// player who created it, or the first player seen if no one
+	// did. When the host leaves, another player takes over.
+	Host string `json:"host,omitempty"`
+
+	// Settings are chosen by the host, and carry
+	// over to the games that replace this one.
+	Settings Settings `json:"settings"`
 }
 
 type Event struct {
@@ -71,21 +121,47 @@ type Event struct {
 	Team     int    `json:"team"`
 	Index    int    `json:"index"`
 	Message  string `json:"message"`
+
+	// Scope is ScopeSide for events that only players on the
+	// event's Team may see.
+	Scope string `json:"scope,omitempty"`
+}
+
+// visibleTo returns true if players on the provided team may
+// see the event.
+func (e Event) visibleTo(team int) bool {
+	return e.Scope != ScopeSide || e.Team == team
 }
 
 type Player struct {
 	Team     int       `json:"team"`
 	Name     string    `json:"name"`
 	LastSeen time.Time `json:"last_seen"`
+	Bot      bool      `json:"bot,omitempty"`
+	Risk     float64   `json:"risk,omitempty"` // bots only
+
+	key []byte // the hash of the player's key
 }
 
 func NewState(seed int64, words []string) GameState {
+	return newState(seed, words, CurrentGenerator)
+}
+
+// newState returns the state of a new game whose board is generated
+// by the provided version of the board generator. It's built in place,
+// rather than by changing a copy of NewState's result, because states
+// hold a mutex and mustn't be copied.
+func newState(seed int64, words []string, generator int) GameState {
 	return GameState{
 		changed: make(chan struct{}),
 		players: make(map[string]Player),
+		kicked:  make(map[string]bool),
+		muted:   make(map[string]bool),
 		Seed:    Seed(seed),
 		Events:  []Event{},
 		WordSet: words,
+
+		GeneratorVersion: generator,
 	}
 }
 
@@ -95,6 +171,11 @@ type Game struct {
 	Words     []string  `json:"words"`
 	OneLayout []Color   `json:"one_layout"`
 	TwoLayout []Color   `json:"two_layout"`
+
+	id       string // set when the game is created by a command
+	stats    *statsRecorder
+	history  *GameHistory
+	finished bool
 }
 
 func (gs *GameState) notifyAll() {
@@ -112,17 +193,40 @@ func (gs *GameState) addEvent(evt Event) {
 	gs.changed = make(chan struct{})
 }
 
-func (gs *GameState) eventsSince(lastSeen int) (evts []Event, next chan struct{}) {
+// eventsSince returns the events after lastSeen that players on
+// the provided team may see, and a channel that's closed when
+// there are new events.
+func (gs *GameState) eventsSince(lastSeen, team int) (evts []Event, next chan struct{}) {
 	evts = []Event{}
 	for _, e := range gs.Events {
-		if e.Number > lastSeen {
+		if e.Number > lastSeen && e.visibleTo(team) {
 			evts = append(evts, e)
 		}
 	}
 	return evts, gs.changed
 }
 
+// view returns the game as it's shown to players on the provided
+// team, without the events that are private to the other team. It
+// must be marshaled while the caller holds g.mu.
+func (g *Game) view(team int) interface{} {
+	evts, _ := g.eventsSince(0, team)
+	return struct {
+		*Game
+		State gameStateView `json:"state"`
+	}{g, gameStateView{&g.GameState, evts}}
+}
+
+// gameStateView is a GameState with some of its events.
+type gameStateView struct {
+	*GameState
+	Events []Event `json:"events"`
+}
+
 func (g *Game) markSeen(playerID, name string, team int, when time.Time) {
+	if g.Host == "" {
+		g.Host, g.hostKey = playerID, g.players[playerID].key
+	}
 	p, ok := g.players[playerID]
 	if ok {
 		p.LastSeen = when
@@ -149,6 +253,7 @@ func (g *Game) markSeen(playerID, name string, team int, when time.Time) {
 	}
 
 	g.players[playerID] = Player{Team: team, Name: name, LastSeen: when}
+	g.stats.playersChanged(when, len(g.players)-1, len(g.players))
 	if team != 0 {
 		g.addEvent(Event{
 			Type:     "join_side",
@@ -159,6 +264,49 @@ func (g *Game) markSeen(playerID, name string, team int, when time.Time) {
 	}
 }
 
+// present records when a player was last seen, like markSeen, but
+// only if that's all that markSeen would change. It returns false,
+// without changing anything, if the player would join the game or
+// change sides or names, or become the host.
+func (g *Game) present(playerID, name string, team int, when time.Time) bool {
+	p, ok := g.players[playerID]
+	if !ok || g.Host == "" || (team != 0 && p.Team != team) || (name != p.Name && p.Name != "") {
+		return false
+	}
+	if when.After(p.LastSeen) {
+		p.LastSeen = when
+		g.players[playerID] = p
+	}
+	return true
+}
+
+// addBot adds a bot player to the provided team.
+func (g *Game) addBot(playerID, name string, team int, risk float64, when time.Time) {
+	g.players[playerID] = Player{Team: team, Name: name, LastSeen: when, Bot: true, Risk: risk}
+	g.stats.playersChanged(when, len(g.players)-1, len(g.players))
+	g.addEvent(Event{
+		Type:     "join_side",
+		PlayerID: playerID,
+		Name:     name,
+		Team:     team,
+	})
+}
+
+// giveClue records a clue given by a player on the provided team,
+// for the other team to guess. The clue's word is recorded in the
+// event's Message and its number in the event's Index.
+func (g *Game) giveClue(playerID, name string, team int, word string, number int, when time.Time) {
+	g.markSeen(playerID, name, team, when)
+	g.addEvent(Event{
+		Type:     "clue",
+		Team:     team,
+		PlayerID: playerID,
+		Name:     name,
+		Index:    number,
+		Message:  word,
+	})
+}
+
 func (g *Game) guess(playerID, name string, team, index int, when time.Time) {
 	g.markSeen(playerID, name, team, when)
 
@@ -178,56 +326,149 @@ func (g *Game) guess(playerID, name string, team, index int, when time.Time) {
 		PlayerID: playerID,
 		Name:     name,
 	})
+	g.checkFinished(when)
+	g.giveNextClue()
+}
+
+func (g *Game) endTurn(playerID, name string, team int, when time.Time) {
+	g.markSeen(playerID, name, team, when)
+	g.addEvent(Event{
+		Type:     "end_turn",
+		Team:     team,
+		PlayerID: playerID,
+		Name:     name,
+	})
+	g.checkFinished(when)
+	g.giveNextClue()
+}
+
+// checkFinished records the game's outcome the first
+// time that it's won or lost.
+func (g *Game) checkFinished(when time.Time) {
+	if g.finished {
+		return
+	}
+	if st := g.status(); st.Finished() {
+		g.finished = true
+		g.stats.gameFinished(when, st)
+		if !st.Ended {
+			g.history.record(g.result(when, st))
+		}
+	}
 }
 
-func (g *Game) pruneOldPlayers(now time.Time) (remaining int) {
+// removePlayers removes players that have gone away, as decided by
+// the instance that published the prune command: brokers don't
+// retain presence, so instances that subscribe late don't know
+// when every player was last seen.
+func (g *Game) removePlayers(ids []string, now time.Time) (remaining int) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
-	for id, player := range g.players {
-		if player.LastSeen.Add(50 * time.Second).Before(now) {
-			delete(g.players, id)
-			if player.Team != 0 {
-				g.addEvent(Event{
-					Type:     "player_left",
-					PlayerID: id,
-					Name:     player.Name,
-					Team:     player.Team,
-				})
-			}
+	before := len(g.players)
+	for _, id := range ids {
+		player, ok := g.players[id]
+		if !ok {
 			continue
 		}
+		delete(g.players, id)
+		if player.Team != 0 {
+			g.addEvent(Event{
+				Type:     "player_left",
+				PlayerID: id,
+				Name:     player.Name,
+				Team:     player.Team,
+			})
+		}
+	}
+	if g.hostGone(now) {
+		g.passHost()
 	}
+	g.stats.playersChanged(now, before, len(g.players))
 	return len(g.players)
 }
 
-func ReconstructGame(state GameState) (g Game) {
+// departed returns the IDs of the players that have gone away, in a
+// consistent order, and whether the game needs pruning: whether any
+// players have gone, or its host has, or the game itself has been
+// abandoned.
+func (g *Game) departed(now time.Time) (ids []string, prune bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	humans := g.hasHumans(now)
+	for id, player := range g.players {
+		if player.gone(now, humans) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, len(ids) > 0 || g.hostGone(now) || g.abandoned(now)
+}
+
+// hasHumans returns true if any of the game's players are
+// people who have been seen recently. The caller must hold g.mu.
+func (g *Game) hasHumans(now time.Time) bool {
+	for _, player := range g.players {
+		if !player.Bot && !player.gone(now, true) {
+			return true
+		}
+	}
+	return false
+}
+
+// playerTimeout is how long players stay in a
+// game after they were last seen.
+const playerTimeout = 50 * time.Second
+
+// gone returns true if the player should be removed from the game.
+// Bots are never seen, so they stay for as long as there are
+// humans to play with.
+func (p Player) gone(now time.Time, humans bool) bool {
+	if p.Bot {
+		return !humans
+	}
+	return p.LastSeen.Add(playerTimeout).Before(now)
+}
+
+// abandoned returns true if the game has no players and it's been
+// more than 24 hours since it started. The caller must hold g.mu.
+func (g *Game) abandoned(now time.Time) bool {
+	return len(g.players) == 0 && g.CreatedAt.Add(24*time.Hour).Before(now)
+}
+
+// ReconstructGame rebuilds a game's board from its state. It returns
+// an error if the state's GeneratorVersion isn't supported by this
+// package (see SupportedGenerator), or if its word set has too few
+// distinct words for a board.
+func ReconstructGame(state GameState) (g Game, err error) {
+	// Games contain a mutex, so they're returned without naming
+	// g, which vet would see as a copy.
+	gen, ok := generators[state.GeneratorVersion]
+	if !ok {
+		err = fmt.Errorf("gameapi: unsupported board generator version %d", state.GeneratorVersion)
+		return
+	}
+	if n := len(uniqueWords(state.WordSet)); n < len(colorDistribution) {
+		err = fmt.Errorf("gameapi: a board needs %d distinct words, but the word set has %d", len(colorDistribution), n)
+		return
+	}
+
 	g = Game{
 		GameState: state,
 		OneLayout: make([]Color, len(colorDistribution)),
 		TwoLayout: make([]Color, len(colorDistribution)),
 	}
-
-	rnd := rand.New(rand.NewSource(int64(state.Seed)))
-
-	// Pick 25 random words.
-	used := make(map[string]bool, len(colorDistribution))
-	for len(used) < len(colorDistribution) {
-		w := state.WordSet[rnd.Intn(len(state.WordSet))]
-		if !used[w] {
-			g.Words = append(g.Words, w)
-			used[w] = true
-		}
-	}
+	var perm []int
+	g.Words, perm = gen(int64(state.Seed), state.WordSet)
 
 	// Assign the colors for each team, according to the
 	// relative distribution in the rule book.
-	perm := rnd.Perm(len(colorDistribution))
 	for i, colors := range colorDistribution {
 		g.OneLayout[perm[i]] = colors[0]
 		g.TwoLayout[perm[i]] = colors[1]
 	}
-	return g
+	return
 }
 
 var colorDistribution = [25][2]Color{
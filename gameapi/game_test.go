@@ -9,9 +9,61 @@ var exampleWords = []string{"AFRICA", "AGENT", "AIR", "ALIEN", "ALPS", "AMAZON",
 
 func TestConstructGame(t *testing.T) {
 	state := NewState(0, exampleWords)
-	game := ReconstructGame(state)
+	game, err := ReconstructGame(state)
+	if err != nil {
+		t.Fatal(err)
+	}
 	game.markSeen("alice", "alice", 1, time.Now())
 	if len(game.players) != 1 {
 		t.Errorf("len(game.players) = %d, want %d", len(game.players), 1)
 	}
 }
+
+func TestReconstructGameErrors(t *testing.T) {
+	// Saved states come from outside, so they mustn't
+	// be able to crash the server.
+	unknown := NewState(0, exampleWords)
+	unknown.GeneratorVersion = 99
+	if _, err := ReconstructGame(unknown); err == nil {
+		t.Error("ReconstructGame with an unknown generator version: err = nil, want an error")
+	}
+	if _, err := ReconstructGame(NewState(0, exampleWords[:24])); err == nil {
+		t.Error("ReconstructGame with 24 words: err = nil, want an error")
+	}
+}
+
+func TestStatus(t *testing.T) {
+	game, err := ReconstructGame(NewState(0, exampleWords))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := game.status(); st.Turn != 0 || st.GreensLeft != 15 || st.Finished() {
+		t.Fatalf("initial status = %+v", st)
+	}
+
+	// Side A guesses a card that's green on side B's key.
+	var green, black int
+	for i, c := range game.TwoLayout {
+		if c == Green {
+			green = i
+		}
+		if c == Black {
+			black = i
+		}
+	}
+	game.guess("alice", "alice", 1, green, time.Now())
+	if st := game.status(); st.Turn != 1 || st.GreensLeft != 14 || st.Finished() {
+		t.Errorf("status after green guess = %+v", st)
+	}
+
+	game.endTurn("alice", "alice", 1, time.Now())
+	if st := game.status(); st.Turn != 2 || st.TokensConsumed != 1 {
+		t.Errorf("status after end turn = %+v", st)
+	}
+
+	// It's no longer side A's turn, so this guess is ignored.
+	game.guess("alice", "alice", 1, black, time.Now())
+	if st := game.status(); st.Lost {
+		t.Errorf("status after out-of-turn guess = %+v", st)
+	}
+}
@@ -0,0 +1,110 @@
+package gameapi
+
+import "math/rand"
+
+// CurrentGenerator is the version of the board generator
+// used for new games.
+const CurrentGenerator = 1
+
+// A generator turns a seed and a set of words into a board. It
+// returns the 25 words on the board, and a permutation used to
+// assign each card in colorDistribution to a position on the board.
+//
+// A generator must never change once games have been created with
+// it, or saved games would be rebuilt with different boards. Changes
+// to board generation require a new version.
+type generator func(seed int64, wordSet []string) (words []string, perm []int)
+
+var generators = map[int]generator{
+	0: generateV0,
+	1: generateV1,
+}
+
+// SupportedGenerator returns true if boards generated by the
+// provided generator version can be rebuilt.
+func SupportedGenerator(version int) bool {
+	_, ok := generators[version]
+	return ok
+}
+
+// generateV0 generates boards using math/rand. It depends on the
+// exact sequence produced by math/rand's default source, and is
+// only kept to rebuild games created before boards were versioned.
+func generateV0(seed int64, wordSet []string) (words []string, perm []int) {
+	rnd := rand.New(rand.NewSource(seed))
+
+	// Pick 25 random words.
+	used := make(map[string]bool, len(colorDistribution))
+	for len(used) < len(colorDistribution) {
+		w := wordSet[rnd.Intn(len(wordSet))]
+		if !used[w] {
+			words = append(words, w)
+			used[w] = true
+		}
+	}
+	return words, rnd.Perm(len(colorDistribution))
+}
+
+// generateV1 generates boards using a self-contained splitmix64
+// generator and Fisher-Yates shuffles, so that boards don't depend
+// on the behavior of any other package.
+func generateV1(seed int64, wordSet []string) (words []string, perm []int) {
+	rnd := splitMix64(seed)
+
+	// Pick 25 distinct words by shuffling the front of
+	// the deduplicated word set.
+	words = uniqueWords(wordSet)
+	for i := 0; i < len(colorDistribution); i++ {
+		j := i + rnd.intn(len(words)-i)
+		words[i], words[j] = words[j], words[i]
+	}
+	words = words[:len(colorDistribution):len(colorDistribution)]
+
+	perm = make([]int, len(colorDistribution))
+	for i := range perm {
+		perm[i] = i
+	}
+	for i := len(perm) - 1; i > 0; i-- {
+		j := rnd.intn(i + 1)
+		perm[i], perm[j] = perm[j], perm[i]
+	}
+	return words, perm
+}
+
+// uniqueWords returns a copy of words with duplicates removed,
+// preserving the order of each word's first occurrence.
+func uniqueWords(words []string) []string {
+	seen := make(map[string]bool, len(words))
+	unique := make([]string, 0, len(words))
+	for _, w := range words {
+		if !seen[w] {
+			unique = append(unique, w)
+			seen[w] = true
+		}
+	}
+	return unique
+}
+
+// splitMix64 is the SplitMix64 pseudorandom number generator.
+// See https://prng.di.unimi.it/splitmix64.c.
+type splitMix64 uint64
+
+func (s *splitMix64) next() uint64 {
+	*s += 0x9e3779b97f4a7c15
+	z := uint64(*s)
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// intn returns a uniformly distributed integer in [0, n).
+func (s *splitMix64) intn(n int) int {
+	// Reject values below 2^64 mod n so that the
+	// remaining range divides evenly by n.
+	threshold := -uint64(n) % uint64(n)
+	for {
+		if v := s.next(); v >= threshold {
+			return int(v % uint64(n))
+		}
+	}
+}
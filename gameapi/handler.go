@@ -1,12 +1,14 @@
 package gameapi
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"math/rand"
 	"net/http"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -14,14 +16,51 @@ import (
 	"github.com/jbowens/dictionary"
 )
 
+// An Option configures optional behavior of a Handler.
+type Option func(*handler)
+
+// WithBroker configures the handler to share games with other
+// instances through the provided broker. By default, games are
+// only visible to the handler that created them.
+func WithBroker(b Broker) Option {
+	return func(h *handler) {
+		h.broker = b
+	}
+}
+
 // Handler implements the codenames green server handler.
-func Handler(wordLists map[string][]string) http.Handler {
+func Handler(wordLists map[string][]string, opts ...Option) http.Handler {
 	h := &handler{
 		mux:       http.NewServeMux(),
 		wordLists: wordLists,
 		rand:      rand.New(rand.NewSource(time.Now().UnixNano())),
-		games:     make(map[string]*Game),
+		games:     newRegistry(),
+		stats:     newStatsRecorder(),
+		history:   newGameHistory(),
+		pending:   make(map[string]chan commandResult),
+		cors:      DefaultCORSPolicy,
+		limits:    DefaultRateLimits,
+
+		maxMessageLength: DefaultMaxMessageLength,
+		limiter:          newLimiter(),
+		lobby:            newLobby(),
+		matchmaker:       newMatchmaker(),
+
+		routeMethods: make(map[string][]string),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.broker == nil {
+		h.broker = NewLocalBroker()
+	}
+	if h.clock == nil {
+		h.clock = systemClock{}
 	}
+	if len(h.inviteKey) == 0 {
+		h.inviteKey = randomKey()
+	}
+	h.instanceID = strconv.FormatInt(h.rand.Int63(), 36)
 
 	// Build a list of all words. The combined list
 	// of words is our default word list for new games,
@@ -37,30 +76,42 @@ func Handler(wordLists map[string][]string) http.Handler {
 	}
 	sort.Strings(h.allWords)
 
-	h.mux.HandleFunc("/index", h.handleIndex)
-	h.mux.HandleFunc("/new-game", h.handleNewGame)
-	h.mux.HandleFunc("/guess", h.handleGuess)
-	h.mux.HandleFunc("/end-turn", h.handleEndTurn)
-	h.mux.HandleFunc("/chat", h.handleChat)
-	h.mux.HandleFunc("/events", h.handleEvents)
-	h.mux.HandleFunc("/ping", h.handlePing)
-	h.mux.HandleFunc("/stats", h.handleStats)
-
-	// Periodically remove games that are old and inactive.
+	h.handle("/index", h.handleIndex, "POST")
+	h.handle("/new-game", h.handleNewGame, "POST")
+	h.handle("/guess", h.handleGuess, "POST")
+	h.handle("/end-turn", h.handleEndTurn, "POST")
+	h.handle("/chat", h.handleChat, "POST")
+	h.handle("/events", h.handleEvents, "POST")
+	h.handle("/ping", h.handlePing, "POST")
+	h.handle("/stats", h.handleStats, "GET")
+	h.handle("/clue", h.handleClue, "POST")
+	h.handle("POST /games/{id}/suggest-clue", h.handleSuggestClue)
+	h.handle("POST /games/{id}/bots", h.handleAddBot)
+	h.handle("GET /games/{id}/practice", h.handlePracticeResults)
+	h.registerV2()
+	h.registerAdmin()
+	h.handle("GET /lobby", h.handleLobby)
+	h.handle("POST /matchmaking/join", h.handleMatchmaking)
+	h.handle("GET /players/{id}/stats", h.handlePlayerStats)
+	h.registerTeams()
+	h.registerAccounts()
+	h.handle("GET /openapi.json", h.handleOpenAPI)
+
+	h.broker.Subscribe(h.receive)
+
+	// Periodically remove players that have gone away and games
+	// that are old and inactive. Every instance sweeps its games,
+	// but pruning is idempotent so it doesn't matter which
+	// instance's commands are applied first.
 	go func() {
-		for now := range time.Tick(10 * time.Minute) {
-			h.mu.Lock()
-			for id, g := range h.games {
-				remaining := g.pruneOldPlayers(now)
-				if remaining > 0 {
-					continue // at least one player is still in the game
-				}
-				if g.CreatedAt.Add(24 * time.Hour).After(time.Now()) {
-					continue // hasn't been 24 hours since the game started
+		for now := range h.clock.Tick(10 * time.Minute) {
+			h.limiter.sweep(now)
+			h.seq.forget(now)
+			h.games.each(func(id string, g *Game) {
+				if ids, ok := g.departed(now); ok {
+					h.publish(command{Op: opPrune, At: now, GameID: id, Players: ids})
 				}
-				delete(h.games, id)
-			}
-			h.mu.Unlock()
+			})
 		}
 	}()
 
@@ -68,25 +119,57 @@ func Handler(wordLists map[string][]string) http.Handler {
 }
 
 type handler struct {
-	mux       *http.ServeMux
-	wordLists map[string][]string
-	allWords  []string
-	rand      *rand.Rand
-
-	mu    sync.Mutex
-	games map[string]*Game
+	mux        *http.ServeMux
+	wordLists  map[string][]string
+	allWords   []string
+	stats      *statsRecorder
+	history    *GameHistory
+	games      *registry
+	broker     Broker
+	clock      Clock
+	spymaster  Spymaster
+	guesser    Guesser
+	cors       CORSPolicy
+	limits     RateLimits
+	limiter    *limiter
+	lobby      *lobby
+	matchmaker *matchmaker
+	accounts   *AccountDB
+
+	adminToken       string
+	maxMessageLength int
+	chatFilter       ChatFilter
+	inviteKey        []byte
+
+	// routeMethods holds the methods declared for routes
+	// whose patterns match any method.
+	routeMethods map[string][]string
+	patterns     []string // every route's pattern
+
+	// instanceID distinguishes this instance's commands from other
+	// instances' commands, and seq tracks the messages received
+	// from the broker.
+	instanceID string
+	seq        seqTracker
+
+	pendingMu sync.Mutex
+	lastID    int
+	pending   map[string]chan commandResult
+
+	mu   sync.Mutex // protects rand
+	rand *rand.Rand
 }
 
 func (h *handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
-	// Allow all cross-origin requests.
-	header := rw.Header()
-	header.Set("Access-Control-Allow-Origin", "*")
-	header.Set("Access-Control-Allow-Methods", "*")
-	header.Set("Access-Control-Allow-Headers", "Content-Type")
-	header.Set("Access-Control-Max-Age", "1728000") // 20 days
-
-	if req.Method == "OPTIONS" {
-		rw.WriteHeader(http.StatusOK)
+	if h.serveCORS(rw, req) || h.methodNotAllowed(rw, req) || h.limit(rw, req) {
+		return
+	}
+	req, answered := h.authenticate(rw, req)
+	if answered {
+		return
+	}
+	req, answered = readPlayerKey(rw, req)
+	if answered || h.checkAccess(rw, req) {
 		return
 	}
 	h.mux.ServeHTTP(rw, req)
@@ -94,271 +177,240 @@ func (h *handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 
 // POST /index
 func (h *handler) handleIndex(rw http.ResponseWriter, req *http.Request) {
-	// Autogenerate a game ID from the set of words that we know about, skipping
-	// any that already have games in-memory.
-	id := ""
-	h.mu.Lock()
+	writeJSON(rw, IndexResponse{AutogeneratedID: h.newGameID()})
+}
+
+// newGameID autogenerates a game ID from the set of words that we
+// know about, skipping any that already have games in-memory.
+func (h *handler) newGameID() string {
 	for {
+		h.mu.Lock()
 		w1 := strings.ToLower(h.allWords[h.rand.Int63n(int64(len(h.allWords)))])
 		w2 := strings.ToLower(h.allWords[h.rand.Int63n(int64(len(h.allWords)))])
+		h.mu.Unlock()
 		id := fmt.Sprintf("%s-%s", w1, w2)
-		if _, ok := h.games[id]; !ok {
-			break
+		if _, ok := h.games.get(id); !ok {
+			return id
 		}
 	}
-	h.mu.Unlock()
-
-	writeJSON(rw, struct {
-		AutogeneratedID string `json:"autogenerated_id"`
-	}{id})
 }
 
 // POST /new-game
 func (h *handler) handleNewGame(rw http.ResponseWriter, req *http.Request) {
-	var body struct {
-		GameID   string   `json:"game_id"`
-		Words    []string `json:"words,omitempty"`
-		PrevSeed *Seed    `json:"prev_seed,omitempty"` // a string because of js number precision
-	}
+	var body NewGameRequest
 	err := json.NewDecoder(req.Body).Decode(&body)
 	if err != nil || body.GameID == "" {
 		writeError(rw, "malformed_body", "Unable to parse request body.", 400)
 		return
 	}
 
-	h.mu.Lock()
-	defer h.mu.Unlock()
-
 	// If the game already exists, make sure that the request includes
 	// the existing game's seed so a delayed request doesn't reset an
 	// existing game.
-	oldGame, ok := h.games[body.GameID]
-	if ok {
+	if oldGame, ok := h.games.get(body.GameID); ok {
 		oldGame.mu.Lock()
-		defer oldGame.mu.Unlock()
-	}
-	if ok && (body.PrevSeed == nil || *body.PrevSeed != oldGame.Seed) {
-		writeJSON(rw, oldGame)
-		return
+		if body.PrevSeed == nil || *body.PrevSeed != oldGame.Seed {
+			key, session := playerKey(req.Context(), body.PlayerID)
+			writeJSON(rw, oldGame.view(oldGame.sideOf(body.PlayerID, key, session)))
+			oldGame.mu.Unlock()
+			return
+		}
+		key, session := playerKey(req.Context(), body.PlayerID)
+		err := oldGame.checkReplace(&body, key, session)
+		oldGame.mu.Unlock()
+		if err != nil {
+			writeResult(rw, nil, err)
+			return
+		}
 	}
 
-	words := body.Words
+	resp, err := h.createGame(req.Context(), body)
+	writeResult(rw, resp, err)
+}
+
+// createGame creates a new game, replacing any existing game with
+// the same ID. It returns the new game.
+func (h *handler) createGame(ctx context.Context, body NewGameRequest) (interface{}, error) {
+	words, wordList := body.Words, "custom"
+	if len(words) == 0 && body.WordList != "" {
+		list, ok := h.wordLists[body.WordList]
+		if !ok {
+			return nil, &apiError{"unknown_word_list", "No word list named " + body.WordList + ".", 400}
+		}
+		words, wordList = list, body.WordList
+	}
 	if len(words) == 0 {
-		words = h.allWords
+		words, wordList = h.allWords, "default"
 	}
-	if len(words) < len(colorDistribution) {
-		writeError(rw, "too_few_words",
-			fmt.Sprintf("A word list must have at least %d words.", len(colorDistribution)), 400)
-		return
+	if len(uniqueWords(words)) < len(colorDistribution) {
+		return nil, &apiError{"too_few_words",
+			fmt.Sprintf("A word list must have at least %d distinct words.", len(colorDistribution)), 400}
 	}
 
-	game := ReconstructGame(NewState(h.rand.Int63(), words))
-	if oldGame != nil {
-		// Carry over the players but without teams in case
-		// they want to switch them up.
-		for id, p := range oldGame.players {
-			game.players[id] = Player{LastSeen: p.LastSeen}
+	h.mu.Lock()
+	seed := h.rand.Int63()
+	h.mu.Unlock()
+
+	var clues []Clue
+	if body.Practice {
+		var err error
+		clues, err = h.planPracticeGame(seed, words)
+		if err != nil {
+			return nil, err
 		}
+	}
 
-		// Wake up any clients waiting on this game.
-		oldGame.notifyAll()
+	name, err := h.moderateName(body.Name)
+	if err != nil {
+		return nil, err
 	}
 
-	g := &game
-	g.CreatedAt = time.Now()
-	h.games[body.GameID] = g
-	writeJSON(rw, g)
+	var pc *passcode
+	if body.Passcode != "" {
+		var err error
+		if pc, err = newPasscode(body.Passcode); err != nil {
+			return nil, err
+		}
+	}
+
+	return h.exec(ctx, command{
+		Op:        opNewGame,
+		GameID:    body.GameID,
+		PlayerID:  body.PlayerID,
+		Seed:      Seed(seed),
+		Generator: CurrentGenerator,
+		Words:     words,
+		WordList:  wordList,
+		Clues:     clues,
+		Passcode:  pc,
+		Public:    body.Public,
+		RoomName:  name,
+	})
 }
 
 // POST /guess
 func (h *handler) handleGuess(rw http.ResponseWriter, req *http.Request) {
-	var body struct {
-		GameID   string `json:"game_id"`
-		Seed     Seed   `json:"seed"`
-		PlayerID string `json:"player_id"`
-		Name     string `json:"name"`
-		Team     int    `json:"team"`
-		Index    int    `json:"index"`
-	}
-
-	err := json.NewDecoder(req.Body).Decode(&body)
+	var body GuessRequest
+	err := decodePlayerRequest(req, &body, &body.PlayerRequest)
 	if err != nil || body.GameID == "" || body.Team == 0 || body.PlayerID == "" {
 		writeError(rw, "malformed_body", "Unable to parse request body.", 400)
 		return
 	}
 
-	h.mu.Lock()
-	g, ok := h.games[body.GameID]
-	h.mu.Unlock()
-	if !ok {
-		writeError(rw, "not_found", "Game not found", 404)
-		return
-	}
-
-	g.mu.Lock()
-	defer g.mu.Unlock()
-	if body.Seed != g.Seed {
-		writeError(rw, "bad_seed", "Request intended for a different game seed.", 400)
-		return
-	}
-
-	g.markSeen(body.PlayerID, body.Name, body.Team, time.Now())
-	g.guess(body.PlayerID, body.Name, body.Team, body.Index, time.Now())
-	writeJSON(rw, map[string]string{"status": "ok"})
+	cmd := body.command(opGuess)
+	cmd.Index = body.Index
+	resp, err := h.exec(req.Context(), cmd)
+	writeResult(rw, resp, err)
 }
 
 // POST /end-turn
 func (h *handler) handleEndTurn(rw http.ResponseWriter, req *http.Request) {
-	var body struct {
-		GameID   string `json:"game_id"`
-		Seed     Seed   `json:"seed"`
-		PlayerID string `json:"player_id"`
-		Name     string `json:"name"`
-		Team     int    `json:"team"`
-	}
-
-	err := json.NewDecoder(req.Body).Decode(&body)
+	var body PlayerRequest
+	err := decodePlayerRequest(req, &body, &body)
 	if err != nil || body.GameID == "" || body.Team == 0 || body.PlayerID == "" {
 		writeError(rw, "malformed_body", "Unable to parse request body.", 400)
 		return
 	}
 
-	h.mu.Lock()
-	g, ok := h.games[body.GameID]
-	h.mu.Unlock()
-	if !ok {
-		writeError(rw, "not_found", "Game not found", 404)
-		return
-	}
-
-	g.mu.Lock()
-	defer g.mu.Unlock()
-	if body.Seed != g.Seed {
-		writeError(rw, "bad_seed", "Request intended for a different game seed.", 400)
-		return
-	}
-
-	g.markSeen(body.PlayerID, body.Name, body.Team, time.Now())
-	g.addEvent(Event{
-		Type:     "end_turn",
-		Team:     body.Team,
-		PlayerID: body.PlayerID,
-		Name:     body.Name,
-	})
-	writeJSON(rw, map[string]string{"status": "ok"})
+	resp, err := h.exec(req.Context(), body.command(opEndTurn))
+	writeResult(rw, resp, err)
 }
 
 // POST /chat
 func (h *handler) handleChat(rw http.ResponseWriter, req *http.Request) {
-	var body struct {
-		GameID   string `json:"game_id"`
-		Seed     Seed   `json:"seed"`
-		PlayerID string `json:"player_id"`
-		Name     string `json:"name"`
-		Team     int    `json:"team"`
-		Message  string `json:"message"`
-	}
-
-	err := json.NewDecoder(req.Body).Decode(&body)
-	if err != nil || body.GameID == "" || body.Team == 0 || body.PlayerID == "" || body.Message == "" {
+	var body ChatRequest
+	err := decodePlayerRequest(req, &body, &body.PlayerRequest)
+	if err != nil || body.GameID == "" || body.Team == 0 || body.PlayerID == "" || body.Message == "" ||
+		(body.Scope != "" && body.Scope != ScopeAll && body.Scope != ScopeSide) {
 		writeError(rw, "malformed_body", "Unable to parse request body.", 400)
 		return
 	}
 
-	h.mu.Lock()
-	g, ok := h.games[body.GameID]
-	h.mu.Unlock()
-	if !ok {
-		writeError(rw, "not_found", "Game not found", 404)
-		return
-	}
-
-	g.mu.Lock()
-	defer g.mu.Unlock()
-	if body.Seed != g.Seed {
-		writeError(rw, "bad_seed", "Request intended for a different game seed.", 400)
+	message, err := h.moderateMessage(body.Message)
+	if err != nil {
+		writeResult(rw, nil, err)
 		return
 	}
-
-	g.markSeen(body.PlayerID, body.Name, body.Team, time.Now())
-	g.addEvent(Event{
-		Type:     "chat",
-		Team:     body.Team,
-		PlayerID: body.PlayerID,
-		Name:     body.Name,
-		Message:  body.Message,
-	})
-	writeJSON(rw, map[string]string{"status": "ok"})
+	cmd := body.command(opChat)
+	cmd.Message = message
+	cmd.Scope = body.Scope
+	resp, err := h.exec(req.Context(), cmd)
+	writeResult(rw, resp, err)
 }
 
 // POST /events
 func (h *handler) handleEvents(rw http.ResponseWriter, req *http.Request) {
-	var body struct {
-		GameID    string `json:"game_id"`
-		Seed      Seed   `json:"seed"`
-		PlayerID  string `json:"player_id"`
-		Name      string `json:"name"`
-		Team      int    `json:"team"`
-		LastEvent int    `json:"last_event"`
-	}
-
-	err := json.NewDecoder(req.Body).Decode(&body)
+	var body EventsRequest
+	err := decodePlayerRequest(req, &body, &body.PlayerRequest)
 	if err != nil || body.GameID == "" || body.PlayerID == "" {
 		writeError(rw, "malformed_body", "Unable to parse request body.", 400)
 		return
 	}
+	h.pollEvents(rw, req, body)
+}
 
-	h.mu.Lock()
-	g, ok := h.games[body.GameID]
-	h.mu.Unlock()
+// pollEvents responds with the game's events after body.LastEvent,
+// waiting for new events if there aren't any yet. Players only
+// receive the events that their side may see.
+func (h *handler) pollEvents(rw http.ResponseWriter, req *http.Request, body EventsRequest) {
+	g, ok := h.games.get(body.GameID)
 	if !ok {
 		writeError(rw, "not_found", "Game not found", 404)
 		return
 	}
 
+	key, session := playerKey(req.Context(), body.PlayerID)
 	g.mu.Lock()
 	seed := g.Seed
 	if body.Seed != seed {
-		evts, _ := g.eventsSince(body.LastEvent)
+		evts, _ := g.eventsSince(body.LastEvent, g.sideOf(body.PlayerID, key, session))
 		g.mu.Unlock()
 		writeJSON(rw, GameUpdate{Seed: seed, Events: evts})
 		return
 	}
-	g.markSeen(body.PlayerID, body.Name, body.Team, time.Now())
-
-	evts, ch := g.eventsSince(body.LastEvent)
-
-	// Release the mutex.
-	// We reacquire it when we reretrieve the game.
 	g.mu.Unlock()
 
-	if len(evts) > 0 {
-		writeJSON(rw, GameUpdate{Seed: seed, Events: evts})
+	_, err := h.seen(req.Context(), body.PlayerRequest)
+	if apiErr, ok := err.(*apiError); ok && (apiErr.StatusCode >= 500 || apiErr == errKicked) {
+		writeResult(rw, nil, err)
 		return
 	}
 
-	// Wait until a new event becomes available, the client
-	// gives up, or we time out.
-	select {
-	case <-ch:
-		// re-retrieve the game in case it was replaced
-		// while we were waiting for events.
-		h.mu.Lock()
-		g, ok := h.games[body.GameID]
-		h.mu.Unlock()
+	var timeout <-chan time.Time
+	for {
+		// The game may have been replaced while the command made
+		// its way through the broker or while we were waiting for
+		// events, so re-retrieve it each time.
+		g, ok = h.games.get(body.GameID)
 		if !ok {
 			writeError(rw, "not_found", "Game not found", 404)
 			return
 		}
 		g.mu.Lock()
-		evts, _ = g.eventsSince(body.LastEvent)
 		seed = g.Seed
+		evts, ch := g.eventsSince(body.LastEvent, g.sideOf(body.PlayerID, key, session))
 		g.mu.Unlock()
 
-	case <-req.Context().Done():
-	case <-time.After(25 * time.Second):
+		if len(evts) > 0 || seed != body.Seed {
+			writeJSON(rw, GameUpdate{Seed: seed, Events: evts})
+			return
+		}
+
+		// Wait until a new event becomes available, the client
+		// gives up, or we time out. Events the player can't see
+		// wake us up too, in which case we keep waiting.
+		if timeout == nil {
+			timeout = h.clock.After(25 * time.Second)
+		}
+		select {
+		case <-ch:
+			continue
+		case <-req.Context().Done():
+		case <-timeout:
+		}
+		writeJSON(rw, GameUpdate{Seed: seed, Events: evts})
+		return
 	}
-	writeJSON(rw, GameUpdate{Seed: seed, Events: evts})
 }
 
 // POST /ping
@@ -367,68 +419,81 @@ func (h *handler) handleEvents(rw http.ResponseWriter, req *http.Request) {
 // It only calls `markSeen` with the provided player information
 // and has no other effects.
 func (h *handler) handlePing(rw http.ResponseWriter, req *http.Request) {
-	var body struct {
-		GameID   string `json:"game_id"`
-		Seed     Seed   `json:"seed"`
-		PlayerID string `json:"player_id"`
-		Name     string `json:"name"`
-		Team     int    `json:"team"`
-	}
-
-	err := json.NewDecoder(req.Body).Decode(&body)
+	var body PlayerRequest
+	err := decodePlayerRequest(req, &body, &body)
 	if err != nil || body.GameID == "" || body.PlayerID == "" {
 		writeError(rw, "malformed_body", "Unable to parse request body.", 400)
 		return
 	}
 
-	h.mu.Lock()
-	g, ok := h.games[body.GameID]
-	h.mu.Unlock()
-	if !ok {
-		writeError(rw, "not_found", "Game not found", 404)
-		return
-	}
-	if body.Seed != g.Seed {
-		writeError(rw, "bad_seed", "Request intended for a different game seed.", 400)
-		return
+	resp, err := h.seen(req.Context(), body)
+	writeResult(rw, resp, err)
+}
+
+// seen records that the player is still around. Most of the time
+// that's all it does, so it's recorded with a command that brokers
+// don't retain, falling back to one they do if the player is
+// joining or changing sides or names.
+func (h *handler) seen(ctx context.Context, p PlayerRequest) (interface{}, error) {
+	resp, err := h.exec(ctx, p.command(opPresence))
+	if err == errNotPresent {
+		resp, err = h.exec(ctx, p.command(opSeen))
 	}
+	return resp, err
+}
 
-	g.mu.Lock()
-	g.markSeen(body.PlayerID, body.Name, body.Team, time.Now())
-	g.mu.Unlock()
-	writeJSON(rw, map[string]string{"status": "ok"})
+// command returns a command performing op on behalf of the player.
+func (p PlayerRequest) command(op string) command {
+	return command{
+		Op:       op,
+		GameID:   p.GameID,
+		Seed:     p.Seed,
+		PlayerID: p.PlayerID,
+		Name:     p.Name,
+		Team:     p.Team,
+	}
 }
 
-type GameUpdate struct {
-	Seed   Seed    `json:"seed"`
-	Events []Event `json:"events"`
+// decodePlayerRequest decodes the request's body into body, whose
+// player fields are p. Routes under /v2 identify the game, and
+// sometimes the player, in the path instead of the body. Players
+// who are signed in play as their account.
+func decodePlayerRequest(req *http.Request, body interface{}, p *PlayerRequest) error {
+	if err := json.NewDecoder(req.Body).Decode(body); err != nil {
+		return err
+	}
+	if id := req.PathValue("id"); id != "" {
+		p.GameID = id
+	}
+	if id := req.PathValue("player_id"); id != "" {
+		p.PlayerID = id
+	}
+	if a, ok := requestAccount(req); ok {
+		p.PlayerID, p.Name = a.PlayerID, a.DisplayName
+	}
+	return nil
 }
 
+// GET /stats?window=24h
+// Reports the current number of active games and players, along with
+// hourly aggregates over the requested window. The window defaults to
+// 24 hours and may be at most 7 days.
 func (h *handler) handleStats(rw http.ResponseWriter, req *http.Request) {
-	var players, games int
-	h.mu.Lock()
-	for _, g := range h.games {
-		g.mu.Lock()
-		players += len(g.players)
-		if len(g.players) > 0 {
-			games++
+	window := 24 * time.Hour
+	if w := req.URL.Query().Get("window"); w != "" {
+		d, err := time.ParseDuration(w)
+		if err != nil || d < time.Hour || d > statsRetention {
+			writeError(rw, "bad_window", "The window must be a duration between 1h and 168h.", 400)
+			return
 		}
-		g.mu.Unlock()
+		window = d.Truncate(time.Hour)
 	}
-	h.mu.Unlock()
-
-	writeJSON(rw, struct {
-		ActiveGames   int `json:"active_games"`
-		ActivePlayers int `json:"active_players"`
-	}{ActiveGames: games, ActivePlayers: players})
+	writeJSON(rw, h.stats.summarize(h.clock.Now(), window))
 }
 
 func writeError(rw http.ResponseWriter, code, message string, statusCode int) {
 	rw.WriteHeader(statusCode)
-	writeJSON(rw, struct {
-		Code    string `json:"code"`
-		Message string `json:"message"`
-	}{Code: code, Message: message})
+	writeJSON(rw, ErrorResponse{Code: code, Message: message})
 }
 
 func writeJSON(rw http.ResponseWriter, resp interface{}) {
@@ -0,0 +1,705 @@
+package gameapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// post makes a request to h with a JSON body, decoding the
+// response into resp if it's non-nil. It's safe to call from
+// goroutines other than the test's.
+func post(t *testing.T, h http.Handler, path string, body interface{}, resp interface{}) int {
+	t.Helper()
+	return postContext(context.Background(), t, h, path, body, resp)
+}
+
+func postContext(ctx context.Context, t *testing.T, h http.Handler, path string, body interface{}, resp interface{}) int {
+	t.Helper()
+	return request(ctx, t, h, "POST", path, body, resp)
+}
+
+// request makes a request to h like post, but with any method. A nil
+// body is sent as an empty body, and resp is decoded from any 2xx
+// response.
+func request(ctx context.Context, t *testing.T, h http.Handler, method, path string, body interface{}, resp interface{}) int {
+	t.Helper()
+	var b []byte
+	if body != nil {
+		var err error
+		if b, err = json.Marshal(body); err != nil {
+			t.Error(err)
+			return 0
+		}
+	}
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, bytes.NewReader(b)).WithContext(ctx)
+	h.ServeHTTP(rec, req)
+	if resp != nil && rec.Code/100 == 2 {
+		if err := json.Unmarshal(rec.Body.Bytes(), resp); err != nil {
+			t.Errorf("%s %s: unmarshaling %q: %s", method, path, rec.Body.String(), err)
+		}
+	}
+	return rec.Code
+}
+
+// waitFor polls cond until it returns true, failing
+// the test if it doesn't within a few seconds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func newTestHandler(clock *FakeClock) *handler {
+	h := Handler(map[string][]string{"example": exampleWords}, WithClock(clock)).(*handler)
+	// Wait for the pruning goroutine to start its ticker.
+	clock.BlockUntil(1)
+	return h
+}
+
+func newTestGame(t *testing.T, h http.Handler, id string) Seed {
+	var game struct {
+		State GameState `json:"state"`
+	}
+	if code := post(t, h, "/new-game", map[string]interface{}{"game_id": id}, &game); code != 200 {
+		t.Fatalf("new-game status = %d, want 200", code)
+	}
+	return game.State.Seed
+}
+
+func TestPlayerExpiry(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC))
+	h := newTestHandler(clock)
+	seed := newTestGame(t, h, "expiry")
+
+	for _, p := range []string{"alice", "bob"} {
+		post(t, h, "/ping", map[string]interface{}{"game_id": "expiry", "seed": seed, "player_id": p, "team": 1}, nil)
+	}
+	clock.Advance(9*time.Minute + 30*time.Second)
+	post(t, h, "/ping", map[string]interface{}{"game_id": "expiry", "seed": seed, "player_id": "bob", "team": 1}, nil)
+	clock.Advance(30 * time.Second)
+
+	g, _ := h.games.get("expiry")
+	waitFor(t, "alice to be pruned", func() bool {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return len(g.players) == 1
+	})
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.players["bob"]; !ok {
+		t.Error("bob was pruned despite being seen within the last 50 seconds")
+	}
+	// Alice hosted the game, so bob takes over.
+	left, host := g.Events[len(g.Events)-2], g.Events[len(g.Events)-1]
+	if left.Type != "player_left" || left.PlayerID != "alice" {
+		t.Errorf("event = %+v, want alice's player_left", left)
+	}
+	if host.Type != "host_changed" || host.PlayerID != "bob" || g.Host != "bob" {
+		t.Errorf("last event = %+v, want bob to become the host", host)
+	}
+}
+
+func TestGameEviction(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC))
+	h := newTestHandler(clock)
+	seed := newTestGame(t, h, "eviction")
+	post(t, h, "/ping", map[string]interface{}{"game_id": "eviction", "seed": seed, "player_id": "alice", "team": 1}, nil)
+
+	// The game loses its last player after ten minutes, but
+	// it's kept around until it's a day old.
+	for i := 0; i < 6*24-1; i++ {
+		clock.Advance(10 * time.Minute)
+	}
+	g, _ := h.games.get("eviction")
+	waitFor(t, "alice to be pruned", func() bool {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return len(g.players) == 0
+	})
+	if _, ok := h.games.get("eviction"); !ok {
+		t.Fatal("game evicted before it was 24 hours old")
+	}
+
+	clock.Advance(10*time.Minute + time.Second)
+	waitFor(t, "the game to be evicted", func() bool {
+		_, ok := h.games.get("eviction")
+		return !ok
+	})
+}
+
+func TestEventsTimeout(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC))
+	h := newTestHandler(clock)
+	seed := newTestGame(t, h, "timeout")
+
+	done := make(chan GameUpdate)
+	go func() {
+		var up GameUpdate
+		post(t, h, "/events", map[string]interface{}{"game_id": "timeout", "seed": seed, "player_id": "alice"}, &up)
+		done <- up
+	}()
+
+	// Wait for the long poll to start waiting on
+	// its timer alongside the pruning ticker.
+	clock.BlockUntil(2)
+	select {
+	case <-done:
+		t.Fatal("long poll returned before timing out")
+	default:
+	}
+
+	clock.Advance(25 * time.Second)
+	select {
+	case up := <-done:
+		if len(up.Events) != 0 || up.Seed != seed {
+			t.Errorf("update = %+v, want no events", up)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("long poll didn't time out")
+	}
+}
+
+func TestIndex(t *testing.T) {
+	h := newTestHandler(NewFakeClock(time.Now()))
+	var resp struct {
+		AutogeneratedID string `json:"autogenerated_id"`
+	}
+	if code := post(t, h, "/index", map[string]interface{}{}, &resp); code != 200 {
+		t.Fatalf("status = %d, want 200", code)
+	}
+	words := strings.Split(resp.AutogeneratedID, "-")
+	if len(words) != 2 || words[0] == "" || words[1] == "" {
+		t.Errorf("autogenerated_id = %q, want two hyphenated words", resp.AutogeneratedID)
+	}
+}
+
+func TestNewGame(t *testing.T) {
+	h := newTestHandler(NewFakeClock(time.Now()))
+
+	type gameResp struct {
+		State     GameState `json:"state"`
+		Words     []string  `json:"words"`
+		OneLayout []string  `json:"one_layout"`
+		TwoLayout []string  `json:"two_layout"`
+	}
+	var first gameResp
+	if code := post(t, h, "/new-game", map[string]interface{}{"game_id": "new"}, &first); code != 200 {
+		t.Fatalf("status = %d, want 200", code)
+	}
+	if len(first.Words) != 25 || len(first.OneLayout) != 25 || len(first.TwoLayout) != 25 {
+		t.Errorf("got %d words and %d, %d colors, want 25", len(first.Words), len(first.OneLayout), len(first.TwoLayout))
+	}
+
+	// Without the previous seed, the existing game is returned.
+	var again gameResp
+	post(t, h, "/new-game", map[string]interface{}{"game_id": "new"}, &again)
+	if again.State.Seed != first.State.Seed {
+		t.Errorf("seed = %d, want existing seed %d", again.State.Seed, first.State.Seed)
+	}
+	post(t, h, "/new-game", map[string]interface{}{"game_id": "new", "prev_seed": Seed(first.State.Seed + 1)}, &again)
+	if again.State.Seed != first.State.Seed {
+		t.Errorf("seed = %d, want existing seed %d", again.State.Seed, first.State.Seed)
+	}
+
+	// With the previous seed, the game is replaced.
+	var replaced gameResp
+	post(t, h, "/new-game", map[string]interface{}{"game_id": "new", "prev_seed": first.State.Seed}, &replaced)
+	if replaced.State.Seed == first.State.Seed {
+		t.Error("game wasn't replaced")
+	}
+
+	// Games may be created from a named word list.
+	var named gameResp
+	if code := post(t, h, "/new-game", map[string]interface{}{"game_id": "named", "word_list": "example"}, &named); code != 200 {
+		t.Errorf("status = %d, want 200", code)
+	}
+}
+
+func TestBadRequests(t *testing.T) {
+	h := newTestHandler(NewFakeClock(time.Now()))
+	seed := newTestGame(t, h, "bad")
+	player := func(extra map[string]interface{}) map[string]interface{} {
+		body := map[string]interface{}{"game_id": "bad", "seed": seed, "player_id": "alice", "team": 1}
+		for k, v := range extra {
+			body[k] = v
+		}
+		return body
+	}
+
+	testCases := []struct {
+		path string
+		body interface{}
+		code int
+	}{
+		{"/new-game", map[string]interface{}{}, 400},
+		{"/new-game", map[string]interface{}{"game_id": "x", "word_list": "missing"}, 400},
+		{"/new-game", map[string]interface{}{"game_id": "x", "words": []string{"too", "few"}}, 400},
+		{"/guess", map[string]interface{}{"game_id": "bad"}, 400},
+		{"/guess", player(map[string]interface{}{"game_id": "missing"}), 404},
+		{"/guess", player(map[string]interface{}{"seed": Seed(seed + 1)}), 400},
+		{"/guess", player(map[string]interface{}{"seed": 7}), 400}, // seeds are strings
+		{"/end-turn", player(map[string]interface{}{"team": 0}), 400},
+		{"/end-turn", player(map[string]interface{}{"game_id": "missing"}), 404},
+		{"/end-turn", player(map[string]interface{}{"seed": Seed(seed + 1)}), 400},
+		{"/chat", player(nil), 400}, // no message
+		{"/chat", player(map[string]interface{}{"message": "hi", "game_id": "missing"}), 404},
+		{"/chat", player(map[string]interface{}{"message": "hi", "seed": Seed(seed + 1)}), 400},
+		{"/ping", player(map[string]interface{}{"player_id": ""}), 400},
+		{"/ping", player(map[string]interface{}{"game_id": "missing"}), 404},
+		{"/ping", player(map[string]interface{}{"seed": Seed(seed + 1)}), 400},
+		{"/events", player(map[string]interface{}{"player_id": ""}), 400},
+		{"/events", player(map[string]interface{}{"game_id": "missing"}), 404},
+		{"/stats?window=forever", nil, 400},
+		{"/stats?window=720h", nil, 400},
+	}
+	for _, tc := range testCases {
+		if code := post(t, h, tc.path, tc.body, nil); code != tc.code {
+			t.Errorf("POST %s %v: status = %d, want %d", tc.path, tc.body, code, tc.code)
+		}
+	}
+}
+
+func TestGameplayRoutes(t *testing.T) {
+	h := newTestHandler(NewFakeClock(time.Now()))
+	seed := newTestGame(t, h, "routes")
+	body := func(extra map[string]interface{}) map[string]interface{} {
+		b := map[string]interface{}{"game_id": "routes", "seed": seed, "player_id": "alice", "name": "Alice", "team": 1}
+		for k, v := range extra {
+			b[k] = v
+		}
+		return b
+	}
+
+	for _, req := range []struct {
+		path string
+		body map[string]interface{}
+	}{
+		{"/ping", body(nil)},
+		{"/guess", body(map[string]interface{}{"index": 4})},
+		{"/guess", body(map[string]interface{}{"index": 4})}, // duplicate, ignored
+		{"/chat", body(map[string]interface{}{"message": "hello"})},
+		{"/end-turn", body(nil)},
+	} {
+		var resp map[string]string
+		if code := post(t, h, req.path, req.body, &resp); code != 200 || resp["status"] != "ok" {
+			t.Errorf("POST %s: status = %d, response = %v", req.path, code, resp)
+		}
+	}
+
+	var up GameUpdate
+	post(t, h, "/events", body(nil), &up)
+	var types []string
+	for _, e := range up.Events {
+		types = append(types, e.Type)
+	}
+	if got, want := strings.Join(types, ","), "join_side,guess,chat,end_turn"; got != want {
+		t.Errorf("event types = %s, want %s", got, want)
+	}
+
+	// Only events after last_event are returned.
+	post(t, h, "/events", body(map[string]interface{}{"last_event": 2}), &up)
+	if len(up.Events) != 2 || up.Events[0].Number != 3 {
+		t.Errorf("events since 2 = %+v", up.Events)
+	}
+}
+
+func TestEventsSeedMismatch(t *testing.T) {
+	h := newTestHandler(NewFakeClock(time.Now()))
+	seed := newTestGame(t, h, "mismatch")
+	post(t, h, "/ping", map[string]interface{}{"game_id": "mismatch", "seed": seed, "player_id": "alice", "team": 1}, nil)
+
+	// A client polling with a stale seed learns about the new seed
+	// immediately, without waiting for the long poll to time out.
+	var up GameUpdate
+	post(t, h, "/events", map[string]interface{}{"game_id": "mismatch", "seed": Seed(seed + 1), "player_id": "alice"}, &up)
+	if up.Seed != seed || len(up.Events) != 1 {
+		t.Errorf("update = %+v, want the current seed and its event", up)
+	}
+}
+
+func TestConcurrentLongPolls(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	h := newTestHandler(clock)
+	seed := newTestGame(t, h, "concurrent")
+	host := keyed(h, "alice")
+	post(t, host, "/ping", map[string]interface{}{"game_id": "concurrent", "seed": seed, "player_id": "alice"}, nil)
+
+	const pollers = 20
+	var wg sync.WaitGroup
+	updates := make(chan GameUpdate, pollers)
+	for i := 0; i < pollers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var up GameUpdate
+			post(t, h, "/events", map[string]interface{}{
+				"game_id":   "concurrent",
+				"seed":      seed,
+				"player_id": fmt.Sprintf("spectator-%d", i),
+			}, &up)
+			updates <- up
+		}(i)
+	}
+	clock.BlockUntil(pollers + 1)
+
+	post(t, h, "/chat", map[string]interface{}{
+		"game_id": "concurrent", "seed": seed, "player_id": "alice", "team": 1, "message": "wake up",
+	}, nil)
+	wg.Wait()
+	close(updates)
+	for up := range updates {
+		if n := len(up.Events); n == 0 || up.Events[n-1].Message != "wake up" {
+			t.Errorf("update = %+v, want the chat message", up)
+		}
+	}
+
+	// A new game wakes pollers up too, so they learn the new seed.
+	done := make(chan GameUpdate)
+	go func() {
+		var up GameUpdate
+		post(t, h, "/events", map[string]interface{}{
+			"game_id": "concurrent", "seed": seed, "player_id": "bob", "last_event": 100,
+		}, &up)
+		done <- up
+	}()
+	clock.BlockUntil(2)
+	post(t, host, "/new-game", map[string]interface{}{"game_id": "concurrent", "prev_seed": seed, "player_id": "alice"}, nil)
+	if up := <-done; up.Seed == seed {
+		t.Error("long poll returned the old seed after the game was replaced")
+	}
+}
+
+func TestStats(t *testing.T) {
+	h := newTestHandler(NewFakeClock(time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)))
+	seed := newTestGame(t, h, "stats")
+	for _, p := range []string{"alice", "bob"} {
+		post(t, keyed(h, p), "/ping", map[string]interface{}{"game_id": "stats", "seed": seed, "player_id": p}, nil)
+	}
+
+	var sum StatsSummary
+	if code := post(t, h, "/stats?window=2h", nil, &sum); code != 200 {
+		t.Fatalf("status = %d, want 200", code)
+	}
+	if sum.ActiveGames != 1 || sum.ActivePlayers != 2 || sum.GamesStarted != 1 || len(sum.Series) != 2 {
+		t.Errorf("stats = %+v", sum)
+	}
+
+	// Bots stop counting once the game they're in is replaced.
+	h.guesser = &fakeGuesser{}
+	if code := post(t, h, "/games/stats/bots", map[string]interface{}{"seed": seed, "player_id": "alice", "team": 2}, nil); code != 200 {
+		t.Fatalf("add bot: status = %d, want 200", code)
+	}
+	if code := post(t, keyed(h, "alice"), "/new-game", map[string]interface{}{"game_id": "stats", "prev_seed": seed, "player_id": "alice"}, nil); code != 200 {
+		t.Fatalf("replace game: status = %d, want 200", code)
+	}
+	post(t, h, "/stats?window=2h", nil, &sum)
+	if sum.ActiveGames != 1 || sum.ActivePlayers != 2 {
+		t.Errorf("stats after replacing the game with a bot = %+v, want 2 active players", sum)
+	}
+}
+
+type fakeSpymaster struct {
+	board Board
+}
+
+func (s *fakeSpymaster) SuggestClues(b Board, max int) []Clue {
+	s.board = b
+	return []Clue{{Word: "clue", Number: 1, Targets: []string{b.Words[0]}}}
+}
+
+func TestSuggestClue(t *testing.T) {
+	h := newTestHandler(NewFakeClock(time.Now()))
+	seed := newTestGame(t, h, "clues")
+	if code := post(t, h, "/games/clues/suggest-clue", map[string]interface{}{"seed": seed, "team": 1}, nil); code != 501 {
+		t.Errorf("status without a spymaster = %d, want 501", code)
+	}
+
+	s := &fakeSpymaster{}
+	h.spymaster = s
+	g, _ := h.games.get("clues")
+	var green int
+	for i, c := range g.TwoLayout {
+		if c == Green {
+			green = i
+		}
+	}
+	post(t, h, "/guess", map[string]interface{}{"game_id": "clues", "seed": seed, "player_id": "alice", "team": 1, "index": green}, nil)
+
+	var resp struct {
+		Clues []Clue `json:"clues"`
+	}
+	if code := post(t, h, "/games/clues/suggest-clue", map[string]interface{}{"seed": seed, "team": 2}, &resp); code != 200 {
+		t.Fatalf("status = %d, want 200", code)
+	}
+	if len(resp.Clues) != 1 || resp.Clues[0].Word != "clue" {
+		t.Errorf("clues = %+v", resp.Clues)
+	}
+	if s.board.Hidden[green] || s.board.Key[green] != Green {
+		t.Errorf("side B's board should show card %d as a revealed green", green)
+	}
+
+	for body, want := range map[string]int{
+		`{"seed": "` + strconv.FormatInt(int64(seed), 10) + `", "team": 3}`: 400,
+		`{"seed": "1", "team": 1}`: 400,
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("POST", "/games/clues/suggest-clue", strings.NewReader(body)))
+		if rec.Code != want {
+			t.Errorf("POST %s: status = %d, want %d", body, rec.Code, want)
+		}
+	}
+	if code := post(t, h, "/games/missing/suggest-clue", map[string]interface{}{"seed": seed, "team": 1}, nil); code != 404 {
+		t.Errorf("status for a missing game = %d, want 404", code)
+	}
+}
+
+// fakeGuesser ranks cards in a fixed order.
+type fakeGuesser struct {
+	order []int
+}
+
+func (g *fakeGuesser) RankGuesses(b Board, clue string) []Guess {
+	var guesses []Guess
+	for i, idx := range g.order {
+		if b.Hidden[idx] {
+			guesses = append(guesses, Guess{Index: idx, Confidence: 1 - float64(i)*0.01})
+		}
+	}
+	return guesses
+}
+
+func TestBots(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	h := newTestHandler(clock)
+	seed := newTestGame(t, h, "bots")
+	addBot := map[string]interface{}{"seed": seed, "player_id": "bob", "team": 2, "name": "Robo"}
+	if code := post(t, h, "/games/bots/bots", addBot, nil); code != 501 {
+		t.Errorf("status without a guesser = %d, want 501", code)
+	}
+
+	// Bots on side B guess against side A's key.
+	g, _ := h.games.get("bots")
+	var greens, tans []int
+	for i, c := range g.OneLayout {
+		switch c {
+		case Green:
+			greens = append(greens, i)
+		case Tan:
+			tans = append(tans, i)
+		}
+	}
+	guesser := &fakeGuesser{order: []int{greens[0], greens[1], greens[2], tans[0]}}
+	h.guesser = guesser
+
+	var bot struct {
+		PlayerID string `json:"player_id"`
+	}
+	if code := post(t, h, "/games/bots/bots", addBot, &bot); code != 200 || bot.PlayerID == "" {
+		t.Fatalf("add bot: status = %d, player = %q", code, bot.PlayerID)
+	}
+
+	clue := map[string]interface{}{"game_id": "bots", "seed": seed, "player_id": "alice", "name": "Alice", "team": 1, "word": "fruit", "number": 2}
+	if code := post(t, h, "/clue", clue, nil); code != 200 {
+		t.Fatalf("clue status = %d, want 200", code)
+	}
+	g.mu.Lock()
+	var got []string
+	for _, e := range g.Events {
+		got = append(got, fmt.Sprintf("%s:%s:%d", e.Type, e.Name, e.Index))
+	}
+	g.mu.Unlock()
+	want := []string{
+		"join_side:Robo:0",
+		"join_side:Alice:0",
+		"clue:Alice:2",
+		fmt.Sprintf("guess:Robo:%d", greens[0]),
+		fmt.Sprintf("guess:Robo:%d", greens[1]),
+		"end_turn:Robo:0",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+
+	// A bot's turn ends as soon as it guesses a tan card.
+	post(t, h, "/end-turn", map[string]interface{}{"game_id": "bots", "seed": seed, "player_id": "alice", "team": 1}, nil)
+	guesser.order = []int{tans[0], greens[2]}
+	clue["number"] = 1
+	if code := post(t, h, "/clue", clue, nil); code != 200 {
+		t.Fatalf("clue status = %d, want 200", code)
+	}
+	g.mu.Lock()
+	last := g.Events[len(g.Events)-1]
+	turn := g.status().Turn
+	g.mu.Unlock()
+	if last.Type != "guess" || last.Index != tans[0] || turn != 1 {
+		t.Errorf("last event = %+v with turn %d, want a guess of %d with turn 1", last, turn, tans[0])
+	}
+
+	for _, body := range []map[string]interface{}{
+		{"game_id": "bots", "seed": seed, "player_id": "alice", "team": 1, "word": "two words", "number": 1},
+		{"game_id": "bots", "seed": seed, "player_id": "alice", "team": 1, "word": "", "number": 1},
+		{"game_id": "bots", "seed": seed, "player_id": "alice", "team": 1, "word": "fruit", "number": -1},
+	} {
+		if code := post(t, h, "/clue", body, nil); code != 400 {
+			t.Errorf("clue %v: status = %d, want 400", body, code)
+		}
+	}
+	for _, body := range []map[string]interface{}{
+		{"seed": seed, "player_id": "bob", "team": 2, "risk": 2},
+		{"seed": seed, "team": 2},
+	} {
+		if code := post(t, h, "/games/bots/bots", body, nil); code != 400 {
+			t.Errorf("add bot %v: status = %d, want 400", body, code)
+		}
+	}
+
+	// Players may only add bots to their own side while the teams
+	// are locked, and kicked players can't add them at all.
+	clock.Advance(10 * time.Second) // for the bots' rate limit
+	g.mu.Lock()
+	g.Settings.TeamsLocked = true
+	g.kicked["mallory"] = true
+	g.mu.Unlock()
+	for _, r := range []struct {
+		player string
+		team   int
+		want   int
+	}{
+		{"alice", 2, 403},
+		{"mallory", 1, 403},
+		{"alice", 1, 200},
+	} {
+		body := map[string]interface{}{"seed": seed, "player_id": r.player, "team": r.team}
+		if code := post(t, h, "/games/bots/bots", body, nil); code != r.want {
+			t.Errorf("add bot to side %d by %s: status = %d, want %d", r.team, r.player, code, r.want)
+		}
+	}
+
+	// Once the humans leave, so do the bots.
+	later := clock.Now().Add(time.Minute)
+	ids, _ := g.departed(later)
+	if remaining := g.removePlayers(ids, later); remaining != 0 {
+		t.Errorf("remaining players = %d, want 0", remaining)
+	}
+}
+
+// greedySpymaster gives clues for three of the hidden greens at a time.
+type greedySpymaster struct{}
+
+func (greedySpymaster) SuggestClues(b Board, max int) []Clue {
+	clue := Clue{Word: "clue", Number: 3}
+	for i, c := range b.Key {
+		if c == Green && b.Hidden[i] && len(clue.Targets) < 3 {
+			clue.Targets = append(clue.Targets, b.Words[i])
+		}
+	}
+	return []Clue{clue}
+}
+
+func TestPractice(t *testing.T) {
+	h := newTestHandler(NewFakeClock(time.Now()))
+	newPractice := map[string]interface{}{"game_id": "solo", "practice": true}
+	if code := post(t, h, "/new-game", newPractice, nil); code != 501 {
+		t.Errorf("status without a spymaster = %d, want 501", code)
+	}
+	h.spymaster = greedySpymaster{}
+
+	var game map[string]interface{}
+	if code := post(t, h, "/new-game", newPractice, &game); code != 200 {
+		t.Fatalf("new-game status = %d, want 200", code)
+	}
+	// Players learn how many clues there are, but not their targets.
+	b, _ := json.Marshal(game)
+	if state := game["state"].(map[string]interface{}); state["practice_clues"] != 3.0 || strings.Contains(string(b), "targets") {
+		t.Fatalf("new game = %s, want three clues without their targets", b)
+	}
+	g, _ := h.games.get("solo")
+	seed, plan := g.Seed, g.Practice
+	index := make(map[string]int)
+	for i, w := range g.Words {
+		index[w] = i
+	}
+	var tan int
+	for i, c := range g.OneLayout {
+		if c == Tan {
+			tan = i
+		}
+	}
+
+	guess := func(i int) {
+		post(t, h, "/guess", map[string]interface{}{"game_id": "solo", "seed": seed, "player_id": "solo", "team": 2, "index": i}, nil)
+	}
+	endTurn := func() {
+		post(t, h, "/end-turn", map[string]interface{}{"game_id": "solo", "seed": seed, "player_id": "solo", "team": 2}, nil)
+	}
+	results := func() (r PracticeResults) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/games/solo/practice", nil))
+		if rec.Code != 200 {
+			t.Fatalf("results status = %d, want 200", rec.Code)
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &r); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	// Find the first clue's targets, then pass on the rest. Guessing
+	// a tan on the second clue ends the turn, and the third clue's
+	// targets are all found.
+	for _, w := range plan[0].Targets {
+		guess(index[w])
+	}
+	endTurn()
+
+	// Players can't give clues or add bots, which would
+	// throw off the planned clues.
+	if code := post(t, h, "/clue", map[string]interface{}{"game_id": "solo", "seed": seed, "player_id": "solo", "team": 1, "word": "extra", "number": 1}, nil); code != 409 {
+		t.Errorf("clue in a practice game: status = %d, want 409", code)
+	}
+	h.guesser = &fakeGuesser{}
+	if code := post(t, h, "/games/solo/bots", map[string]interface{}{"seed": seed, "player_id": "alice", "team": 1}, nil); code != 409 {
+		t.Errorf("add bot to a practice game: status = %d, want 409", code)
+	}
+
+	guess(tan)
+	if r := results(); r.Finished || len(r.Rounds) != 3 || r.Rounds[0].Clue.Targets != nil {
+		t.Errorf("results before finishing = %+v", r)
+	}
+	for _, w := range plan[2].Targets {
+		guess(index[w])
+	}
+	endTurn()
+
+	r := results()
+	if !r.Finished || r.GreensFound != 6 || r.GreensTotal != 9 || r.TansGuessed != 1 || r.Score != 60 {
+		t.Errorf("results = %+v", r)
+	}
+	if len(r.Rounds) != 3 || r.Rounds[0].Correct != 3 || r.Rounds[1].Correct != 0 || len(r.Rounds[2].Clue.Targets) != 3 {
+		t.Errorf("rounds = %+v", r.Rounds)
+	}
+
+	newTestGame(t, h, "multi")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/games/multi/practice", nil))
+	if rec.Code != 400 {
+		t.Errorf("results for a regular game: status = %d, want 400", rec.Code)
+	}
+}
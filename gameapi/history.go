@@ -0,0 +1,286 @@
+package gameapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// GameHistory records the outcomes of games that have been won or
+// lost, from which players' lifetime statistics and the team
+// leaderboards are computed. Every instance records the games it
+// applies commands for, so instances that share games through a
+// broker keep the same history, and teams are created and deleted
+// with commands too.
+//
+// A history opened with OpenGameHistory is kept in a file, one JSON
+// record per line, so that it survives restarts. Otherwise it's only
+// kept in memory. Only one instance at a time may open the file.
+type GameHistory struct {
+	mu       sync.Mutex
+	f        *os.File // nil if the history is only kept in memory
+	results  []gameResult
+	seen     map[string]bool  // by the result's key
+	byPlayer map[string][]int // indexes into results
+	teams    map[string]*Team // by ID
+}
+
+// historyRecord is a line of the history's file.
+type historyRecord struct {
+	Result      *gameResult `json:"result,omitempty"`
+	Team        *Team       `json:"team,omitempty"`
+	DeletedTeam string      `json:"deleted_team,omitempty"`
+}
+
+// gameResult records how a game went.
+type gameResult struct {
+	GameID     string         `json:"game_id"`
+	Seed       Seed           `json:"seed"`
+	FinishedAt time.Time      `json:"finished_at"`
+	Won        bool           `json:"won"`
+	TokensUsed int            `json:"tokens_used"`
+	Players    []resultPlayer `json:"players"`
+}
+
+// resultPlayer records how a player played in a game.
+type resultPlayer struct {
+	PlayerID string `json:"player_id"`
+	Team     int    `json:"team"`
+	Guesses  int    `json:"guesses"`
+	Correct  int    `json:"correct"` // guesses that found a green card
+}
+
+// WithGameHistory configures the handler to record finished games
+// in hist. By default, each handler keeps a history in memory.
+func WithGameHistory(hist *GameHistory) Option {
+	return func(h *handler) {
+		h.history = hist
+	}
+}
+
+func newGameHistory() *GameHistory {
+	return &GameHistory{
+		seen:     make(map[string]bool),
+		byPlayer: make(map[string][]int),
+		teams:    make(map[string]*Team),
+	}
+}
+
+// OpenGameHistory opens the history at path, creating it if it
+// doesn't exist.
+func OpenGameHistory(path string) (*GameHistory, error) {
+	hist := newGameHistory()
+	err := replayLog(path, func(line []byte) error {
+		var r historyRecord
+		if err := json.Unmarshal(line, &r); err != nil {
+			return err
+		}
+		hist.apply(r)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("gameapi: reading %s: %w", path, err)
+	}
+	if hist.f, err = os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600); err != nil {
+		return nil, fmt.Errorf("gameapi: opening %s: %w", path, err)
+	}
+	return hist, nil
+}
+
+// Close closes the history's file, if it has one.
+func (hist *GameHistory) Close() error {
+	hist.mu.Lock()
+	defer hist.mu.Unlock()
+	if hist.f == nil {
+		return nil
+	}
+	return hist.f.Close()
+}
+
+// key identifies the game that the result is for. Replacing a
+// game changes its seed, so the same ID may be recorded again.
+func (r gameResult) key() string {
+	return r.GameID + "/" + strconv.FormatInt(int64(r.Seed), 10)
+}
+
+// record adds the result of a game, unless it's already been
+// recorded: brokers replay the commands they've retained to
+// instances that restart. A nil *GameHistory records nothing.
+func (hist *GameHistory) record(r gameResult) {
+	if hist == nil {
+		return
+	}
+	hist.mu.Lock()
+	defer hist.mu.Unlock()
+	if hist.seen[r.key()] {
+		return
+	}
+	if err := hist.write(historyRecord{Result: &r}); err != nil {
+		log.Printf("gameapi: recording game %s: %s", r.GameID, err)
+		hist.add(r)
+	}
+}
+
+// write appends a record to the history's file, if it has one,
+// and then applies it. The caller must hold hist.mu.
+func (hist *GameHistory) write(r historyRecord) error {
+	if hist.f != nil {
+		b, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		if _, err := hist.f.Write(append(b, '\n')); err != nil {
+			return err
+		}
+	}
+	hist.apply(r)
+	return nil
+}
+
+// apply applies a record to the history's results and teams.
+// The caller must hold hist.mu, if others may use it.
+func (hist *GameHistory) apply(r historyRecord) {
+	if r.Result != nil {
+		hist.add(*r.Result)
+	}
+	if t := r.Team; t != nil {
+		hist.teams[t.ID] = t
+	}
+	if r.DeletedTeam != "" {
+		delete(hist.teams, r.DeletedTeam)
+	}
+}
+
+// add adds a result to the history's indexes.
+// The caller must hold hist.mu, if others may use it.
+func (hist *GameHistory) add(r gameResult) {
+	if hist.seen[r.key()] {
+		return
+	}
+	hist.seen[r.key()] = true
+	hist.results = append(hist.results, r)
+	for _, p := range r.Players {
+		hist.byPlayer[p.PlayerID] = append(hist.byPlayer[p.PlayerID], len(hist.results)-1)
+	}
+}
+
+// result derives the game's result from its events. The players are
+// those who joined a side during the game, apart from bots, on the
+// side they last played. The caller must hold the game's mutex.
+func (g *Game) result(when time.Time, st Status) gameResult {
+	players := map[string]*resultPlayer{}
+	for _, e := range g.Events {
+		if e.PlayerID == "" || (e.Team != 1 && e.Team != 2) || g.players[e.PlayerID].Bot {
+			continue
+		}
+		switch e.Type {
+		case "join_side", "guess", "clue", "end_turn":
+		default:
+			continue
+		}
+		p, ok := players[e.PlayerID]
+		if !ok {
+			p = &resultPlayer{PlayerID: e.PlayerID}
+			players[e.PlayerID] = p
+		}
+		p.Team = e.Team
+		if e.Type == "guess" && e.Index >= 0 && e.Index < len(g.OneLayout) {
+			p.Guesses++
+			if g.layout(otherTeam(e.Team))[e.Index] == Green {
+				p.Correct++
+			}
+		}
+	}
+
+	r := gameResult{
+		GameID:     g.id,
+		Seed:       g.Seed,
+		FinishedAt: when,
+		Won:        st.Won,
+		TokensUsed: st.TokensConsumed,
+		Players:    []resultPlayer{},
+	}
+	for _, p := range players {
+		r.Players = append(r.Players, *p)
+	}
+	sort.Slice(r.Players, func(i, j int) bool { return r.Players[i].PlayerID < r.Players[j].PlayerID })
+	return r
+}
+
+// PlayerStats summarizes the games a player has won or lost. Losses
+// are games lost by revealing a black card, and accuracy is the share
+// of the player's guesses that found a green card. FavoriteSide is
+// the side, 1 for A or 2 for B, that the player has played the most
+// games on, or 0 if they've played as many on each. Streaks count
+// consecutive wins.
+type PlayerStats struct {
+	PlayerID       string  `json:"player_id"`
+	GamesPlayed    int     `json:"games_played"`
+	Wins           int     `json:"wins"`
+	Losses         int     `json:"losses"`
+	AvgTokensUsed  float64 `json:"avg_tokens_used"`
+	Guesses        int     `json:"guesses"`
+	CorrectGuesses int     `json:"correct_guesses"`
+	GuessAccuracy  float64 `json:"guess_accuracy"`
+	FavoriteSide   int     `json:"favorite_side"`
+	CurrentStreak  int     `json:"current_streak"`
+	LongestStreak  int     `json:"longest_streak"`
+}
+
+// playerStats summarizes the player's games, in the order
+// they finished.
+func (hist *GameHistory) playerStats(playerID string) PlayerStats {
+	hist.mu.Lock()
+	defer hist.mu.Unlock()
+
+	s := PlayerStats{PlayerID: playerID}
+	var tokens int
+	var sides [3]int
+	for _, i := range hist.byPlayer[playerID] {
+		r := hist.results[i]
+		for _, p := range r.Players {
+			if p.PlayerID != playerID {
+				continue
+			}
+			s.Guesses += p.Guesses
+			s.CorrectGuesses += p.Correct
+			sides[p.Team]++
+		}
+		s.GamesPlayed++
+		tokens += r.TokensUsed
+		if r.Won {
+			s.Wins++
+			s.CurrentStreak++
+			s.LongestStreak = max(s.LongestStreak, s.CurrentStreak)
+		} else {
+			s.Losses++
+			s.CurrentStreak = 0
+		}
+	}
+	if s.GamesPlayed > 0 {
+		s.AvgTokensUsed = float64(tokens) / float64(s.GamesPlayed)
+	}
+	if s.Guesses > 0 {
+		s.GuessAccuracy = float64(s.CorrectGuesses) / float64(s.Guesses)
+	}
+	switch {
+	case sides[1] > sides[2]:
+		s.FavoriteSide = 1
+	case sides[2] > sides[1]:
+		s.FavoriteSide = 2
+	}
+	return s
+}
+
+// GET /players/{id}/stats
+// Reports a player's lifetime statistics. Players who haven't
+// finished a game yet have no statistics, rather than not existing.
+func (h *handler) handlePlayerStats(rw http.ResponseWriter, req *http.Request) {
+	writeJSON(rw, h.history.playerStats(req.PathValue("id")))
+}
@@ -0,0 +1,90 @@
+package gameapi
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// playToWin guesses green cards, in turn, until the game is won.
+func playToWin(g *Game, players map[int]string, now time.Time) {
+	for st, n := g.status(), 0; !st.Won && n < 100; st, n = g.status(), n+1 {
+		team := st.Turn
+		if team == 0 {
+			team = 1
+		}
+		guessed := false
+		for i, c := range g.layout(otherTeam(team)) {
+			if c == Green && !st.Revealed[otherTeam(team)-1][i] && g.exposedColor(i, st.Revealed) != Green {
+				g.guess(players[team], players[team], team, i, now)
+				guessed = true
+				break
+			}
+		}
+		if !guessed {
+			g.endTurn(players[team], players[team], team, now)
+		}
+	}
+}
+
+func TestPlayerStats(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	hist, err := OpenGameHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	newGame := func(id string, seed int64) *Game {
+		g, err := ReconstructGame(NewState(seed, exampleWords))
+		if err != nil {
+			t.Fatal(err)
+		}
+		g.id, g.history = id, hist
+		g.markSeen("alice", "Alice", 1, now)
+		g.markSeen("bob", "Bob", 2, now)
+		return &g
+	}
+
+	won := newGame("first", 1)
+	playToWin(won, map[int]string{1: "alice", 2: "bob"}, now)
+	lost := newGame("second", 2)
+	for i, c := range lost.TwoLayout {
+		if c == Black {
+			lost.guess("alice", "Alice", 1, i, now)
+			break
+		}
+	}
+	// Games ended by admins aren't counted.
+	ended := newGame("third", 3)
+	ended.end("admin", now)
+
+	want := hist.playerStats("alice")
+	if want.GamesPlayed != 2 || want.Wins != 1 || want.Losses != 1 || want.FavoriteSide != 1 ||
+		want.CurrentStreak != 0 || want.LongestStreak != 1 || want.Guesses == 0 || want.GuessAccuracy >= 1 {
+		t.Errorf("alice's stats = %+v, want a win, a loss and a wrong guess on side A", want)
+	}
+	if bob := hist.playerStats("bob"); bob.GamesPlayed != 2 || bob.FavoriteSide != 2 || bob.GuessAccuracy != 1 {
+		t.Errorf("bob's stats = %+v, want two games on side B without a wrong guess", bob)
+	}
+
+	// The history survives restarts, and games aren't
+	// recorded twice when the broker replays them.
+	hist.Close()
+	if hist, err = OpenGameHistory(path); err != nil {
+		t.Fatal(err)
+	}
+	defer hist.Close()
+	hist.record(won.result(now, won.status()))
+
+	clock := NewFakeClock(now)
+	h := newTestHandler(clock)
+	h.history = hist
+	var got PlayerStats
+	if code := request(context.Background(), t, h, "GET", "/players/alice/stats", nil, &got); code != 200 {
+		t.Fatalf("GET /players/alice/stats: status = %d, want 200", code)
+	}
+	if got != want {
+		t.Errorf("alice's stats after reopening = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,322 @@
+package gameapi
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// PlayerKeyHeader is the request header that carries the player's
+// key: a secret that the client chooses and sends with each request.
+// A player is bound to the key that they join a game with. Player
+// IDs are public, so a game's host proves who they are with their
+// key, or with their account's session if they're signed in.
+const PlayerKeyHeader = "X-Player-Key"
+
+// minPlayerKeyLength is the shortest player key, in bytes.
+const minPlayerKeyLength = 16
+
+// The operations that only a game's host may perform,
+// besides muting players.
+const (
+	opHostKick     = "host_kick"
+	opTransferHost = "transfer_host"
+	opSettings     = "settings"
+)
+
+var (
+	errNotHost     = &apiError{"not_host", "Only the game's host may do that.", 403}
+	errTeamsLocked = &apiError{"teams_locked", "The host has locked the teams.", 403}
+	errShortKey    = &apiError{"player_key_too_short",
+		"Player keys must be at least " + strconv.Itoa(minPlayerKeyLength) + " bytes.", 400}
+)
+
+// Settings are the options that a game's host controls.
+type Settings struct {
+	// TeamsLocked keeps players from switching sides. Players
+	// keep their sides when a locked game is replaced.
+	TeamsLocked bool `json:"teams_locked"`
+
+	// WordList names the word list that replacement games use
+	// when the request doesn't choose their words. If it's empty,
+	// they use all of the word lists.
+	WordList string `json:"word_list,omitempty"`
+
+	// Private is true if the game has a passcode. Requests for
+	// private games must include the passcode or an invite.
+	Private bool `json:"private"`
+
+	// Public and Name are chosen when the game is created. Public
+	// games that aren't private are listed in the lobby by name.
+	Public bool   `json:"public"`
+	Name   string `json:"name,omitempty"`
+}
+
+// POST /v2/games/{id}/kicks
+// Removes a player from the game. Only the game's host may kick
+// players, and they can't rejoin the game or its replacements.
+func (h *handler) handleKick(rw http.ResponseWriter, req *http.Request) {
+	h.handleHostAction(rw, req, opHostKick)
+}
+
+// PUT /v2/games/{id}/host
+// Makes another player the game's host.
+func (h *handler) handleTransferHost(rw http.ResponseWriter, req *http.Request) {
+	h.handleHostAction(rw, req, opTransferHost)
+}
+
+// handleHostAction handles requests in which the
+// host acts on another player.
+func (h *handler) handleHostAction(rw http.ResponseWriter, req *http.Request, op string) {
+	var body TargetRequest
+	err := decodePlayerRequest(req, &body, &body.PlayerRequest)
+	if err != nil || body.GameID == "" || body.PlayerID == "" || body.TargetID == "" {
+		writeError(rw, "malformed_body", "Unable to parse request body.", 400)
+		return
+	}
+
+	cmd := body.command(op)
+	cmd.TargetID = body.TargetID
+	resp, err := h.exec(req.Context(), cmd)
+	writeResult(rw, resp, err)
+}
+
+// PATCH /v2/games/{id}/settings
+// Changes the game's settings, leaving out any that the request
+// omits, and returns them.
+func (h *handler) handleSettings(rw http.ResponseWriter, req *http.Request) {
+	var body SettingsRequest
+	err := decodePlayerRequest(req, &body, &body.PlayerRequest)
+	if err != nil || body.GameID == "" || body.PlayerID == "" {
+		writeError(rw, "malformed_body", "Unable to parse request body.", 400)
+		return
+	}
+	if l := body.WordList; l != nil && *l != "" {
+		if _, ok := h.wordLists[*l]; !ok {
+			writeError(rw, "unknown_word_list", "No word list named "+*l+".", 400)
+			return
+		}
+	}
+
+	cmd := body.command(opSettings)
+	if p := body.Passcode; p != nil {
+		// Only the passcode's hash is published.
+		cmd.Passcode = &passcode{}
+		if *p != "" {
+			if cmd.Passcode, err = newPasscode(*p); err != nil {
+				writeResult(rw, nil, err)
+				return
+			}
+		}
+		body.Passcode = nil
+	}
+	cmd.Settings = &body.SettingsChange
+	resp, err := h.exec(req.Context(), cmd)
+	writeResult(rw, resp, err)
+}
+
+// playerKeyKey is the context key of the hash of the request's
+// player key.
+type playerKeyKey struct{}
+
+// readPlayerKey adds the hash of the request's player key, if it
+// has one, to its context. Only the hash is published to the broker.
+// It answers requests with keys that are too short to be secret.
+func readPlayerKey(rw http.ResponseWriter, req *http.Request) (*http.Request, bool) {
+	key := req.Header.Get(PlayerKeyHeader)
+	if key == "" {
+		return req, false
+	}
+	if len(key) < minPlayerKeyLength {
+		writeResult(rw, nil, errShortKey)
+		return req, true
+	}
+	sum := sha256.Sum256([]byte(key))
+	return req.WithContext(context.WithValue(req.Context(), playerKeyKey{}, sum[:])), false
+}
+
+// playerKey returns the hash of the player key sent with the request
+// that ctx belongs to, and true if the request was made with the
+// session of playerID's account.
+func playerKey(ctx context.Context, playerID string) (key []byte, session bool) {
+	key, _ = ctx.Value(playerKeyKey{}).([]byte)
+	a, ok := ctx.Value(accountKey{}).(Account)
+	return key, ok && playerID != "" && a.PlayerID == playerID
+}
+
+// withPlayer returns a copy of ctx for work done on behalf of a
+// player other than the one who made the request, with the hash
+// of that player's key and their account.
+func withPlayer(ctx context.Context, key []byte, a Account) context.Context {
+	return context.WithValue(context.WithValue(ctx, playerKeyKey{}, key), accountKey{}, a)
+}
+
+// isHost returns true if playerID is the game's host and the request
+// was made with the host's player key or account session. The caller
+// must hold g.mu.
+func (g *Game) isHost(playerID string, key []byte, session bool) bool {
+	if playerID == "" || playerID != g.Host {
+		return false
+	}
+	return session || (len(g.hostKey) > 0 && subtle.ConstantTimeCompare(key, g.hostKey) == 1)
+}
+
+// sideOf returns the side whose messages the request may see: the
+// player's side, if the request was made with their key or account
+// session, or otherwise 0. Player IDs appear in every event, so they
+// don't prove who's asking. The caller must hold g.mu.
+func (g *Game) sideOf(playerID string, key []byte, session bool) int {
+	p, ok := g.players[playerID]
+	if !ok || !session && (len(p.key) == 0 || subtle.ConstantTimeCompare(key, p.key) != 1) {
+		return 0
+	}
+	return p.Team
+}
+
+// bindKey binds a player who has just joined the game to their key.
+// If they're the host and the host has no key yet, it becomes the
+// host's key. The caller must hold g.mu.
+func (g *Game) bindKey(playerID string, key []byte) {
+	p, ok := g.players[playerID]
+	if !ok || p.Bot {
+		return
+	}
+	p.key = key
+	g.players[playerID] = p
+	if g.Host == playerID && g.hostKey == nil {
+		g.hostKey = key
+	}
+}
+
+// checkReplace returns errNotHost unless the request to replace the
+// game comes from its host, or the game has no host. It applies the
+// game's settings to the request. The caller must hold g.mu.
+func (g *Game) checkReplace(body *NewGameRequest, key []byte, session bool) error {
+	if g.Host != "" && !g.isHost(body.PlayerID, key, session) {
+		return errNotHost
+	}
+	if len(body.Words) == 0 && body.WordList == "" {
+		body.WordList = g.Settings.WordList
+	}
+	return nil
+}
+
+// checkSide returns errTeamsLocked if the teams are locked and
+// the player is switching sides. Players who haven't joined a
+// side yet may still join one.
+func (g *Game) checkSide(playerID string, team int) error {
+	p, ok := g.players[playerID]
+	if g.Settings.TeamsLocked && ok && team != 0 && p.Team != 0 && p.Team != team {
+		return errTeamsLocked
+	}
+	return nil
+}
+
+// hostKick removes a player from the game on behalf of the host.
+func (g *Game) hostKick(hostID, hostName, targetID string, when time.Time) error {
+	if hostID != g.Host {
+		return errNotHost
+	}
+	if targetID == hostID {
+		return &apiError{"bad_target", "The host can't kick themselves.", 400}
+	}
+	return g.kick(targetID, hostName, when)
+}
+
+// transferHost makes another player the host on behalf of the host.
+func (g *Game) transferHost(hostID, hostName, targetID string) error {
+	if hostID != g.Host {
+		return errNotHost
+	}
+	p, ok := g.players[targetID]
+	if !ok {
+		return errPlayerNotFound
+	}
+	if p.Bot {
+		return &apiError{"bad_target", "Bots can't host games.", 400}
+	}
+	if targetID != hostID {
+		g.setHost(targetID, hostName)
+	}
+	return nil
+}
+
+// changeSettings changes the game's settings on behalf of the host,
+// recording an event for each setting that changes. If pc is non-nil,
+// it replaces the game's passcode.
+func (g *Game) changeSettings(hostID, hostName string, change SettingsChange, pc *passcode) (Settings, error) {
+	if hostID != g.Host {
+		return g.Settings, errNotHost
+	}
+	team := g.players[hostID].Team
+	if l := change.TeamsLocked; l != nil && *l != g.Settings.TeamsLocked {
+		g.Settings.TeamsLocked = *l
+		typ := "teams_unlocked"
+		if *l {
+			typ = "teams_locked"
+		}
+		g.addEvent(Event{Type: typ, PlayerID: hostID, Name: hostName, Team: team})
+	}
+	if l := change.WordList; l != nil && *l != g.Settings.WordList {
+		g.Settings.WordList = *l
+		g.addEvent(Event{
+			Type:     "word_list_changed",
+			PlayerID: hostID,
+			Name:     hostName,
+			Team:     team,
+			Message:  *l,
+		})
+	}
+	if pc != nil {
+		g.setPasscode(hostName, pc)
+	}
+	return g.Settings, nil
+}
+
+// setHost makes a player the host and records the change. by is the
+// name of the host who handed it over, or empty if the previous
+// host left.
+func (g *Game) setHost(playerID, by string) {
+	p := g.players[playerID]
+	g.Host, g.hostKey = playerID, p.key
+	g.addEvent(Event{
+		Type:     "host_changed",
+		PlayerID: playerID,
+		Name:     p.Name,
+		Team:     p.Team,
+		Message:  by,
+	})
+}
+
+// hostGone returns true if the game's host is no longer one of
+// its players. A game's creator is given time to join it before
+// they're considered gone. The caller must hold g.mu.
+func (g *Game) hostGone(now time.Time) bool {
+	if g.Host == "" {
+		return false
+	}
+	_, ok := g.players[g.Host]
+	return !ok && g.CreatedAt.Add(playerTimeout).Before(now)
+}
+
+// passHost hands the game over to another player after its host
+// leaves. The player with the lowest ID takes over so that every
+// instance agrees. If only bots remain, the next player seen
+// becomes the host.
+func (g *Game) passHost() {
+	g.Host, g.hostKey = "", nil
+	var ids []string
+	for id, p := range g.players {
+		if !p.Bot {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) > 0 {
+		sort.Strings(ids)
+		g.setHost(ids[0], "")
+	}
+}
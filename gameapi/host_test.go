@@ -0,0 +1,163 @@
+package gameapi
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func withKey(h http.Handler, key string) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		req.Header.Set(PlayerKeyHeader, key)
+		h.ServeHTTP(rw, req)
+	})
+}
+
+// keyed returns a handler that makes requests with the player's key.
+func keyed(h http.Handler, playerID string) http.Handler {
+	return withKey(h, playerID+"'s key, kept secret")
+}
+
+func TestHost(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	h := newTestHandler(clock)
+	ctx := context.Background()
+	alice, bob := keyed(h, "alice"), keyed(h, "bob")
+
+	var game struct {
+		State GameState `json:"state"`
+	}
+	if code := request(ctx, t, alice, "POST", "/v2/games", map[string]interface{}{"game_id": "hosted", "player_id": "alice"}, &game); code != 201 {
+		t.Fatalf("POST /v2/games: status = %d, want 201", code)
+	}
+	seed := game.State.Seed
+	player := func(id string, team int, fields ...interface{}) map[string]interface{} {
+		m := map[string]interface{}{"seed": seed, "player_id": id, "name": id, "team": team}
+		for i := 0; i < len(fields); i += 2 {
+			m[fields[i].(string)] = fields[i+1]
+		}
+		return m
+	}
+	for _, p := range []string{"bob", "alice", "mallory"} {
+		request(ctx, t, keyed(h, p), "PUT", "/v2/games/hosted/players/"+p, player(p, 1), nil)
+	}
+
+	// Only the host may change the game's settings, kick players or
+	// hand the game over, and player IDs are public, so they have to
+	// send their key.
+	for _, r := range []struct {
+		method, path string
+		body         map[string]interface{}
+	}{
+		{"PATCH", "/v2/games/hosted/settings", player("bob", 1, "teams_locked", true)},
+		{"POST", "/v2/games/hosted/kicks", player("bob", 1, "target_id", "mallory")},
+		{"PUT", "/v2/games/hosted/host", player("bob", 1, "target_id", "bob")},
+		{"PUT", "/v2/games/hosted", map[string]interface{}{"prev_seed": seed, "player_id": "bob"}},
+	} {
+		if code := request(ctx, t, bob, r.method, r.path, r.body, nil); code != 403 {
+			t.Errorf("%s %s by a guest: status = %d, want 403", r.method, r.path, code)
+		}
+	}
+	for _, key := range []string{"", "mallory's key, kept secret"} {
+		body := player("alice", 1, "teams_locked", true)
+		if code := request(ctx, t, withKey(h, key), "PATCH", "/v2/games/hosted/settings", body, nil); code != 403 {
+			t.Errorf("PATCH settings as the host with key %q: status = %d, want 403", key, code)
+		}
+	}
+	if code := request(ctx, t, withKey(h, "short"), "PUT", "/v2/games/hosted/players/carol", player("carol", 2), nil); code != 400 {
+		t.Errorf("joining with a short key: status = %d, want 400", code)
+	}
+	clock.Advance(5 * time.Second) // for alice's rate limit
+
+	var settings Settings
+	if code := request(ctx, t, alice, "PATCH", "/v2/games/hosted/settings", player("alice", 1, "teams_locked", true, "word_list", "example"), &settings); code != 200 {
+		t.Fatalf("PATCH settings: status = %d, want 200", code)
+	}
+	if !settings.TeamsLocked || settings.WordList != "example" {
+		t.Errorf("settings = %+v, want locked teams and the example word list", settings)
+	}
+	if code := request(ctx, t, alice, "PATCH", "/v2/games/hosted/settings", player("alice", 1, "word_list", "missing"), nil); code != 400 {
+		t.Errorf("PATCH settings with an unknown word list: status = %d, want 400", code)
+	}
+	if code := request(ctx, t, bob, "PUT", "/v2/games/hosted/players/bob", player("bob", 2), nil); code != 403 {
+		t.Errorf("switching sides while the teams are locked: status = %d, want 403", code)
+	}
+	if code := request(ctx, t, h, "PUT", "/v2/games/hosted/players/carol", player("carol", 2), nil); code != 200 {
+		t.Errorf("joining a side while the teams are locked: status = %d, want 200", code)
+	}
+
+	if code := request(ctx, t, alice, "POST", "/v2/games/hosted/kicks", player("alice", 1, "target_id", "mallory"), nil); code != 200 {
+		t.Fatalf("kick: status = %d, want 200", code)
+	}
+	if code := request(ctx, t, h, "PUT", "/v2/games/hosted/players/mallory", player("mallory", 1), nil); code != 403 {
+		t.Errorf("kicked player's ping: status = %d, want 403", code)
+	}
+
+	// The new game keeps the host, its settings and, since
+	// they're locked, the teams.
+	if code := request(ctx, t, alice, "PUT", "/v2/games/hosted", map[string]interface{}{"prev_seed": seed, "player_id": "alice"}, &game); code != 201 {
+		t.Fatalf("PUT by the host: status = %d, want 201", code)
+	}
+	if game.State.Host != "alice" || !game.State.Settings.TeamsLocked || game.State.Settings.WordList != "example" {
+		t.Errorf("new game's host = %q, settings = %+v", game.State.Host, game.State.Settings)
+	}
+	sides := map[string]int{}
+	for _, e := range game.State.Events {
+		if e.Type == "join_side" {
+			sides[e.PlayerID] = e.Team
+		}
+	}
+	if len(sides) != 3 || sides["alice"] != 1 || sides["bob"] != 1 || sides["carol"] != 2 {
+		t.Errorf("new game's sides = %v, want the old game's", sides)
+	}
+
+	seed = game.State.Seed
+	if code := request(ctx, t, alice, "PUT", "/v2/games/hosted/host", player("alice", 1, "target_id", "bob"), nil); code != 200 {
+		t.Fatalf("transfer: status = %d, want 200", code)
+	}
+	g, _ := h.games.get("hosted")
+	g.mu.Lock()
+	last := g.Events[len(g.Events)-1]
+	g.mu.Unlock()
+	if last.Type != "host_changed" || last.PlayerID != "bob" || last.Message != "alice" {
+		t.Errorf("last event = %+v, want alice to make bob the host", last)
+	}
+	if code := request(ctx, t, alice, "PATCH", "/v2/games/hosted/settings", player("alice", 1, "teams_locked", false), nil); code != 403 {
+		t.Errorf("PATCH settings by the former host: status = %d, want 403", code)
+	}
+	if code := request(ctx, t, bob, "PATCH", "/v2/games/hosted/settings", player("bob", 1, "teams_locked", false), nil); code != 200 {
+		t.Errorf("PATCH settings by the new host: status = %d, want 200", code)
+	}
+}
+
+func TestHostLeaves(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	h := newTestHandler(clock)
+	h.adminToken = "secret"
+	seed := newTestGame(t, h, "handoff")
+	for _, p := range []string{"alice", "carol", "bob"} {
+		post(t, keyed(h, p), "/ping", map[string]interface{}{"game_id": "handoff", "seed": seed, "player_id": p, "name": p, "team": 1}, nil)
+	}
+
+	// The player with the lowest ID takes over.
+	if code := request(context.Background(), t, withToken(h, "secret"), "DELETE", "/admin/games/handoff/players/alice", nil, nil); code != 200 {
+		t.Fatalf("kick: status = %d, want 200", code)
+	}
+	g, _ := h.games.get("handoff")
+	g.mu.Lock()
+	host, last := g.Host, g.Events[len(g.Events)-1]
+	g.mu.Unlock()
+	if host != "bob" || last.Type != "host_changed" || last.PlayerID != "bob" {
+		t.Errorf("host = %q, last event = %+v; want bob to take over", host, last)
+	}
+
+	// They act as the host with the key that they joined with.
+	lock := map[string]interface{}{"seed": seed, "player_id": "bob", "teams_locked": true}
+	if code := request(context.Background(), t, keyed(h, "carol"), "PATCH", "/v2/games/handoff/settings", lock, nil); code != 403 {
+		t.Errorf("PATCH settings with another player's key: status = %d, want 403", code)
+	}
+	if code := request(context.Background(), t, keyed(h, "bob"), "PATCH", "/v2/games/handoff/settings", lock, nil); code != 200 {
+		t.Errorf("PATCH settings by the new host: status = %d, want 200", code)
+	}
+}
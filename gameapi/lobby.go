@@ -0,0 +1,162 @@
+package gameapi
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// maxNameLength is the longest name, in characters, that a public
+// game may be listed under or that an account may be displayed as.
+const maxNameLength = 50
+
+// lobby wakes clients waiting for the list of public games to
+// change. Rather than track the listing itself, it's notified of
+// any change to a game that might affect it, and the waiting
+// clients rebuild the listing to see whether it has.
+type lobby struct {
+	mu      sync.Mutex
+	changed chan struct{}
+}
+
+func newLobby() *lobby {
+	return &lobby{changed: make(chan struct{})}
+}
+
+// notify wakes the clients waiting for the lobby to change.
+func (l *lobby) notify() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// wait returns a channel that's closed when the lobby may have changed.
+func (l *lobby) wait() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.changed
+}
+
+// moderateName checks the name that a public game is listed under,
+// or an account's display name, and filters it like a chat message.
+func (h *handler) moderateName(name string) (string, error) {
+	if utf8.RuneCountInString(name) > maxNameLength {
+		return "", &apiError{"name_too_long",
+			"Names may be at most " + strconv.Itoa(maxNameLength) + " characters.", 400}
+	}
+	if h.chatFilter == nil || name == "" {
+		return name, nil
+	}
+	name, ok := h.chatFilter.Filter(name)
+	if !ok {
+		return "", &apiError{"name_rejected", "The name contains words that aren't allowed.", 400}
+	}
+	return name, nil
+}
+
+// GET /lobby
+// Lists the public games that have players. If the version query
+// parameter matches the current listing's version, it waits for the
+// listing to change before responding.
+func (h *handler) handleLobby(rw http.ResponseWriter, req *http.Request) {
+	version := req.URL.Query().Get("version")
+	var timeout <-chan time.Time
+	for {
+		// Wait on the lobby before building the listing
+		// so that changes in between aren't missed.
+		ch := h.lobby.wait()
+		resp := h.lobbyListing(h.clock.Now())
+		if resp.Version != version {
+			writeJSON(rw, resp)
+			return
+		}
+
+		if timeout == nil {
+			timeout = h.clock.After(25 * time.Second)
+		}
+		select {
+		case <-ch:
+			continue
+		case <-req.Context().Done():
+		case <-timeout:
+		}
+		writeJSON(rw, resp)
+		return
+	}
+}
+
+// lobbyListing lists the public games, those looking for players
+// first and then those with the most players.
+func (h *handler) lobbyListing(now time.Time) LobbyResponse {
+	resp := LobbyResponse{Games: []LobbyGame{}}
+	h.games.each(func(id string, g *Game) {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if !g.Settings.Public || g.passcode != nil || !g.hasHumans(now) {
+			return
+		}
+		resp.Games = append(resp.Games, g.lobbyGame(id, now))
+	})
+	sort.Slice(resp.Games, func(i, j int) bool {
+		a, b := resp.Games[i], resp.Games[j]
+		if a.LookingForPlayers != b.LookingForPlayers {
+			return a.LookingForPlayers
+		}
+		if n, m := a.PlayersA+a.PlayersB, b.PlayersA+b.PlayersB; n != m {
+			return n > m
+		}
+		return a.ID < b.ID
+	})
+
+	b, err := json.Marshal(resp.Games)
+	if err != nil {
+		panic(err)
+	}
+	sum := fnv.New64a()
+	sum.Write(b)
+	resp.Version = strconv.FormatUint(sum.Sum64(), 36)
+	return resp
+}
+
+// lobbyGame describes the game for the lobby. A game is looking for
+// players until both sides have someone on them, unless it's over or
+// it's a practice game. The caller must hold g.mu.
+func (g *Game) lobbyGame(id string, now time.Time) LobbyGame {
+	lg := LobbyGame{
+		ID:        id,
+		Name:      g.Settings.Name,
+		Mode:      "standard",
+		WordList:  g.WordList,
+		CreatedAt: g.CreatedAt,
+	}
+	if lg.Name == "" {
+		lg.Name = id
+	}
+	humans := g.hasHumans(now)
+	for _, p := range g.players {
+		if p.gone(now, humans) {
+			continue
+		}
+		if p.Bot {
+			lg.Mode = "bots"
+		}
+		switch p.Team {
+		case 1:
+			lg.PlayersA++
+		case 2:
+			lg.PlayersB++
+		}
+	}
+	if len(g.Practice) > 0 {
+		lg.Mode = "practice"
+	}
+	lg.LookingForPlayers = lg.Mode != "practice" && !g.status().Finished() &&
+		(lg.PlayersA == 0 || lg.PlayersB == 0)
+	return lg
+}
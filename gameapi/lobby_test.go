@@ -0,0 +1,89 @@
+package gameapi
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLobby(t *testing.T) {
+	defer func(c argon2Cost) { passwordCost = c }(passwordCost)
+	passwordCost = argon2Cost{Time: 1, Memory: 8, Threads: 1}
+
+	clock := NewFakeClock(time.Now())
+	h := newTestHandler(clock)
+	ctx := context.Background()
+
+	seeds := map[string]Seed{}
+	for _, g := range []map[string]interface{}{
+		{"game_id": "open", "player_id": "alice", "public": true, "name": "Friday night", "word_list": "example"},
+		{"game_id": "unlisted", "player_id": "alice"},
+		{"game_id": "secret", "player_id": "alice", "public": true, "passcode": "hunter2"},
+	} {
+		var game struct {
+			State GameState `json:"state"`
+		}
+		if code := request(ctx, t, h, "POST", "/v2/games", g, &game); code != 201 {
+			t.Fatalf("POST /v2/games %v: status = %d, want 201", g, code)
+		}
+		seeds[g["game_id"].(string)] = game.State.Seed
+	}
+	for id, seed := range seeds {
+		request(ctx, t, withAccess(h, "hunter2"), "PUT", "/v2/games/"+id+"/players/alice",
+			map[string]interface{}{"seed": seed, "player_id": "alice", "name": "alice", "team": 1}, nil)
+	}
+
+	var lobby LobbyResponse
+	if code := request(ctx, t, h, "GET", "/lobby", nil, &lobby); code != 200 {
+		t.Fatalf("GET /lobby: status = %d, want 200", code)
+	}
+	want := LobbyGame{
+		ID:                "open",
+		Name:              "Friday night",
+		Mode:              "standard",
+		WordList:          "example",
+		PlayersA:          1,
+		LookingForPlayers: true,
+	}
+	if len(lobby.Games) != 1 {
+		t.Fatalf("lobby = %+v, want only the open game", lobby.Games)
+	}
+	got := lobby.Games[0]
+	got.CreatedAt = time.Time{}
+	if got != want {
+		t.Errorf("lobby game = %+v, want %+v", got, want)
+	}
+
+	// Clients with the current listing wait for it to change.
+	done := make(chan LobbyResponse)
+	go func() {
+		var update LobbyResponse
+		request(ctx, t, h, "GET", "/lobby?version="+lobby.Version, nil, &update)
+		done <- update
+	}()
+	clock.BlockUntil(2) // the pruning ticker and the lobby's timeout
+	request(ctx, t, h, "PUT", "/v2/games/open/players/bob",
+		map[string]interface{}{"seed": seeds["open"], "player_id": "bob", "name": "bob", "team": 2}, nil)
+	update := <-done
+	if update.Version == lobby.Version || len(update.Games) != 1 || update.Games[0].PlayersB != 1 || update.Games[0].LookingForPlayers {
+		t.Errorf("lobby after bob joined = %+v, want bob on side B", update)
+	}
+
+	// Otherwise they give up waiting eventually.
+	go func() {
+		var unchanged LobbyResponse
+		request(ctx, t, h, "GET", "/lobby?version="+update.Version, nil, &unchanged)
+		done <- unchanged
+	}()
+	clock.BlockUntil(3) // including the first request's timeout, which never fired
+	clock.Advance(30 * time.Second)
+	if unchanged := <-done; unchanged.Version != update.Version {
+		t.Errorf("lobby version = %q, want %q", unchanged.Version, update.Version)
+	}
+
+	// Games leave the lobby once their players have gone.
+	clock.Advance(time.Minute)
+	if request(ctx, t, h, "GET", "/lobby", nil, &lobby); len(lobby.Games) != 0 {
+		t.Errorf("lobby = %+v, want no games", lobby.Games)
+	}
+}
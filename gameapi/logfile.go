@@ -0,0 +1,35 @@
+package gameapi
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// replayLog calls fn with each line of the log file at path, whose
+// lines are JSON records. A crash may leave the last line incomplete,
+// so it's skipped if fn can't parse it, but any other line that can't
+// be parsed is an error. A log that doesn't exist yet is empty.
+func replayLog(path string, fn func(line []byte) error) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1<<20)
+	var bad error
+	for line := 1; s.Scan(); line++ {
+		if bad != nil {
+			return bad
+		}
+		if err := fn(s.Bytes()); err != nil {
+			bad = fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	return s.Err()
+}
@@ -0,0 +1,270 @@
+package gameapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxLanguageLength is the longest language tag, in bytes, that
+// a player may ask to be matched by.
+const maxLanguageLength = 35
+
+var (
+	errMatchKeyRequired = &apiError{"player_key_required",
+		"Send a player key, or sign in, to look for a partner.", 400}
+	errAlreadyQueued = &apiError{"already_queued",
+		"That player is already looking for a partner from another client.", 409}
+)
+
+// matchmaker pairs players who are looking for a partner. Its queue
+// is held by each instance independently, so players are only paired
+// with players queued on the same instance; deployments that run
+// several instances should route matchmaking to one of them. The
+// games it creates are shared like any other.
+type matchmaker struct {
+	mu      sync.Mutex
+	waiting []*matchEntry          // unpaired players, longest waiting first
+	players map[string]*matchEntry // by ID, including paired players who haven't heard yet
+}
+
+// A matchEntry is a player in the queue.
+type matchEntry struct {
+	MatchmakingRequest
+	lastSeen time.Time
+	paired   bool
+
+	// key and account are the hash of the player's key and their
+	// account, if they're signed in, for creating the game on their
+	// behalf.
+	key     []byte
+	account Account
+
+	// matched is closed once resp and err are set.
+	matched chan struct{}
+	resp    MatchmakingResponse
+	err     error
+}
+
+func newMatchmaker() *matchmaker {
+	return &matchmaker{players: make(map[string]*matchEntry)}
+}
+
+// compatible returns true if the players' preferences agree.
+// Preferences that are empty match anything.
+func (e *matchEntry) compatible(o *matchEntry) bool {
+	agree := func(a, b string) bool { return a == "" || b == "" || a == b }
+	return e.PlayerID != o.PlayerID && agree(e.WordList, o.WordList) && agree(e.Language, o.Language)
+}
+
+// queuedBy returns true if a request made with the key and account
+// comes from the player who joined the queue as e.
+func (e *matchEntry) queuedBy(key []byte, account Account) bool {
+	if e.account.Username != "" {
+		return account.Username == e.account.Username
+	}
+	return len(e.key) > 0 && subtle.ConstantTimeCompare(key, e.key) == 1
+}
+
+// join adds the player to the queue, or updates their preferences if
+// they're already in it, and returns their entry. If there's someone
+// for them to play with, it also returns the pair of players, longest
+// waiting first, which the caller must finish. Player IDs are public,
+// so only the player who joined the queue, with the same key or
+// account, may update their entry; others get errAlreadyQueued.
+func (m *matchmaker) join(req MatchmakingRequest, key []byte, account Account, now time.Time) (e *matchEntry, pair []*matchEntry, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expire(now)
+
+	e, ok := m.players[req.PlayerID]
+	if ok && !e.queuedBy(key, account) {
+		return nil, nil, errAlreadyQueued
+	}
+	if !ok {
+		e = &matchEntry{matched: make(chan struct{})}
+		m.players[req.PlayerID] = e
+		m.waiting = append(m.waiting, e)
+	}
+	e.lastSeen = now
+	if e.paired {
+		return e, nil, nil
+	}
+	e.MatchmakingRequest = req
+	e.key, e.account = key, account
+
+	for _, o := range m.waiting {
+		if o == e || !o.compatible(e) {
+			continue
+		}
+		// The queue is in order, so whichever of the two
+		// comes first has been waiting longer.
+		for _, w := range m.waiting {
+			if w == e || w == o {
+				pair = append(pair, w)
+			}
+		}
+		for _, p := range pair {
+			p.paired = true
+			m.remove(p)
+		}
+		return e, pair, nil
+	}
+	return e, nil, nil
+}
+
+// finish records the outcome for a player who has been paired.
+func (m *matchmaker) finish(e *matchEntry, resp MatchmakingResponse, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e.resp, e.err = resp, err
+	close(e.matched)
+}
+
+// result returns the outcome for the player, if they've been paired,
+// and removes them from the queue.
+func (m *matchmaker) result(e *matchEntry) (resp MatchmakingResponse, ok bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	select {
+	case <-e.matched:
+	default:
+		return resp, false, nil
+	}
+	if m.players[e.PlayerID] == e {
+		delete(m.players, e.PlayerID)
+	}
+	return e.resp, true, e.err
+}
+
+// leave removes the player from the queue unless they've been paired.
+func (m *matchmaker) leave(e *matchEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !e.paired && m.players[e.PlayerID] == e {
+		delete(m.players, e.PlayerID)
+		m.remove(e)
+	}
+}
+
+// expire removes players who have stopped asking for a partner.
+// The caller must hold m.mu.
+func (m *matchmaker) expire(now time.Time) {
+	for id, e := range m.players {
+		if e.lastSeen.Add(playerTimeout).Before(now) {
+			delete(m.players, id)
+			m.remove(e)
+		}
+	}
+}
+
+// remove removes the entry from the waiting players.
+// The caller must hold m.mu.
+func (m *matchmaker) remove(e *matchEntry) {
+	for i, w := range m.waiting {
+		if w == e {
+			m.waiting = append(m.waiting[:i], m.waiting[i+1:]...)
+			return
+		}
+	}
+}
+
+// POST /matchmaking/join
+// Queues the player until there's someone for them to play with, and
+// then creates a game for the two of them. It waits a while for a
+// partner before responding that the player is still waiting; the
+// player keeps their place in the queue by repeating the request
+// with the same player key or session.
+func (h *handler) handleMatchmaking(rw http.ResponseWriter, req *http.Request) {
+	var body MatchmakingRequest
+	err := json.NewDecoder(req.Body).Decode(&body)
+	if err != nil || body.PlayerID == "" || len(body.Language) > maxLanguageLength {
+		writeError(rw, "malformed_body", "Unable to parse request body.", 400)
+		return
+	}
+	a, signedIn := requestAccount(req)
+	if signedIn {
+		body.Name = a.DisplayName
+		if body.WordList == "" {
+			body.WordList = a.Preferences.WordList
+		}
+		if body.Language == "" {
+			body.Language = a.Preferences.Language
+		}
+	}
+	if body.WordList != "" {
+		if _, ok := h.wordLists[body.WordList]; !ok {
+			writeError(rw, "unknown_word_list", "No word list named "+body.WordList+".", 400)
+			return
+		}
+	}
+	body.Language = strings.ToLower(strings.TrimSpace(body.Language))
+
+	key, _ := playerKey(req.Context(), body.PlayerID)
+	if key == nil && !signedIn {
+		writeResult(rw, nil, errMatchKeyRequired)
+		return
+	}
+	e, pair, err := h.matchmaker.join(body, key, a, h.clock.Now())
+	if err != nil {
+		writeResult(rw, nil, err)
+		return
+	}
+	if pair != nil {
+		// The game is created on behalf of both players,
+		// so it shouldn't fail if this one goes away.
+		h.startMatch(context.WithoutCancel(req.Context()), pair[0], pair[1])
+	}
+
+	select {
+	case <-e.matched:
+	default:
+		select {
+		case <-e.matched:
+		case <-req.Context().Done():
+			h.matchmaker.leave(e)
+			return
+		case <-h.clock.After(25 * time.Second):
+		}
+	}
+	resp, ok, err := h.matchmaker.result(e)
+	if !ok {
+		writeJSON(rw, MatchmakingResponse{Status: "waiting"})
+		return
+	}
+	writeResult(rw, resp, err)
+}
+
+// startMatch creates a game for a pair of players, the same way as
+// a request for a new game, and tells them which sides they're on.
+// The player who waited longer hosts the game and plays side A.
+//
+// Matched games are private, so that no one else can join them:
+// their IDs are random, and no one is told their passcode. Each
+// player is sent an invite instead.
+func (h *handler) startMatch(ctx context.Context, a, b *matchEntry) {
+	wordList := a.WordList
+	if wordList == "" {
+		wordList = b.WordList
+	}
+	id := "match-" + randomToken(12)
+	_, err := h.createGame(withPlayer(ctx, a.key, a.account), NewGameRequest{
+		GameID:   id,
+		PlayerID: a.PlayerID,
+		WordList: wordList,
+		Passcode: randomToken(16),
+	})
+	var invite string
+	if g, ok := h.games.get(id); ok && err == nil {
+		g.mu.Lock()
+		pc := g.passcode
+		g.mu.Unlock()
+		invite = h.invite(id, pc, h.clock.Now().Add(DefaultInviteTTL).Truncate(time.Second))
+	}
+	h.matchmaker.finish(a, MatchmakingResponse{Status: "matched", GameID: id, Team: 1, Partner: b.Name, Invite: invite}, err)
+	h.matchmaker.finish(b, MatchmakingResponse{Status: "matched", GameID: id, Team: 2, Partner: a.Name, Invite: invite}, err)
+}
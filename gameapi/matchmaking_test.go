@@ -0,0 +1,91 @@
+package gameapi
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatchmaking(t *testing.T) {
+	defer func(c argon2Cost) { passwordCost = c }(passwordCost)
+	passwordCost = argon2Cost{Time: 1, Memory: 8, Threads: 1}
+
+	clock := NewFakeClock(time.Now())
+	h := newTestHandler(clock)
+	ctx := context.Background()
+
+	join := func(body map[string]interface{}) <-chan MatchmakingResponse {
+		ch := make(chan MatchmakingResponse, 1)
+		go func() {
+			var resp MatchmakingResponse
+			if code := post(t, keyed(h, body["player_id"].(string)), "/matchmaking/join", body, &resp); code != 200 {
+				t.Errorf("join %v: status = %d, want 200", body, code)
+			}
+			ch <- resp
+		}()
+		return ch
+	}
+
+	if code := post(t, keyed(h, "alice"), "/matchmaking/join", map[string]interface{}{"player_id": "alice", "word_list": "missing"}, nil); code != 400 {
+		t.Errorf("join with an unknown word list: status = %d, want 400", code)
+	}
+	if code := post(t, h, "/matchmaking/join", map[string]interface{}{"player_id": "alice"}, nil); code != 400 {
+		t.Errorf("join without a player key: status = %d, want 400", code)
+	}
+
+	alice := join(map[string]interface{}{"player_id": "alice", "name": "Alice", "word_list": "example"})
+	clock.BlockUntil(2) // the pruning ticker and alice's timeout
+	bob := <-join(map[string]interface{}{"player_id": "bob", "name": "Bob", "language": "en"})
+	a := <-alice
+	if a.Status != "matched" || a.Team != 1 || a.Partner != "Bob" {
+		t.Errorf("alice's match = %+v, want side A with Bob", a)
+	}
+	if bob.Status != "matched" || bob.Team != 2 || bob.Partner != "Alice" || bob.GameID != a.GameID {
+		t.Errorf("bob's match = %+v, want side B of %q with Alice", bob, a.GameID)
+	}
+	var game struct {
+		State GameState `json:"state"`
+	}
+	if a.Invite == "" || bob.Invite == "" {
+		t.Fatalf("alice's invite = %q, bob's = %q; want invites to the private game", a.Invite, bob.Invite)
+	}
+	if code := request(ctx, t, h, "GET", "/v2/games/"+a.GameID, nil, nil); code != 403 {
+		t.Errorf("GET the matched game without an invite: status = %d, want 403", code)
+	}
+	if code := request(ctx, t, withAccess(h, bob.Invite), "GET", "/v2/games/"+a.GameID, nil, &game); code != 200 {
+		t.Fatalf("GET the matched game: status = %d, want 200", code)
+	}
+	if game.State.Host != "alice" || game.State.WordList != "example" || !game.State.Settings.Private {
+		t.Errorf("matched game's host = %q, word list = %q, settings = %+v; want a private game hosted by alice with the example word list",
+			game.State.Host, game.State.WordList, game.State.Settings)
+	}
+	if !strings.HasPrefix(a.GameID, "match-") || len(a.GameID) != len("match-")+16 {
+		t.Errorf("matched game's ID = %q, want a random one", a.GameID)
+	}
+
+	// Players who want different things wait for someone else.
+	carol := join(map[string]interface{}{"player_id": "carol", "language": "fr"})
+	clock.BlockUntil(3)
+	dave := join(map[string]interface{}{"player_id": "dave", "language": "en"})
+	clock.BlockUntil(4)
+
+	// No one else can take a queued player's place.
+	if code := post(t, keyed(h, "mallory"), "/matchmaking/join", map[string]interface{}{"player_id": "dave", "name": "Mallory"}, nil); code != 409 {
+		t.Errorf("join as dave with another key: status = %d, want 409", code)
+	}
+	clock.Advance(30 * time.Second)
+	if c, d := <-carol, <-dave; c.Status != "waiting" || d.Status != "waiting" {
+		t.Errorf("carol's status = %q, dave's = %q; want both waiting", c.Status, d.Status)
+	}
+
+	// Players keep their place while they keep asking, and
+	// are paired with the player who has waited longest.
+	erin := <-join(map[string]interface{}{"player_id": "erin", "language": "EN"})
+	if erin.Status != "matched" || erin.Team != 2 {
+		t.Errorf("erin's match = %+v, want side B", erin)
+	}
+	if d := <-join(map[string]interface{}{"player_id": "dave"}); d.Status != "matched" || d.GameID != erin.GameID || d.Team != 1 {
+		t.Errorf("dave's match = %+v, want side A of %q", d, erin.GameID)
+	}
+}
@@ -0,0 +1,381 @@
+package gameapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiOperation documents one of the API's routes for the OpenAPI
+// document. Every route registered with handle must be documented;
+// TestOpenAPIRoutes checks that they match.
+type apiOperation struct {
+	method, path string
+	summary      string
+	query        []apiParam
+	request      interface{} // the request body, or nil if there isn't one
+	response     interface{}
+	status       int  // the status of successful responses, if not 200
+	admin        bool // requires the admin token
+	session      bool // requires a player's session
+	keyed        bool // requires a player's key or session
+}
+
+// forGame returns true if the operation is for a particular game,
+// named by its path or its request body.
+func (op apiOperation) forGame() bool {
+	if strings.Contains(op.path, "games/{id}") {
+		return true
+	}
+	if op.request == nil {
+		return false
+	}
+	_, ok := reflect.TypeOf(op.request).FieldByName("GameID")
+	return ok
+}
+
+// forPlayer returns true if the operation is made on behalf of a
+// player, named by its path, its query or its request body.
+func (op apiOperation) forPlayer() bool {
+	if strings.Contains(op.path, "{player_id}") {
+		return true
+	}
+	for _, p := range op.query {
+		if p.name == "player_id" {
+			return true
+		}
+	}
+	if op.request == nil {
+		return false
+	}
+	_, ok := reflect.TypeOf(op.request).FieldByName("PlayerID")
+	return ok
+}
+
+// security returns the operation's security requirements, any one
+// of which suffices, or nil if it doesn't need any.
+func (op apiOperation) security() []interface{} {
+	switch {
+	case op.admin:
+		return []interface{}{map[string]interface{}{"adminToken": []string{}}}
+	case op.session:
+		return []interface{}{map[string]interface{}{"playerSession": []string{}}}
+	}
+	// Only private games require access, only players with
+	// accounts require sessions, and only hosts require keys.
+	reqs := []map[string]interface{}{{}}
+	if op.forGame() {
+		reqs = append(reqs, map[string]interface{}{"roomAccess": []string{}})
+	}
+	if op.forPlayer() {
+		for _, scheme := range []string{"playerSession", "playerKey"} {
+			for _, r := range reqs[:len(reqs):len(reqs)] {
+				if _, ok := r["playerSession"]; ok {
+					continue
+				}
+				with := map[string]interface{}{scheme: []string{}}
+				for k, v := range r {
+					with[k] = v
+				}
+				reqs = append(reqs, with)
+			}
+		}
+	}
+	if op.keyed {
+		reqs = reqs[1:]
+	} else if len(reqs) == 1 {
+		return nil
+	}
+	security := make([]interface{}, len(reqs))
+	for i, r := range reqs {
+		security[i] = r
+	}
+	return security
+}
+
+// unversioned holds the paths of the routes for the whole server,
+// which aren't under /v2 but aren't deprecated either.
+var unversioned = map[string]bool{
+	"/lobby":              true,
+	"/matchmaking/join":   true,
+	"/accounts":           true,
+	"/login":              true,
+	"/logout":             true,
+	"/accounts/me":        true,
+	"/players/{id}/stats": true,
+	"/teams":              true,
+	"/leaderboards/teams": true,
+	"/openapi.json":       true,
+}
+
+// apiParam documents a query parameter.
+type apiParam struct {
+	name, typ, description string
+}
+
+var apiOperations = []apiOperation{
+	{method: "POST", path: "/index", summary: "Generate an unused game ID.", response: IndexResponse{}},
+	{method: "POST", path: "/new-game", summary: "Create a game, or return the existing game unless prev_seed matches its seed.", request: NewGameRequest{}, response: (*Game)(nil)},
+	{method: "POST", path: "/guess", summary: "Guess a card.", request: GuessRequest{}, response: StatusResponse{}},
+	{method: "POST", path: "/end-turn", summary: "End the current turn.", request: PlayerRequest{}, response: StatusResponse{}},
+	{method: "POST", path: "/chat", summary: "Send a chat message.", request: ChatRequest{}, response: StatusResponse{}},
+	{method: "POST", path: "/events", summary: "Long poll for the game's events.", request: EventsRequest{}, response: GameUpdate{}},
+	{method: "POST", path: "/ping", summary: "Record that a player is still playing.", request: PlayerRequest{}, response: StatusResponse{}},
+	{method: "GET", path: "/stats", summary: "Report usage statistics.", query: statsParams, response: StatsSummary{}},
+	{method: "POST", path: "/clue", summary: "Give a clue, which bots on the other side respond to.", request: ClueRequest{}, response: StatusResponse{}},
+	{method: "POST", path: "/games/{id}/suggest-clue", summary: "Suggest clues for a side.", request: SuggestClueRequest{}, response: SuggestClueResponse{}},
+	{method: "POST", path: "/games/{id}/bots", summary: "Add a bot to a side.", request: AddBotRequest{}, response: AddBotResponse{}},
+	{method: "GET", path: "/games/{id}/practice", summary: "Report the results of a practice game.", response: PracticeResults{}},
+
+	{method: "POST", path: "/v2/games", summary: "Create a game, generating its ID if it isn't provided.", request: NewGameRequest{}, response: (*Game)(nil), status: 201},
+	{method: "GET", path: "/v2/games/{id}", summary: "Get a game.", query: gameParams, response: (*Game)(nil)},
+	{method: "PUT", path: "/v2/games/{id}", summary: "Create a game, or replace it if prev_seed matches its seed and the request comes from its host.", request: NewGameRequest{}, response: (*Game)(nil), status: 201},
+	{method: "GET", path: "/v2/games/{id}/events", summary: "Long poll for the game's events.", query: eventsParams, response: GameUpdate{}},
+	{method: "PUT", path: "/v2/games/{id}/players/{player_id}", summary: "Record that a player is still playing.", request: PlayerRequest{}, response: StatusResponse{}},
+	{method: "POST", path: "/v2/games/{id}/guesses", summary: "Guess a card.", request: GuessRequest{}, response: StatusResponse{}},
+	{method: "POST", path: "/v2/games/{id}/end-turn", summary: "End the current turn.", request: PlayerRequest{}, response: StatusResponse{}},
+	{method: "POST", path: "/v2/games/{id}/clues", summary: "Give a clue, which bots on the other side respond to.", request: ClueRequest{}, response: StatusResponse{}},
+	{method: "POST", path: "/v2/games/{id}/messages", summary: "Send a chat message.", request: ChatRequest{}, response: StatusResponse{}},
+	{method: "POST", path: "/v2/games/{id}/mutes", summary: "Mute or unmute a player, as the game's host.", request: MuteRequest{}, response: StatusResponse{}},
+	{method: "POST", path: "/v2/games/{id}/kicks", summary: "Remove a player from the game, as its host.", request: TargetRequest{}, response: StatusResponse{}},
+	{method: "PUT", path: "/v2/games/{id}/host", summary: "Make another player the game's host, as its host.", request: TargetRequest{}, response: StatusResponse{}},
+	{method: "PATCH", path: "/v2/games/{id}/settings", summary: "Change the game's settings, as its host.", request: SettingsRequest{}, response: Settings{}},
+	{method: "POST", path: "/v2/games/{id}/invites", summary: "Invite players to a private game, as its host.", request: InviteRequest{}, response: InviteResponse{}, status: 201},
+	{method: "POST", path: "/v2/games/{id}/clue-suggestions", summary: "Suggest clues for a side.", request: SuggestClueRequest{}, response: SuggestClueResponse{}},
+	{method: "POST", path: "/v2/games/{id}/bots", summary: "Add a bot to a side.", request: AddBotRequest{}, response: AddBotResponse{}},
+	{method: "GET", path: "/v2/games/{id}/practice", summary: "Report the results of a practice game.", response: PracticeResults{}},
+	{method: "GET", path: "/v2/stats", summary: "Report usage statistics.", query: statsParams, response: StatsSummary{}},
+	{method: "GET", path: "/lobby", summary: "List the public games, waiting for the listing to change if version matches it.", query: lobbyParams, response: LobbyResponse{}},
+	{method: "POST", path: "/matchmaking/join", summary: "Wait to be paired with another player, and then create a game for the two of you.", request: MatchmakingRequest{}, response: MatchmakingResponse{}, keyed: true},
+	{method: "POST", path: "/accounts", summary: "Create an account and sign in to it.", request: RegisterRequest{}, response: SessionResponse{}, status: 201},
+	{method: "POST", path: "/login", summary: "Sign in to an account.", request: LoginRequest{}, response: SessionResponse{}, status: 201},
+	{method: "POST", path: "/logout", summary: "End the session.", response: StatusResponse{}, session: true},
+	{method: "GET", path: "/accounts/me", summary: "Get the signed-in player's account.", response: Account{}, session: true},
+	{method: "PATCH", path: "/accounts/me", summary: "Change the signed-in player's display name or preferences.", request: AccountChange{}, response: Account{}, session: true},
+	{method: "GET", path: "/players/{id}/stats", summary: "Report a player's statistics over the games they've won or lost.", response: PlayerStats{}},
+	{method: "POST", path: "/teams", summary: "Create a team of players to compete on the leaderboards. When players have accounts, the creator must be signed in and on the team.", request: TeamRequest{}, response: Team{}, status: 201},
+	{method: "GET", path: "/teams", summary: "List the teams.", response: TeamsResponse{}},
+	{method: "GET", path: "/leaderboards/teams", summary: "Rank the teams by the games they've won or lost over a season.", query: seasonParams, response: Leaderboard{}},
+	{method: "GET", path: "/openapi.json", summary: "Describe the API.", response: map[string]interface{}{}},
+
+	{method: "GET", path: "/admin/games", summary: "List the games in memory.", response: AdminGamesResponse{}, admin: true},
+	{method: "GET", path: "/admin/games/{id}", summary: "Get a game, including its players.", response: AdminGame{}, admin: true},
+	{method: "DELETE", path: "/admin/games/{id}", summary: "Delete a game.", response: StatusResponse{}, admin: true},
+	{method: "POST", path: "/admin/games/{id}/end", summary: "End a game that hasn't been won or lost.", response: StatusResponse{}, admin: true},
+	{method: "DELETE", path: "/admin/games/{id}/players/{player_id}", summary: "Remove a player from a game and keep them from rejoining.", response: StatusResponse{}, admin: true},
+	{method: "DELETE", path: "/admin/games/{id}/messages", summary: "Remove a game's chat messages.", query: purgeParams, response: AdminPurgeResponse{}, admin: true},
+	{method: "DELETE", path: "/admin/teams/{id}", summary: "Delete a team.", response: StatusResponse{}, admin: true},
+}
+
+var statsParams = []apiParam{
+	{"window", "string", "The duration to report on, between 1h and 168h. Defaults to 24h."},
+}
+
+var lobbyParams = []apiParam{
+	{"version", "string", "The version of the listing the client has."},
+}
+
+var seasonParams = []apiParam{
+	{"season", "string", `The month to rank the teams over, like "2026-10", or "all". Defaults to the current month.`},
+}
+
+var purgeParams = []apiParam{
+	{"player_id", "string", "Only remove the messages sent by this player."},
+}
+
+var gameParams = []apiParam{
+	{"player_id", "string", "The player's ID, to include the messages to their side if the request has their key or session."},
+}
+
+var eventsParams = []apiParam{
+	{"seed", "string", "The seed of the game the player is playing."},
+	{"player_id", "string", "The player's ID."},
+	{"name", "string", "The player's name."},
+	{"team", "integer", "The player's side: 1 for A, 2 for B."},
+	{"last_event", "integer", "The number of the last event the player has seen."},
+}
+
+// GET /openapi.json
+func (h *handler) handleOpenAPI(rw http.ResponseWriter, req *http.Request) {
+	writeJSON(rw, openAPIDocument())
+}
+
+// openAPIDocument builds an OpenAPI 3 document describing the API.
+// Schemas are derived from the request and response types, so the
+// document stays in sync with them.
+func openAPIDocument() map[string]interface{} {
+	s := schemas{}
+	paths := map[string]interface{}{}
+	for _, op := range apiOperations {
+		status := op.status
+		if status == 0 {
+			status = 200
+		}
+		doc := map[string]interface{}{
+			"summary": op.summary,
+			"responses": map[string]interface{}{
+				strconv.Itoa(status): map[string]interface{}{
+					"description": http.StatusText(status),
+					"content":     jsonContent(s.schema(reflect.TypeOf(op.response))),
+				},
+				"default": map[string]interface{}{
+					"description": "An error.",
+					"content":     jsonContent(s.schema(reflect.TypeOf(ErrorResponse{}))),
+				},
+			},
+		}
+		// Routes outside of /v2 are kept for existing clients,
+		// apart from the newer routes for the whole server.
+		if !strings.HasPrefix(op.path, "/v2/") && !op.admin && !unversioned[op.path] {
+			doc["deprecated"] = true
+		}
+		if security := op.security(); security != nil {
+			doc["security"] = security
+		}
+		if op.request != nil {
+			doc["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  jsonContent(s.schema(reflect.TypeOf(op.request))),
+			}
+		}
+
+		var params []interface{}
+		for _, seg := range strings.Split(op.path, "/") {
+			if strings.HasPrefix(seg, "{") {
+				params = append(params, map[string]interface{}{
+					"name":     strings.Trim(seg, "{}"),
+					"in":       "path",
+					"required": true,
+					"schema":   map[string]interface{}{"type": "string"},
+				})
+			}
+		}
+		for _, p := range op.query {
+			params = append(params, map[string]interface{}{
+				"name":        p.name,
+				"in":          "query",
+				"description": p.description,
+				"schema":      map[string]interface{}{"type": p.typ},
+			})
+		}
+		if len(params) > 0 {
+			doc["parameters"] = params
+		}
+
+		item, _ := paths[op.path].(map[string]interface{})
+		if item == nil {
+			item = map[string]interface{}{}
+			paths[op.path] = item
+		}
+		item[strings.ToLower(op.method)] = doc
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Codenames Green",
+			"version": "2",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": map[string]interface{}(s),
+			"securitySchemes": map[string]interface{}{
+				"adminToken":    map[string]interface{}{"type": "http", "scheme": "bearer"},
+				"roomAccess":    map[string]interface{}{"type": "apiKey", "in": "header", "name": AccessHeader},
+				"playerSession": map[string]interface{}{"type": "http", "scheme": "bearer"},
+				"playerKey":     map[string]interface{}{"type": "apiKey", "in": "header", "name": PlayerKeyHeader},
+			},
+		},
+	}
+}
+
+func jsonContent(schema interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": schema},
+	}
+}
+
+// schemas collects the schemas of named types, by name.
+type schemas map[string]interface{}
+
+var (
+	seedType = reflect.TypeOf(Seed(0))
+	timeType = reflect.TypeOf(time.Time{})
+	rawType  = reflect.TypeOf(json.RawMessage{})
+)
+
+// schema returns the schema of values of type t, as they're
+// marshaled by encoding/json. Named structs are added to s and
+// referred to by name.
+func (s schemas) schema(t reflect.Type) map[string]interface{} {
+	switch t {
+	case seedType:
+		return map[string]interface{}{"type": "string", "format": "int64"}
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case rawType:
+		return map[string]interface{}{}
+	case reflect.TypeOf(Color(0)):
+		return map[string]interface{}{"type": "string", "enum": []string{Tan.String(), Green.String(), Black.String()}}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return s.schema(t.Elem())
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": s.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": s.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		if _, ok := s[t.Name()]; !ok {
+			s[t.Name()] = nil // guard against recursive types
+			s[t.Name()] = s.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// object returns the schema of a struct type.
+func (s schemas) object(t reflect.Type) map[string]interface{} {
+	props := map[string]interface{}{}
+	s.addProperties(t, props)
+	return map[string]interface{}{"type": "object", "properties": props}
+}
+
+// addProperties adds the properties of the struct type t to props,
+// including those of embedded structs without JSON names.
+func (s schemas) addProperties(t reflect.Type, props map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || (f.PkgPath != "" && !f.Anonymous) {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			s.addProperties(f.Type, props)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = s.schema(f.Type)
+	}
+}
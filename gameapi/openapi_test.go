@@ -0,0 +1,99 @@
+package gameapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update testdata/openapi.json")
+
+// TestOpenAPIRoutes checks that every route is documented, and that
+// every documented route exists.
+func TestOpenAPIRoutes(t *testing.T) {
+	h := newTestHandler(NewFakeClock(time.Now()))
+
+	var routes []string
+	for _, pattern := range h.patterns {
+		if methods, ok := h.routeMethods[pattern]; ok {
+			for _, m := range methods {
+				routes = append(routes, m+" "+pattern)
+			}
+			continue
+		}
+		routes = append(routes, pattern)
+	}
+	var documented []string
+	for _, op := range apiOperations {
+		documented = append(documented, op.method+" "+op.path)
+	}
+	sort.Strings(routes)
+	sort.Strings(documented)
+	if strings.Join(routes, "\n") != strings.Join(documented, "\n") {
+		t.Errorf("routes:\n%s\n\ndocumented:\n%s", strings.Join(routes, "\n"), strings.Join(documented, "\n"))
+	}
+}
+
+// TestOpenAPIDocument compares the OpenAPI document to the copy in
+// testdata, so that changes to the API are visible in review. Run
+// the test with -update to update it.
+func TestOpenAPIDocument(t *testing.T) {
+	got, err := json.MarshalIndent(openAPIDocument(), "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, '\n')
+	if *update {
+		if err := os.WriteFile("testdata/openapi.json", got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile("testdata/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("the OpenAPI document has changed; if that's intended, run go test -run TestOpenAPIDocument -update")
+	}
+
+	// Spot check a few schemas.
+	var doc struct {
+		Paths      map[string]map[string]json.RawMessage
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]json.RawMessage
+			}
+		}
+	}
+	if err := json.Unmarshal(got, &doc); err != nil {
+		t.Fatal(err)
+	}
+	guess := doc.Components.Schemas["GuessRequest"].Properties
+	for _, name := range []string{"game_id", "seed", "player_id", "name", "team", "index"} {
+		if _, ok := guess[name]; !ok {
+			t.Errorf("GuessRequest is missing %q", name)
+		}
+	}
+	if _, ok := doc.Components.Schemas["GameState"].Properties["mu"]; ok {
+		t.Error("GameState includes unexported fields")
+	}
+	var number map[string]string
+	json.Unmarshal(doc.Components.Schemas["Event"].Properties["number"], &number)
+	if number["type"] != "integer" {
+		t.Errorf("Event.number = %v, want an integer", number)
+	}
+
+	h := newTestHandler(NewFakeClock(time.Now()))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/openapi.json", nil))
+	var served map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &served); rec.Code != 200 || err != nil || served["openapi"] != "3.0.3" {
+		t.Errorf("GET /openapi.json: status = %d, err = %v", rec.Code, err)
+	}
+}
@@ -0,0 +1,198 @@
+package gameapi
+
+import "net/http"
+
+// practiceClues is the most clues a practice game's spymaster
+// gives, one for each of the timer tokens in a real game.
+const practiceClues = 9
+
+// In a practice game a single player guesses on side B against
+// clues for side A's key. The clues are planned by the spymaster
+// when the game is created and recorded in the game's state, so
+// every instance gives the same clues. Each time the turn passes
+// to side A, its spymaster ends the turn and gives the next clue.
+
+var errPracticeGame = &apiError{"practice_game", "Practice games don't take clues from players or bots.", 409}
+
+// planPractice plans the clues for a practice game. Each clue is
+// chosen assuming that the player found the targets of the clues
+// before it.
+func planPractice(s Spymaster, b Board) []Clue {
+	var plan []Clue
+	for len(plan) < practiceClues && hasHiddenGreen(b) {
+		clues := s.SuggestClues(b, 1)
+		if len(clues) == 0 {
+			break
+		}
+		plan = append(plan, clues[0])
+		for _, target := range clues[0].Targets {
+			for i, w := range b.Words {
+				if w == target {
+					b.Hidden[i] = false
+				}
+			}
+		}
+	}
+	return plan
+}
+
+func hasHiddenGreen(b Board) bool {
+	for i, c := range b.Key {
+		if c == Green && b.Hidden[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// giveNextClue has a practice game's spymaster give its next clue
+// once the turn has passed to side A. The caller must hold g.mu.
+func (g *Game) giveNextClue() {
+	if len(g.Practice) == 0 {
+		return
+	}
+	st := g.status()
+	if st.Finished() || st.Turn != 1 || !g.hasHiddenGreens(1, st.Revealed) {
+		return
+	}
+	given := g.cluesGiven()
+	if given == 0 || given >= len(g.Practice) {
+		return
+	}
+	g.addEvent(Event{Type: "end_turn", Team: 1, Name: "Spymaster"})
+	g.givePracticeClue(given)
+}
+
+// givePracticeClue records the practice clue at index i. The
+// caller must hold g.mu.
+func (g *Game) givePracticeClue(i int) {
+	g.addEvent(Event{
+		Type:    "clue",
+		Team:    1,
+		Name:    "Spymaster",
+		Index:   g.Practice[i].Number,
+		Message: g.Practice[i].Word,
+	})
+}
+
+// cluesGiven returns the number of planned clues that have been
+// given. The caller must hold g.mu.
+func (g *Game) cluesGiven() (n int) {
+	for _, e := range g.Events {
+		if e.plannedClue() {
+			n++
+		}
+	}
+	return n
+}
+
+// plannedClue returns true if the event is a clue given by a
+// practice game's spymaster, rather than by a player.
+func (e Event) plannedClue() bool {
+	return e.Type == "clue" && e.PlayerID == "" && e.Team == 1
+}
+
+// PracticeResults summarizes a practice game.
+type PracticeResults struct {
+	Finished    bool            `json:"finished"`
+	Score       int             `json:"score"`
+	GreensFound int             `json:"greens_found"`
+	GreensTotal int             `json:"greens_total"`
+	TansGuessed int             `json:"tans_guessed"`
+	HitBlack    bool            `json:"hit_black"`
+	Rounds      []PracticeRound `json:"rounds"`
+}
+
+// PracticeRound records the guesses made for one clue. The clue's
+// targets are only included once the game is finished.
+type PracticeRound struct {
+	Clue    Clue     `json:"clue"`
+	Guesses []string `json:"guesses"`
+	Correct int      `json:"correct"`
+}
+
+// practiceResults scores a practice game. Each green card found
+// scores 10 points, and finding all of them scores 5 more points
+// for each clue that wasn't needed. Guessing the black card loses
+// half of the score. The caller must hold g.mu.
+func (g *Game) practiceResults() PracticeResults {
+	st := g.status()
+	key := g.layout(1)
+	r := PracticeResults{Rounds: []PracticeRound{}}
+	for _, c := range key {
+		if c == Green {
+			r.GreensTotal++
+		}
+	}
+
+	var round *PracticeRound
+	for _, e := range g.Events {
+		switch {
+		case e.plannedClue() && len(r.Rounds) < len(g.Practice):
+			r.Rounds = append(r.Rounds, PracticeRound{Clue: g.Practice[len(r.Rounds)], Guesses: []string{}})
+			round = &r.Rounds[len(r.Rounds)-1]
+		case e.Type == "guess" && e.Team == 2 && round != nil:
+			round.Guesses = append(round.Guesses, g.Words[e.Index])
+			switch key[e.Index] {
+			case Green:
+				round.Correct++
+				r.GreensFound++
+			case Tan:
+				r.TansGuessed++
+			case Black:
+				r.HitBlack = true
+			}
+		}
+	}
+
+	r.Finished = st.Lost || !g.hasHiddenGreens(1, st.Revealed) ||
+		(st.Turn == 1 && len(r.Rounds) == len(g.Practice))
+	r.Score = 10 * r.GreensFound
+	if r.GreensFound == r.GreensTotal {
+		r.Score += 5 * (len(g.Practice) - len(r.Rounds))
+	}
+	if r.HitBlack {
+		r.Score /= 2
+	}
+	if !r.Finished {
+		for i := range r.Rounds {
+			r.Rounds[i].Clue.Targets = nil
+		}
+	}
+	return r
+}
+
+// GET /games/{id}/practice
+// Returns the results of a practice game so far.
+func (h *handler) handlePracticeResults(rw http.ResponseWriter, req *http.Request) {
+	g, ok := h.games.get(req.PathValue("id"))
+	if !ok {
+		writeError(rw, "not_found", "Game not found", 404)
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.Practice) == 0 {
+		writeError(rw, "not_practice", "This game isn't a practice game.", 400)
+		return
+	}
+	writeJSON(rw, g.practiceResults())
+}
+
+// planPracticeGame builds the board for a new practice game before
+// it's created, so that its clues can be planned and sent along
+// with the command creating it.
+func (h *handler) planPracticeGame(seed int64, words []string) ([]Clue, error) {
+	if h.spymaster == nil {
+		return nil, &apiError{"not_implemented", "This server isn't configured for practice games.", 501}
+	}
+	g, err := ReconstructGame(NewState(seed, words))
+	if err != nil {
+		return nil, err
+	}
+	plan := planPractice(h.spymaster, g.board(1))
+	if len(plan) == 0 {
+		return nil, &apiError{"no_clues", "Unable to come up with clues for this board.", 500}
+	}
+	return plan, nil
+}
@@ -0,0 +1,244 @@
+package gameapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimit is a token bucket's budget: a client may make Burst
+// requests at once, and then Rate requests per second.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimits controls how many requests clients may make. Requests
+// are counted against both the client's IP address and the player
+// making them, separately for each budget; see routeBudgets. Limits
+// are enforced by each instance independently.
+type RateLimits struct {
+	PerIP     map[string]RateLimit
+	PerPlayer map[string]RateLimit
+
+	// MaxBodyBytes caps the size of request bodies.
+	// Zero means that bodies aren't capped.
+	MaxBodyBytes int64
+
+	// TrustForwardedFor identifies clients by the last address in
+	// the X-Forwarded-For header, for instances behind a proxy
+	// that sets it.
+	TrustForwardedFor bool
+}
+
+// DefaultRateLimits is used by handlers that aren't configured
+// with WithRateLimits.
+var DefaultRateLimits = RateLimits{
+	PerIP: map[string]RateLimit{
+		"index":    {Rate: 1, Burst: 20},
+		"new_game": {Rate: 1.0 / 6, Burst: 20},
+		"guess":    {Rate: 5, Burst: 30},
+		"chat":     {Rate: 2, Burst: 20},
+		"host":     {Rate: 1, Burst: 10},
+		"access":   {Rate: 0.1, Burst: 10}, // failed attempts only
+		"match":    {Rate: 0.5, Burst: 10},
+		"login":    {Rate: 0.1, Burst: 10},
+		"teams":    {Rate: 1.0 / 60, Burst: 10},
+		"ai":       {Rate: 0.2, Burst: 5},
+	},
+	PerPlayer: map[string]RateLimit{
+		"guess": {Rate: 2, Burst: 10},
+		"chat":  {Rate: 1, Burst: 5},
+		"host":  {Rate: 1, Burst: 5},
+		"match": {Rate: 0.2, Burst: 5},
+		"ai":    {Rate: 0.1, Burst: 3},
+	},
+	MaxBodyBytes: 64 << 10,
+}
+
+// WithRateLimits configures the handler to limit requests
+// according to the provided limits.
+func WithRateLimits(l RateLimits) Option {
+	return func(h *handler) {
+		h.limits = l
+	}
+}
+
+// routeBudgets maps the patterns of rate limited routes
+// to the names of the budgets they draw from.
+var routeBudgets = map[string]string{
+	"/index":                        "index",
+	"/new-game":                     "new_game",
+	"POST /v2/games":                "new_game",
+	"PUT /v2/games/{id}":            "new_game",
+	"/guess":                        "guess",
+	"/end-turn":                     "guess",
+	"POST /v2/games/{id}/guesses":   "guess",
+	"POST /v2/games/{id}/end-turn":  "guess",
+	"/chat":                         "chat",
+	"/clue":                         "chat",
+	"POST /v2/games/{id}/messages":  "chat",
+	"POST /v2/games/{id}/clues":     "chat",
+	"POST /v2/games/{id}/mutes":     "host",
+	"POST /v2/games/{id}/kicks":     "host",
+	"PUT /v2/games/{id}/host":       "host",
+	"PATCH /v2/games/{id}/settings": "host",
+	"POST /v2/games/{id}/invites":   "host",
+
+	// Suggesting clues and playing bots search the word vectors.
+	"POST /games/{id}/suggest-clue":        "ai",
+	"POST /v2/games/{id}/clue-suggestions": "ai",
+	"POST /games/{id}/bots":                "ai",
+	"POST /v2/games/{id}/bots":             "ai",
+
+	"POST /matchmaking/join": "match",
+	"POST /accounts":         "login",
+	"POST /login":            "login",
+	"POST /teams":            "teams",
+}
+
+// limit caps the request's body and charges the request to its
+// budgets; failed attempts to access private games are charged by
+// checkAccess. It returns true if the request has been answered
+// because it's too large or over budget.
+func (h *handler) limit(rw http.ResponseWriter, req *http.Request) bool {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		r := io.Reader(req.Body)
+		if h.limits.MaxBodyBytes > 0 {
+			r = http.MaxBytesReader(rw, req.Body, h.limits.MaxBodyBytes)
+		}
+		var err error
+		body, err = io.ReadAll(r)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(rw, "body_too_large",
+				"The request body may be at most "+strconv.FormatInt(tooLarge.Limit, 10)+" bytes.", 413)
+			return true
+		} else if err != nil {
+			writeError(rw, "malformed_body", "Unable to read request body.", 400)
+			return true
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	_, pattern := h.mux.Handler(req)
+	budget, ok := routeBudgets[pattern]
+	if !ok {
+		return false
+	}
+	now := h.clock.Now()
+	// Requests refused for the IP aren't charged to the player, so
+	// that others on the IP can't use up the player's budget.
+	wait := h.limiter.take("ip:"+budget+":"+h.clientIP(req), h.limits.PerIP[budget], now)
+	var p struct {
+		PlayerID string `json:"player_id"`
+	}
+	if wait == 0 && json.Unmarshal(body, &p) == nil && p.PlayerID != "" {
+		wait = h.limiter.take("player:"+budget+":"+p.PlayerID, h.limits.PerPlayer[budget], now)
+	}
+	if wait == 0 {
+		return false
+	}
+	writeRateLimited(rw, wait)
+	return true
+}
+
+// writeRateLimited tells the client to wait before trying again.
+func writeRateLimited(rw http.ResponseWriter, wait time.Duration) {
+	rw.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	writeError(rw, "rate_limited", "Too many requests. Try again later.", 429)
+}
+
+// clientIP returns the address of the client making the request.
+func (h *handler) clientIP(req *http.Request) string {
+	if h.limits.TrustForwardedFor {
+		if fwd := req.Header.Values("X-Forwarded-For"); len(fwd) > 0 {
+			hops := strings.Split(fwd[len(fwd)-1], ",")
+			if ip := strings.TrimSpace(hops[len(hops)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// limiter holds token buckets by key.
+type limiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	limit  RateLimit
+}
+
+func newLimiter() *limiter {
+	return &limiter{buckets: make(map[string]*bucket)}
+}
+
+// take takes a token from the key's bucket. If the bucket is empty,
+// it returns how long it'll be until a token is available and
+// doesn't take one. A zero limit doesn't limit anything.
+func (l *limiter) take(key string, limit RateLimit, now time.Time) time.Duration {
+	if limit.Rate <= 0 || limit.Burst <= 0 {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now, limit: limit}
+		l.buckets[key] = b
+	}
+	b.refill(now)
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+	}
+	b.tokens--
+	return 0
+}
+
+// giveBack returns a token taken from the key's bucket.
+func (l *limiter) giveBack(key string, limit RateLimit, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if b, ok := l.buckets[key]; ok {
+		b.refill(now)
+		b.tokens = math.Min(float64(limit.Burst), b.tokens+1)
+	}
+}
+
+func (b *bucket) refill(now time.Time) {
+	if now.After(b.last) {
+		b.tokens = math.Min(float64(b.limit.Burst), b.tokens+now.Sub(b.last).Seconds()*b.limit.Rate)
+		b.last = now
+	}
+}
+
+// sweep forgets buckets that have refilled, since
+// they're no different from new buckets.
+func (l *limiter) sweep(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key, b := range l.buckets {
+		if b.refill(now); b.tokens >= float64(b.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package gameapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	l := newLimiter()
+	now := time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)
+	limit := RateLimit{Rate: 2, Burst: 3}
+	for i := 0; i < 3; i++ {
+		if wait := l.take("k", limit, now); wait != 0 {
+			t.Fatalf("request %d: wait = %s, want 0", i, wait)
+		}
+	}
+	if wait := l.take("k", limit, now); wait != 500*time.Millisecond {
+		t.Errorf("over budget: wait = %s, want 500ms", wait)
+	}
+	if wait := l.take("other", limit, now); wait != 0 {
+		t.Errorf("another key: wait = %s, want 0", wait)
+	}
+	if wait := l.take("k", limit, now.Add(500*time.Millisecond)); wait != 0 {
+		t.Errorf("after refilling: wait = %s, want 0", wait)
+	}
+	if wait := l.take("k", RateLimit{}, now); wait != 0 {
+		t.Errorf("without a limit: wait = %s, want 0", wait)
+	}
+
+	l.sweep(now.Add(time.Second))
+	if len(l.buckets) != 1 {
+		t.Errorf("%d buckets after sweeping, want 1", len(l.buckets))
+	}
+	l.sweep(now.Add(2 * time.Second))
+	if len(l.buckets) != 0 {
+		t.Errorf("%d buckets after sweeping, want 0", len(l.buckets))
+	}
+}
+
+func TestRateLimits(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC))
+	h := newTestHandler(clock)
+	seed := newTestGame(t, h, "limited")
+	chat := func(player string) int {
+		return post(t, h, "/chat", map[string]interface{}{
+			"game_id": "limited", "seed": seed, "player_id": player, "team": 1, "message": "hi",
+		}, nil)
+	}
+
+	for i := 0; i < 5; i++ {
+		if code := chat("alice"); code != 200 {
+			t.Fatalf("chat %d: status = %d, want 200", i, code)
+		}
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"game_id": "limited", "seed": seed, "player_id": "alice", "team": 1, "message": "hi",
+	})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/chat", bytes.NewReader(body)))
+	if rec.Code != 429 || rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("chat over budget: status = %d, Retry-After = %q; want 429 after 1 second", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	clock.Advance(time.Second)
+	if code := chat("alice"); code != 200 {
+		t.Errorf("chat after waiting: status = %d, want 200", code)
+	}
+
+	// Other players on the same IP share its budget.
+	var limited bool
+	for i := 0; i < 20 && !limited; i++ {
+		limited = chat(fmt.Sprintf("sock-%d", i)) == 429
+	}
+	if !limited {
+		t.Error("players on one IP weren't limited")
+	}
+
+	// Other routes aren't charged.
+	if code := request(context.Background(), t, h, "GET", "/stats", nil, nil); code != 200 {
+		t.Errorf("GET /stats: status = %d, want 200", code)
+	}
+}
+
+func TestRateLimitsChargeIPFirst(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC))
+	h := newTestHandler(clock)
+	seed := newTestGame(t, h, "shared")
+	chat := func(player string) int {
+		return post(t, h, "/chat", map[string]interface{}{
+			"game_id": "shared", "seed": seed, "player_id": player, "team": 1, "message": "hi",
+		}, nil)
+	}
+
+	// Use up the IP's budget, then have alice's requests refused for it.
+	for i := 0; chat(fmt.Sprintf("sock-%d", i)) != 429; i++ {
+	}
+	for i := 0; i < 5; i++ {
+		if code := chat("alice"); code != 429 {
+			t.Fatalf("chat %d over the IP's budget: status = %d, want 429", i, code)
+		}
+	}
+
+	// Once the IP has budget again, alice still has all of hers.
+	clock.Advance(3 * time.Second)
+	for i := 0; i < 5; i++ {
+		if code := chat("alice"); code != 200 {
+			t.Fatalf("chat %d: status = %d, want 200", i, code)
+		}
+	}
+}
+
+func TestSuggestClueLimit(t *testing.T) {
+	h := newTestHandler(NewFakeClock(time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)))
+	seed := newTestGame(t, h, "clues")
+	for i := 0; i < DefaultRateLimits.PerIP["ai"].Burst; i++ {
+		if code := post(t, h, "/games/clues/suggest-clue", map[string]interface{}{"seed": seed, "team": 1}, nil); code != 501 {
+			t.Fatalf("suggestion %d: status = %d, want 501", i, code)
+		}
+	}
+	for _, path := range []string{"/games/clues/suggest-clue", "/v2/games/clues/clue-suggestions", "/games/clues/bots", "/v2/games/clues/bots"} {
+		if code := post(t, h, path, map[string]interface{}{"seed": seed, "team": 1}, nil); code != 429 {
+			t.Errorf("POST %s over budget: status = %d, want 429", path, code)
+		}
+	}
+}
+
+func TestBodyLimit(t *testing.T) {
+	h := newTestHandler(NewFakeClock(time.Now()))
+	words := strings.Fields(strings.Repeat("word ", 20000))
+	if code := post(t, h, "/new-game", map[string]interface{}{"game_id": "huge", "words": words}, nil); code != 413 {
+		t.Errorf("new game with %d words: status = %d, want 413", len(words), code)
+	}
+}
+
+func TestForwardedFor(t *testing.T) {
+	h := &handler{}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Add("X-Forwarded-For", "203.0.113.1, 198.51.100.2")
+	if ip := h.clientIP(req); ip != "192.0.2.1" {
+		t.Errorf("untrusted: client IP = %q, want 192.0.2.1", ip)
+	}
+	h.limits.TrustForwardedFor = true
+	if ip := h.clientIP(req); ip != "198.51.100.2" {
+		t.Errorf("trusted: client IP = %q, want 198.51.100.2", ip)
+	}
+}
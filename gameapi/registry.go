@@ -0,0 +1,99 @@
+package gameapi
+
+import (
+	"hash/maphash"
+	"sync"
+	"time"
+)
+
+// registryShards is the number of independently locked
+// partitions of the game registry.
+const registryShards = 64
+
+// registry holds all of the in-memory games, keyed by game ID.
+// Games are partitioned across shards so that requests for
+// different games rarely contend on the same lock, and so that
+// pruning one shard doesn't block lookups in the others.
+type registry struct {
+	seed   maphash.Seed
+	shards [registryShards]registryShard
+}
+
+type registryShard struct {
+	mu    sync.RWMutex
+	games map[string]*Game
+}
+
+func newRegistry() *registry {
+	r := &registry{seed: maphash.MakeSeed()}
+	for i := range r.shards {
+		r.shards[i].games = make(map[string]*Game)
+	}
+	return r
+}
+
+// shard returns the shard responsible for the provided game ID.
+func (r *registry) shard(id string) *registryShard {
+	return &r.shards[maphash.String(r.seed, id)%registryShards]
+}
+
+// get looks up the game with the provided ID.
+func (r *registry) get(id string) (*Game, bool) {
+	s := r.shard(id)
+	s.mu.RLock()
+	g, ok := s.games[id]
+	s.mu.RUnlock()
+	return g, ok
+}
+
+// each calls fn for every game in the registry. It only holds
+// one shard's lock at a time, and never while calling fn, so
+// sweeping the registry doesn't block lookups.
+func (r *registry) each(fn func(id string, g *Game)) {
+	for i := range r.shards {
+		for id, g := range r.shards[i].snapshot() {
+			fn(id, g)
+		}
+	}
+}
+
+// remove removes the game with the ID, returning the game
+// if there was one.
+func (r *registry) remove(id string) (*Game, bool) {
+	s := r.shard(id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.games[id]
+	delete(s.games, id)
+	return g, ok
+}
+
+// removeIfAbandoned removes the game with the provided ID if it
+// has no players and is more than 24 hours old.
+func (r *registry) removeIfAbandoned(id string, now time.Time) {
+	s := r.shard(id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g, ok := s.games[id]
+	if !ok {
+		return
+	}
+	g.mu.Lock()
+	abandoned := g.abandoned(now)
+	g.mu.Unlock()
+	if abandoned {
+		delete(s.games, id)
+	}
+}
+
+// snapshot returns a copy of the shard's games.
+func (s *registryShard) snapshot() map[string]*Game {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	games := make(map[string]*Game, len(s.games))
+	for id, g := range s.games {
+		games[id] = g
+	}
+	return games
+}
@@ -0,0 +1,159 @@
+package gameapi
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRegistryRemoveIfAbandoned(t *testing.T) {
+	now := time.Now()
+	r := newRegistry()
+	for i, age := range []time.Duration{time.Hour, 25 * time.Hour, 25 * time.Hour} {
+		g, err := ReconstructGame(NewState(int64(i), exampleWords))
+		if err != nil {
+			t.Fatal(err)
+		}
+		g.CreatedAt = now.Add(-age)
+		r.shard(fmt.Sprint(i)).games[fmt.Sprint(i)] = &g
+	}
+
+	// Game 2 has a player that's still around.
+	g, _ := r.get("2")
+	g.markSeen("alice", "alice", 1, now)
+
+	for _, id := range []string{"0", "1", "2"} {
+		r.removeIfAbandoned(id, now)
+	}
+	for id, want := range map[string]bool{"0": true, "1": false, "2": true} {
+		if _, ok := r.get(id); ok != want {
+			t.Errorf("game %s present = %t, want %t", id, ok, want)
+		}
+	}
+}
+
+// mutexRegistry is the single-mutex map the handler used before
+// the registry was sharded. It's kept here as a baseline for
+// the benchmarks below.
+type mutexRegistry struct {
+	mu    sync.Mutex
+	games map[string]*Game
+}
+
+func (r *mutexRegistry) get(id string) (*Game, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	g, ok := r.games[id]
+	return g, ok
+}
+
+func (r *mutexRegistry) prune(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, g := range r.games {
+		pruneGame(g, now)
+	}
+}
+
+// pruneGame removes the game's departed players, as the
+// handler does by publishing a prune command.
+func pruneGame(g *Game, now time.Time) {
+	ids, _ := g.departed(now)
+	g.removePlayers(ids, now)
+}
+
+const benchmarkGames = 5000
+
+func populateGames(add func(id string, g *Game)) []string {
+	ids := make([]string, benchmarkGames)
+	now := time.Now()
+	for i := range ids {
+		ids[i] = fmt.Sprintf("game-%d", i)
+		g, err := ReconstructGame(NewState(int64(i), exampleWords))
+		if err != nil {
+			panic(err)
+		}
+		g.CreatedAt = now
+		for p := 0; p < 4; p++ {
+			g.markSeen(fmt.Sprint(p), "", p%2+1, now)
+		}
+		add(ids[i], &g)
+	}
+	return ids
+}
+
+// runLookups performs lookups of random games from many goroutines
+// while another goroutine continuously sweeps the registry.
+func runLookups(b *testing.B, ids []string, get func(string) (*Game, bool), prune func(time.Time)) {
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				prune(time.Now())
+			}
+		}
+	}()
+	defer close(done)
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if _, ok := get(ids[i%len(ids)]); !ok {
+				b.Fatal("game not found")
+			}
+			i += 7
+		}
+	})
+}
+
+func BenchmarkRegistryGet(b *testing.B) {
+	b.Run("mutex", func(b *testing.B) {
+		r := &mutexRegistry{games: make(map[string]*Game)}
+		ids := populateGames(func(id string, g *Game) { r.games[id] = g })
+		runLookups(b, ids, r.get, r.prune)
+	})
+	b.Run("sharded", func(b *testing.B) {
+		r := newRegistry()
+		ids := populateGames(func(id string, g *Game) { r.shard(id).games[id] = g })
+		runLookups(b, ids, r.get, func(now time.Time) {
+			r.each(func(id string, g *Game) { pruneGame(g, now) })
+		})
+	})
+}
+
+// BenchmarkExec applies commands to random games through the
+// broker, as requests do, from many goroutines at once. Most
+// commands record that players are still around.
+func BenchmarkExec(b *testing.B) {
+	h := Handler(map[string][]string{"example": exampleWords}).(*handler)
+	ctx := context.Background()
+	ids := make([]string, 500)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("game-%d", i)
+		if _, err := h.exec(ctx, command{Op: opNewGame, GameID: ids[i], Seed: Seed(i), Words: exampleWords}); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := h.exec(ctx, command{Op: opSeen, GameID: ids[i], Seed: Seed(i), PlayerID: "alice", Team: 1}); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			n := i % len(ids)
+			cmd := command{Op: opPresence, GameID: ids[n], Seed: Seed(n), PlayerID: "alice", Team: 1}
+			if _, err := h.exec(ctx, cmd); err != nil {
+				b.Fatal(err)
+			}
+			i += 7
+		}
+	})
+}
@@ -0,0 +1,207 @@
+package gameapi
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// simulation scripts a game end to end through the HTTP API.
+// Every player long-polls for events in the background, the way
+// the client does, so that once the script is done the test can
+// check that every player observed the same game.
+type simulation struct {
+	t      *testing.T
+	h      *handler
+	id     string
+	game   simGame
+	cancel context.CancelFunc
+	ctx    context.Context
+	wg     sync.WaitGroup
+}
+
+type simGame struct {
+	State     GameState `json:"state"`
+	Words     []string  `json:"words"`
+	OneLayout []string  `json:"one_layout"`
+	TwoLayout []string  `json:"two_layout"`
+}
+
+type simPlayer struct {
+	sim  *simulation
+	id   string
+	team int
+
+	mu     sync.Mutex
+	events []Event
+}
+
+func newSimulation(t *testing.T, id string) *simulation {
+	clock := NewFakeClock(time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC))
+	s := &simulation{t: t, h: newTestHandler(clock), id: id}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	if code := post(t, s.h, "/new-game", map[string]interface{}{"game_id": id}, &s.game); code != 200 {
+		t.Fatalf("new-game status = %d, want 200", code)
+	}
+	return s
+}
+
+// join adds a player to the provided team and starts
+// long-polling for events on their behalf.
+func (s *simulation) join(id string, team int) *simPlayer {
+	p := &simPlayer{sim: s, id: id, team: team}
+	s.send(p, "/ping", nil)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for s.ctx.Err() == nil {
+			p.mu.Lock()
+			last := len(p.events)
+			p.mu.Unlock()
+
+			var up GameUpdate
+			postContext(s.ctx, s.t, s.h, "/events", s.body(p, map[string]interface{}{"last_event": last}), &up)
+			p.mu.Lock()
+			p.events = append(p.events, up.Events...)
+			p.mu.Unlock()
+		}
+	}()
+	return p
+}
+
+func (s *simulation) body(p *simPlayer, extra map[string]interface{}) map[string]interface{} {
+	b := map[string]interface{}{
+		"game_id":   s.id,
+		"seed":      s.game.State.Seed,
+		"player_id": p.id,
+		"name":      p.id,
+		"team":      p.team,
+	}
+	for k, v := range extra {
+		b[k] = v
+	}
+	return b
+}
+
+func (s *simulation) send(p *simPlayer, path string, extra map[string]interface{}) {
+	s.t.Helper()
+	if code := post(s.t, s.h, path, s.body(p, extra), nil); code != 200 {
+		s.t.Fatalf("%s: POST %s: status = %d", p.id, path, code)
+	}
+}
+
+func (p *simPlayer) guess(index int) { p.sim.send(p, "/guess", map[string]interface{}{"index": index}) }
+func (p *simPlayer) endTurn()        { p.sim.send(p, "/end-turn", nil) }
+func (p *simPlayer) chat(msg string) { p.sim.send(p, "/chat", map[string]interface{}{"message": msg}) }
+
+// guessGreens has the player guess every card that's green on the
+// other side's key and hasn't already been revealed as green.
+func (p *simPlayer) guessGreens() {
+	key := p.sim.game.TwoLayout
+	if p.team == 2 {
+		key = p.sim.game.OneLayout
+	}
+	for i, c := range key {
+		if c == "g" && !p.sim.exposedGreen(i) {
+			p.guess(i)
+		}
+	}
+}
+
+func (s *simulation) exposedGreen(i int) bool {
+	g, _ := s.h.games.get(s.id)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.exposedColor(i, g.status().Revealed) == Green
+}
+
+// status returns the game's status as computed by the server.
+func (s *simulation) status() Status {
+	g, _ := s.h.games.get(s.id)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.status()
+}
+
+// finish waits for every player to observe every event,
+// stops the players' long polls and checks that all of
+// the players observed identical events.
+func (s *simulation) finish(players ...*simPlayer) {
+	s.t.Helper()
+	g, _ := s.h.games.get(s.id)
+	g.mu.Lock()
+	want := append([]Event(nil), g.Events...)
+	g.mu.Unlock()
+
+	for _, p := range players {
+		waitFor(s.t, p.id+" to observe every event", func() bool {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			return len(p.events) == len(want)
+		})
+	}
+	s.cancel()
+	s.wg.Wait()
+
+	for _, p := range players {
+		if !reflect.DeepEqual(p.events, want) {
+			s.t.Errorf("%s observed events %+v, want %+v", p.id, p.events, want)
+		}
+	}
+}
+
+func TestSimulateWin(t *testing.T) {
+	s := newSimulation(t, "win")
+	alice, bob := s.join("alice", 1), s.join("bob", 2)
+	carol := s.join("carol", 1)
+
+	alice.chat("I'll start")
+	alice.guessGreens()
+	if st := s.status(); st.Turn != 2 || st.TokensConsumed != 1 {
+		t.Fatalf("status after side A's greens = %+v", st)
+	}
+	bob.guessGreens()
+
+	st := s.status()
+	if !st.Won || st.GreensLeft != 0 {
+		t.Errorf("final status = %+v, want won", st)
+	}
+	s.finish(alice, bob, carol)
+
+	var sum StatsSummary
+	post(t, s.h, "/stats", nil, &sum)
+	if sum.GamesFinished != 1 || sum.WinRate != 1 {
+		t.Errorf("stats = %+v, want one won game", sum)
+	}
+}
+
+func TestSimulateLoss(t *testing.T) {
+	s := newSimulation(t, "loss")
+	alice, bob := s.join("alice", 1), s.join("bob", 2)
+
+	alice.endTurn() // ignored: no one has guessed yet
+	for i, c := range s.game.TwoLayout {
+		if c == "t" {
+			alice.guess(i)
+			break
+		}
+	}
+	if st := s.status(); st.Turn != 2 || st.TokensConsumed != 1 {
+		t.Fatalf("status after a tan guess = %+v", st)
+	}
+	bob.endTurn()
+	for i, c := range s.game.TwoLayout {
+		if c == "b" {
+			alice.guess(i)
+			break
+		}
+	}
+
+	if st := s.status(); !st.Lost {
+		t.Errorf("final status = %+v, want lost", st)
+	}
+	s.finish(alice, bob)
+}
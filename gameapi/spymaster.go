@@ -0,0 +1,95 @@
+package gameapi
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// A Spymaster suggests clues for one side of a game. Package
+// gameapi/ai provides an implementation based on word embeddings.
+type Spymaster interface {
+	// SuggestClues returns up to max clues for the provided
+	// board, best first.
+	SuggestClues(b Board, max int) []Clue
+}
+
+// Board is a snapshot of a game's board as seen by the
+// players on one side.
+type Board struct {
+	Words []string
+	// Key holds the colors on the side's key card.
+	Key []Color
+	// Hidden is false for cards that have already been revealed
+	// as green, or that the other side has already guessed.
+	Hidden []bool
+}
+
+// Clue is a one-word clue for a number of the cards on a board.
+type Clue struct {
+	Word    string   `json:"word"`
+	Number  int      `json:"number"`
+	Targets []string `json:"targets"`
+	Score   float64  `json:"score"`
+}
+
+// WithSpymaster configures the handler to suggest clues
+// with the provided spymaster.
+func WithSpymaster(s Spymaster) Option {
+	return func(h *handler) {
+		h.spymaster = s
+	}
+}
+
+// board returns the game's board as seen by the provided team.
+// The caller must hold g.mu.
+func (g *Game) board(team int) Board {
+	st := g.status()
+	b := Board{
+		Words:  append([]string(nil), g.Words...),
+		Key:    append([]Color(nil), g.layout(team)...),
+		Hidden: make([]bool, len(g.Words)),
+	}
+	for i := range b.Hidden {
+		b.Hidden[i] = !st.Revealed[team-1][i] && g.exposedColor(i, st.Revealed) != Green
+	}
+	return b
+}
+
+// POST /games/{id}/suggest-clue
+func (h *handler) handleSuggestClue(rw http.ResponseWriter, req *http.Request) {
+	var body SuggestClueRequest
+	err := json.NewDecoder(req.Body).Decode(&body)
+	if err != nil || (body.Team != 1 && body.Team != 2) || body.Count < 0 {
+		writeError(rw, "malformed_body", "Unable to parse request body.", 400)
+		return
+	}
+	if body.Count == 0 {
+		body.Count = 3
+	} else if body.Count > 10 {
+		body.Count = 10
+	}
+	if h.spymaster == nil {
+		writeError(rw, "not_implemented", "This server isn't configured to suggest clues.", 501)
+		return
+	}
+
+	g, ok := h.games.get(req.PathValue("id"))
+	if !ok {
+		writeError(rw, "not_found", "Game not found", 404)
+		return
+	}
+	g.mu.Lock()
+	if body.Seed != g.Seed {
+		g.mu.Unlock()
+		writeError(rw, "bad_seed", "Request intended for a different game seed.", 400)
+		return
+	}
+	b := g.board(body.Team)
+	g.mu.Unlock()
+
+	clues := h.spymaster.SuggestClues(b, body.Count)
+	if clues == nil {
+		clues = []Clue{}
+	}
+	writeJSON(rw, SuggestClueResponse{Clues: clues})
+}
@@ -0,0 +1,182 @@
+package gameapi
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// statsRetention is how far back the server keeps
+// aggregate statistics.
+const statsRetention = 7 * 24 * time.Hour
+
+// statsRecorder maintains rolling, hourly aggregates of server
+// activity. It's updated as games and players come and go so that
+// serving /stats never requires visiting every game.
+//
+// A nil *statsRecorder discards everything recorded to it.
+type statsRecorder struct {
+	mu            sync.Mutex
+	activeGames   int
+	activePlayers int
+	buckets       [statsRetention / time.Hour]statsBucket
+}
+
+type statsBucket struct {
+	hour          time.Time
+	gamesStarted  int
+	gamesFinished int
+	gamesWon      int
+	tokensUsed    int
+	peakPlayers   int
+	wordLists     map[string]int
+}
+
+func newStatsRecorder() *statsRecorder {
+	return &statsRecorder{}
+}
+
+// bucket returns the bucket for the hour containing t,
+// resetting it if it still holds an older hour's data.
+// The caller must hold s.mu.
+func (s *statsRecorder) bucket(t time.Time) *statsBucket {
+	hour := t.UTC().Truncate(time.Hour)
+	b := &s.buckets[(hour.Unix()/3600)%int64(len(s.buckets))]
+	if !b.hour.Equal(hour) {
+		*b = statsBucket{
+			hour:        hour,
+			peakPlayers: s.activePlayers,
+			wordLists:   map[string]int{},
+		}
+	}
+	return b
+}
+
+func (s *statsRecorder) gameStarted(now time.Time, wordList string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := s.bucket(now)
+	b.gamesStarted++
+	b.wordLists[wordList]++
+}
+
+func (s *statsRecorder) gameFinished(now time.Time, st Status) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := s.bucket(now)
+	b.gamesFinished++
+	b.tokensUsed += st.TokensConsumed
+	if st.Won {
+		b.gamesWon++
+	}
+}
+
+// playersChanged records that a game's player count went
+// from before to after.
+func (s *statsRecorder) playersChanged(now time.Time, before, after int) {
+	if s == nil || before == after {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.activePlayers += after - before
+	if before == 0 {
+		s.activeGames++
+	} else if after == 0 {
+		s.activeGames--
+	}
+	if b := s.bucket(now); s.activePlayers > b.peakPlayers {
+		b.peakPlayers = s.activePlayers
+	}
+}
+
+// StatsPoint holds the aggregates for a single hour.
+type StatsPoint struct {
+	Hour          time.Time `json:"hour"`
+	GamesStarted  int       `json:"games_started"`
+	GamesFinished int       `json:"games_finished"`
+	GamesWon      int       `json:"games_won"`
+	PeakPlayers   int       `json:"peak_players"`
+}
+
+// StatsSummary aggregates statistics over a window of time.
+type StatsSummary struct {
+	ActiveGames   int             `json:"active_games"`
+	ActivePlayers int             `json:"active_players"`
+	Window        string          `json:"window"`
+	GamesStarted  int             `json:"games_started"`
+	GamesFinished int             `json:"games_finished"`
+	WinRate       float64         `json:"win_rate"`
+	AvgTokens     float64         `json:"avg_tokens"`
+	PeakPlayers   int             `json:"peak_players"`
+	WordLists     []WordListUsage `json:"word_lists"`
+	Series        []StatsPoint    `json:"series"`
+}
+
+type WordListUsage struct {
+	Name  string `json:"name"`
+	Games int    `json:"games"`
+}
+
+// summarize aggregates the hourly buckets within window of now.
+// Hours with no recorded activity are included in the series
+// with zero counts.
+func (s *statsRecorder) summarize(now time.Time, window time.Duration) StatsSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sum := StatsSummary{
+		ActiveGames:   s.activeGames,
+		ActivePlayers: s.activePlayers,
+		Window:        window.String(),
+		PeakPlayers:   s.activePlayers,
+		WordLists:     []WordListUsage{},
+		Series:        []StatsPoint{},
+	}
+
+	var won, tokens int
+	wordLists := map[string]int{}
+	end := now.UTC().Truncate(time.Hour)
+	for hour := end.Add(-window + time.Hour); !hour.After(end); hour = hour.Add(time.Hour) {
+		pt := StatsPoint{Hour: hour}
+		b := &s.buckets[(hour.Unix()/3600)%int64(len(s.buckets))]
+		if b.hour.Equal(hour) {
+			pt.GamesStarted = b.gamesStarted
+			pt.GamesFinished = b.gamesFinished
+			pt.GamesWon = b.gamesWon
+			pt.PeakPlayers = b.peakPlayers
+			tokens += b.tokensUsed
+			for name, n := range b.wordLists {
+				wordLists[name] += n
+			}
+		}
+		sum.GamesStarted += pt.GamesStarted
+		sum.GamesFinished += pt.GamesFinished
+		won += pt.GamesWon
+		if pt.PeakPlayers > sum.PeakPlayers {
+			sum.PeakPlayers = pt.PeakPlayers
+		}
+		sum.Series = append(sum.Series, pt)
+	}
+	if sum.GamesFinished > 0 {
+		sum.WinRate = float64(won) / float64(sum.GamesFinished)
+		sum.AvgTokens = float64(tokens) / float64(sum.GamesFinished)
+	}
+
+	for name, n := range wordLists {
+		sum.WordLists = append(sum.WordLists, WordListUsage{Name: name, Games: n})
+	}
+	sort.Slice(sum.WordLists, func(i, j int) bool {
+		if sum.WordLists[i].Games != sum.WordLists[j].Games {
+			return sum.WordLists[i].Games > sum.WordLists[j].Games
+		}
+		return sum.WordLists[i].Name < sum.WordLists[j].Name
+	})
+	return sum
+}
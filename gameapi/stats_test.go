@@ -0,0 +1,42 @@
+package gameapi
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStatsSummarize(t *testing.T) {
+	s := newStatsRecorder()
+	now := time.Date(2020, 4, 1, 12, 30, 0, 0, time.UTC)
+
+	s.gameStarted(now.Add(-2*time.Hour), "green")
+	s.gameStarted(now.Add(-time.Hour), "green")
+	s.gameStarted(now, "original")
+	s.playersChanged(now.Add(-time.Hour), 0, 3)
+	s.playersChanged(now, 3, 1)
+	s.gameFinished(now, Status{Won: true, TokensConsumed: 7})
+	s.gameFinished(now, Status{Lost: true, TokensConsumed: 3})
+
+	sum := s.summarize(now, 2*time.Hour)
+	if sum.GamesStarted != 2 || sum.GamesFinished != 2 {
+		t.Errorf("started, finished = %d, %d, want 2, 2", sum.GamesStarted, sum.GamesFinished)
+	}
+	if sum.WinRate != 0.5 || sum.AvgTokens != 5 {
+		t.Errorf("win rate, avg tokens = %v, %v, want 0.5, 5", sum.WinRate, sum.AvgTokens)
+	}
+	if sum.ActiveGames != 1 || sum.ActivePlayers != 1 || sum.PeakPlayers != 3 {
+		t.Errorf("active games, active players, peak = %d, %d, %d, want 1, 1, 3",
+			sum.ActiveGames, sum.ActivePlayers, sum.PeakPlayers)
+	}
+	if len(sum.Series) != 2 {
+		t.Errorf("len(sum.Series) = %d, want 2", len(sum.Series))
+	}
+	if len(sum.WordLists) != 2 || sum.WordLists[0].Name != "green" {
+		t.Errorf("sum.WordLists = %+v", sum.WordLists)
+	}
+
+	// A week later, everything has rolled out of the window.
+	if sum := s.summarize(now.Add(statsRetention), 24*time.Hour); sum.GamesStarted != 0 {
+		t.Errorf("games started a week later = %d, want 0", sum.GamesStarted)
+	}
+}
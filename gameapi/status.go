@@ -0,0 +1,145 @@
+package gameapi
+
+// Status summarizes the progress of a game. It's derived by
+// replaying the game's events with the same rules the client
+// uses to render the board, so the server and client always
+// agree on whose turn it is and whether the game is over.
+type Status struct {
+	Turn           int  `json:"turn"` // 0 until the first guess
+	GreensLeft     int  `json:"greens_left"`
+	TokensConsumed int  `json:"tokens_consumed"`
+	Won            bool `json:"won"`
+	Lost           bool `json:"lost"`
+	Ended          bool `json:"ended"` // by an admin, before it was won or lost
+
+	// Revealed records, for each side, which cells have
+	// been revealed by the other side's guesses. Revealed[0]
+	// holds cells revealed on OneLayout and Revealed[1] those
+	// revealed on TwoLayout.
+	Revealed [2][]bool `json:"-"`
+}
+
+// Finished returns true if the game has been won, lost or ended.
+func (s Status) Finished() bool {
+	return s.Won || s.Lost || s.Ended
+}
+
+// Status replays the game's events to compute its current status.
+// Clients can use it to follow a game by constructing a Game with
+// its layouts and events.
+func (g *Game) Status() Status {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.status()
+}
+
+// status replays the game's events to compute its current status.
+// The caller must hold the game's mutex.
+func (g *Game) status() (s Status) {
+	n := len(g.OneLayout)
+	s.Revealed = [2][]bool{make([]bool, n), make([]bool, n)}
+
+	for _, e := range g.Events {
+		if e.Type == "game_ended" {
+			s.Ended = true
+			break
+		}
+		if e.Team != 1 && e.Team != 2 {
+			continue
+		}
+		switch e.Type {
+		case "guess":
+			if e.Index < 0 || e.Index >= n || s.Turn == otherTeam(e.Team) {
+				continue
+			}
+			// A guess by one side reveals the card on the other
+			// side's key.
+			s.Revealed[otherTeam(e.Team)-1][e.Index] = true
+			switch g.layout(otherTeam(e.Team))[e.Index] {
+			case Tan:
+				s.Turn = otherTeam(e.Team)
+				if !g.hasHiddenGreens(e.Team, s.Revealed) {
+					s.Turn = e.Team
+				}
+				s.TokensConsumed++
+			case Green:
+				s.Turn = e.Team
+				if !g.hasHiddenGreens(otherTeam(e.Team), s.Revealed) {
+					s.Turn = otherTeam(e.Team)
+					s.TokensConsumed++
+				}
+			case Black:
+				s.Lost = true
+			}
+		case "end_turn":
+			if s.Turn != e.Team {
+				continue
+			}
+			if g.hasHiddenGreens(e.Team, s.Revealed) {
+				s.Turn = otherTeam(e.Team)
+			}
+			s.TokensConsumed++
+		}
+	}
+
+	s.GreensLeft = greenCount
+	for i := 0; i < n; i++ {
+		if g.exposedColor(i, s.Revealed) == Green {
+			s.GreensLeft--
+		}
+	}
+	s.Won = !s.Lost && !s.Ended && s.Turn != 0 && s.GreensLeft == 0
+	return s
+}
+
+// layout returns the key card held by the provided team.
+func (g *Game) layout(team int) []Color {
+	if team == 2 {
+		return g.TwoLayout
+	}
+	return g.OneLayout
+}
+
+// exposedColor returns the color shown on the board for the
+// cell at index i, or Tan if the cell hasn't revealed a green
+// or black card on either side.
+func (g *Game) exposedColor(i int, revealed [2][]bool) Color {
+	a, b := revealed[0][i], revealed[1][i]
+	switch {
+	case a && g.OneLayout[i] == Black, b && g.TwoLayout[i] == Black:
+		return Black
+	case a && g.OneLayout[i] == Green, b && g.TwoLayout[i] == Green:
+		return Green
+	default:
+		return Tan
+	}
+}
+
+// hasHiddenGreens returns true if the provided team's key
+// still has green cards that haven't been revealed.
+func (g *Game) hasHiddenGreens(team int, revealed [2][]bool) bool {
+	for i, c := range g.layout(team) {
+		if c == Green && g.exposedColor(i, revealed) != Green {
+			return true
+		}
+	}
+	return false
+}
+
+func otherTeam(team int) int {
+	if team == 1 {
+		return 2
+	}
+	return 1
+}
+
+// greenCount is the number of distinct green cards
+// across both keys.
+var greenCount = func() (n int) {
+	for _, colors := range colorDistribution {
+		if colors[0] == Green || colors[1] == Green {
+			n++
+		}
+	}
+	return n
+}()
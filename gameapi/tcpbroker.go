@@ -0,0 +1,251 @@
+package gameapi
+
+import (
+	"encoding/gob"
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// maxQueuedMessages bounds the number of messages buffered for a
+// single connection to a broker hub. A connection that falls this
+// far behind is dropped, and catches up when it reconnects.
+const maxQueuedMessages = 1 << 20
+
+// brokerFrame is sent from a TCPBroker to the hub.
+type brokerFrame struct {
+	Subscribe bool
+	Message   Message
+}
+
+// ServeBroker accepts connections on l and relays messages between
+// them, acting as the hub for greenapid instances configured with
+// a TCPBroker. It retains messages in memory the same way a
+// LocalBroker does.
+func ServeBroker(l net.Listener) error {
+	hub := NewLocalBroker()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go serveBrokerConn(hub, conn)
+	}
+}
+
+func serveBrokerConn(hub *LocalBroker, conn net.Conn) {
+	defer conn.Close()
+
+	q := newMessageQueue()
+	defer q.close()
+	go func() {
+		enc := gob.NewEncoder(conn)
+		for {
+			m, ok := q.pop()
+			if !ok {
+				return
+			}
+			if err := enc.Encode(m); err != nil {
+				conn.Close()
+				return
+			}
+		}
+	}()
+
+	dec := gob.NewDecoder(conn)
+	for {
+		var f brokerFrame
+		if err := dec.Decode(&f); err != nil {
+			return
+		}
+		if f.Subscribe {
+			push := func(m Message) {
+				if !q.push(m) {
+					conn.Close()
+				}
+			}
+			cancel := hub.subscribe(push, push)
+			defer cancel()
+			continue
+		}
+		hub.Publish(f.Message)
+	}
+}
+
+// messageQueue is an unbounded queue of messages, used to
+// decouple the hub from slow connections.
+type messageQueue struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	msgs   []Message
+	closed bool
+}
+
+func newMessageQueue() *messageQueue {
+	q := &messageQueue{}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// push adds m to the queue, returning false if the queue
+// is closed or full.
+func (q *messageQueue) push(m Message) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed || len(q.msgs) >= maxQueuedMessages {
+		return false
+	}
+	q.msgs = append(q.msgs, m)
+	q.cond.Signal()
+	return true
+}
+
+// pop removes the oldest message from the queue, waiting for one
+// if the queue is empty. It returns false once the queue is closed.
+func (q *messageQueue) pop() (Message, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.msgs) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed {
+		return Message{}, false
+	}
+	m := q.msgs[0]
+	q.msgs = q.msgs[1:]
+	return m, true
+}
+
+func (q *messageQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.cond.Broadcast()
+	q.mu.Unlock()
+}
+
+// ErrBrokerUnavailable is returned when publishing to a
+// TCPBroker that's not connected to its hub.
+var ErrBrokerUnavailable = errors.New("gameapi: not connected to broker")
+
+// TCPBroker is a Broker that relays messages through a hub
+// started with ServeBroker, typically in another process.
+type TCPBroker struct {
+	addr string
+
+	mu     sync.Mutex
+	conn   net.Conn
+	enc    *gob.Encoder
+	subs   []*deliverer
+	closed bool
+}
+
+// DialBroker connects to the broker hub listening at addr. If
+// the connection is lost, it's re-established in the background,
+// and the hub replays the messages it has retained.
+func DialBroker(addr string) (*TCPBroker, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	b := &TCPBroker{addr: addr}
+	b.connected(conn)
+	return b, nil
+}
+
+func (b *TCPBroker) Publish(m Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.enc == nil {
+		return ErrBrokerUnavailable
+	}
+	return b.enc.Encode(brokerFrame{Message: m})
+}
+
+// Subscribe registers fn to receive messages. Only the first
+// subscriber receives the messages retained by the hub.
+func (b *TCPBroker) Subscribe(fn func(Message)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs = append(b.subs, newDeliverer(fn))
+	if len(b.subs) == 1 && b.enc != nil {
+		b.enc.Encode(brokerFrame{Subscribe: true})
+	}
+}
+
+// Close disconnects from the hub.
+func (b *TCPBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for _, d := range b.subs {
+		d.close()
+	}
+	if b.conn == nil {
+		return nil
+	}
+	return b.conn.Close()
+}
+
+// connected starts using conn to communicate with the hub.
+func (b *TCPBroker) connected(conn net.Conn) {
+	b.mu.Lock()
+	b.conn = conn
+	b.enc = gob.NewEncoder(conn)
+	if len(b.subs) > 0 {
+		b.enc.Encode(brokerFrame{Subscribe: true})
+	}
+	b.mu.Unlock()
+
+	go b.receive(conn)
+}
+
+// receive delivers messages read from conn to subscribers
+// until the connection fails, and then reconnects.
+func (b *TCPBroker) receive(conn net.Conn) {
+	dec := gob.NewDecoder(conn)
+	for {
+		var m Message
+		if err := dec.Decode(&m); err != nil {
+			break
+		}
+		b.mu.Lock()
+		subs := b.subs
+		b.mu.Unlock()
+		for _, d := range subs {
+			d.deliver(m)
+		}
+	}
+	conn.Close()
+
+	b.mu.Lock()
+	b.conn, b.enc = nil, nil
+	closed := b.closed
+	b.mu.Unlock()
+	if closed {
+		return
+	}
+
+	for backoff := 100 * time.Millisecond; ; backoff *= 2 {
+		if backoff > 10*time.Second {
+			backoff = 10 * time.Second
+		}
+		time.Sleep(backoff)
+
+		conn, err := net.Dial("tcp", b.addr)
+		if err != nil {
+			log.Printf("gameapi: reconnecting to broker: %s", err)
+			continue
+		}
+		b.mu.Lock()
+		closed := b.closed
+		b.mu.Unlock()
+		if closed {
+			conn.Close()
+			return
+		}
+		b.connected(conn)
+		return
+	}
+}
@@ -0,0 +1,278 @@
+package gameapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimerTokens is the number of timer tokens a game of Duet starts
+// with. Games here can go on for longer, but a game that uses more
+// tokens than that has none left over.
+const TimerTokens = 9
+
+// WinPoints is what winning a game scores on the team leaderboards,
+// before the timer tokens left over are added.
+const WinPoints = 10
+
+// maxTeamSize is the most players a team may have.
+const maxTeamSize = 10
+
+// The operations that change teams. They're published to the broker
+// like the commands that change games, so that every instance keeps
+// the same teams, under the empty game ID, which no game can have,
+// so that every instance applies them in the same order. Brokers
+// retain them for as long as they run, so instances that start
+// later, or that only keep their history in memory, catch up on
+// every team.
+const (
+	opCreateTeam = "create_team"
+	opDeleteTeam = "delete_team"
+)
+
+var (
+	errTeamNotFound  = &apiError{"team_not_found", "No such team.", 404}
+	errTeamNameTaken = &apiError{"team_name_taken", "There's already a team with that name.", 409}
+	errBadTeamSize   = &apiError{"bad_team_size",
+		"Teams must have 2 to " + strconv.Itoa(maxTeamSize) + " players.", 400}
+	errBadSeason = &apiError{"bad_season", `The season must be a month, like "2026-10", or "all".`, 400}
+
+	errTeamSessionRequired = &apiError{"session_required", "Sign in to create a team.", 401}
+	errNotTeamMember       = &apiError{"not_team_member", "You may only create teams that you're a member of.", 403}
+)
+
+// createTeam adds a team, unless its name is taken. Names are
+// compared without regard to case. Adding a team that's already
+// been added, as brokers replay retained commands to instances that
+// restart, does nothing.
+func (hist *GameHistory) createTeam(t *Team) error {
+	hist.mu.Lock()
+	defer hist.mu.Unlock()
+	if _, ok := hist.teams[t.ID]; ok {
+		return nil
+	}
+	for _, other := range hist.teams {
+		if strings.EqualFold(other.Name, t.Name) {
+			return errTeamNameTaken
+		}
+	}
+	return hist.write(historyRecord{Team: t})
+}
+
+// deleteTeam removes a team. Its games stay in the history.
+func (hist *GameHistory) deleteTeam(id string) error {
+	hist.mu.Lock()
+	defer hist.mu.Unlock()
+	if _, ok := hist.teams[id]; !ok {
+		return errTeamNotFound
+	}
+	return hist.write(historyRecord{DeletedTeam: id})
+}
+
+// listTeams returns the teams, ordered by name.
+func (hist *GameHistory) listTeams() []Team {
+	hist.mu.Lock()
+	defer hist.mu.Unlock()
+	teams := make([]Team, 0, len(hist.teams))
+	for _, t := range hist.teams {
+		teams = append(teams, *t)
+	}
+	sort.Slice(teams, func(i, j int) bool {
+		if teams[i].Name != teams[j].Name {
+			return teams[i].Name < teams[j].Name
+		}
+		return teams[i].ID < teams[j].ID
+	})
+	return teams
+}
+
+// leaderboard ranks every team by the games that finished from start
+// until end. Zero times leave the season unbounded on that side.
+func (hist *GameHistory) leaderboard(start, end time.Time) []TeamStanding {
+	teams := hist.listTeams()
+	hist.mu.Lock()
+	defer hist.mu.Unlock()
+
+	standings := make([]TeamStanding, len(teams))
+	members := make([]map[string]bool, len(teams))
+	for i, t := range teams {
+		standings[i] = TeamStanding{TeamID: t.ID, Name: t.Name}
+		members[i] = make(map[string]bool, len(t.PlayerIDs))
+		for _, id := range t.PlayerIDs {
+			members[i][id] = true
+		}
+	}
+	for _, r := range hist.results {
+		if (!start.IsZero() && r.FinishedAt.Before(start)) || (!end.IsZero() && !r.FinishedAt.Before(end)) {
+			continue
+		}
+		if len(r.Players) < 2 {
+			continue
+		}
+		for i := range teams {
+			if !teamPlayed(members[i], r) {
+				continue
+			}
+			s := &standings[i]
+			s.GamesPlayed++
+			if !r.Won {
+				s.Losses++
+				continue
+			}
+			left := max(TimerTokens-r.TokensUsed, 0)
+			s.Wins++
+			s.TokensRemaining += left
+			s.Score += WinPoints + left
+		}
+	}
+
+	// Order teams with the same score by their wins, but
+	// give them the same rank.
+	sort.SliceStable(standings, func(i, j int) bool {
+		if standings[i].Score != standings[j].Score {
+			return standings[i].Score > standings[j].Score
+		}
+		return standings[i].Wins > standings[j].Wins
+	})
+	for i := range standings {
+		standings[i].Rank = i + 1
+		if i > 0 && standings[i].Score == standings[i-1].Score {
+			standings[i].Rank = standings[i-1].Rank
+		}
+	}
+	return standings
+}
+
+// teamPlayed returns true if every player in the game was one
+// of the team's members.
+func teamPlayed(members map[string]bool, r gameResult) bool {
+	for _, p := range r.Players {
+		if !members[p.PlayerID] {
+			return false
+		}
+	}
+	return true
+}
+
+// parseSeason returns the bounds of the season named s, which
+// defaults to the month that now is in.
+func parseSeason(s string, now time.Time) (name string, start, end time.Time, err error) {
+	switch s {
+	case "all":
+		return s, time.Time{}, time.Time{}, nil
+	case "":
+		now = now.UTC()
+		start = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		if start, err = time.Parse("2006-01", s); err != nil {
+			return "", time.Time{}, time.Time{}, errBadSeason
+		}
+	}
+	return start.Format("2006-01"), start, start.AddDate(0, 1, 0), nil
+}
+
+func (h *handler) registerTeams() {
+	h.handle("POST /teams", h.handleCreateTeam)
+	h.handle("GET /teams", h.handleListTeams)
+	h.handle("GET /leaderboards/teams", h.handleTeamLeaderboard)
+}
+
+// applyTeam applies a command that creates or deletes a team.
+func (h *handler) applyTeam(cmd command) (interface{}, error) {
+	if cmd.Op == opDeleteTeam {
+		if err := h.history.deleteTeam(cmd.TargetID); err != nil {
+			return nil, err
+		}
+		return StatusResponse{Status: "ok"}, nil
+	}
+	if err := h.history.createTeam(cmd.NewTeam); err != nil {
+		return nil, err
+	}
+	return cmd.NewTeam, nil
+}
+
+// POST /teams
+// Creates a team of players. A team's members can't be changed, so
+// that its standing can't be rewritten partway through a season.
+// When players have accounts, only a signed-in player can create a
+// team, and only one that they're a member of, so that no one can
+// enter others in the leaderboards.
+func (h *handler) handleCreateTeam(rw http.ResponseWriter, req *http.Request) {
+	var body TeamRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(rw, "malformed_body", "Unable to parse request body.", 400)
+		return
+	}
+	name, err := h.moderateName(strings.TrimSpace(body.Name))
+	if err != nil {
+		writeResult(rw, nil, err)
+		return
+	}
+	if name == "" {
+		writeError(rw, "malformed_body", "The team needs a name.", 400)
+		return
+	}
+	t := &Team{
+		ID:        "team-" + randomToken(9),
+		Name:      name,
+		PlayerIDs: []string{},
+		CreatedAt: h.clock.Now(),
+	}
+	seen := map[string]bool{}
+	for _, id := range body.PlayerIDs {
+		if id = strings.TrimSpace(id); id != "" && !seen[id] {
+			seen[id] = true
+			t.PlayerIDs = append(t.PlayerIDs, id)
+		}
+	}
+	if len(t.PlayerIDs) < 2 || len(t.PlayerIDs) > maxTeamSize {
+		writeResult(rw, nil, errBadTeamSize)
+		return
+	}
+	if h.accounts != nil {
+		a, ok := requestAccount(req)
+		if !ok {
+			rw.Header().Set("WWW-Authenticate", `Bearer realm="players"`)
+			writeResult(rw, nil, errTeamSessionRequired)
+			return
+		}
+		if !seen[a.PlayerID] {
+			writeResult(rw, nil, errNotTeamMember)
+			return
+		}
+	}
+	sort.Strings(t.PlayerIDs)
+	resp, err := h.exec(req.Context(), command{Op: opCreateTeam, NewTeam: t})
+	writeCreated(rw, resp, err)
+}
+
+// GET /teams
+func (h *handler) handleListTeams(rw http.ResponseWriter, req *http.Request) {
+	writeJSON(rw, TeamsResponse{Teams: h.history.listTeams()})
+}
+
+// GET /leaderboards/teams?season=...
+// Ranks the teams over a season, by default the current month.
+// Standings are computed from the history as it's requested, so
+// games count toward teams created after they finished.
+func (h *handler) handleTeamLeaderboard(rw http.ResponseWriter, req *http.Request) {
+	season, start, end, err := parseSeason(req.URL.Query().Get("season"), h.clock.Now())
+	if err != nil {
+		writeResult(rw, nil, err)
+		return
+	}
+	resp := Leaderboard{Season: season, Teams: h.history.leaderboard(start, end)}
+	if !start.IsZero() {
+		resp.Start, resp.End = &start, &end
+	}
+	writeJSON(rw, resp)
+}
+
+// DELETE /admin/teams/{id}
+func (h *handler) handleAdminDeleteTeam(rw http.ResponseWriter, req *http.Request) {
+	resp, err := h.exec(req.Context(), command{Op: opDeleteTeam, TargetID: req.PathValue("id")})
+	writeResult(rw, resp, err)
+}
@@ -0,0 +1,184 @@
+package gameapi
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTeamLeaderboard(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	hist, err := OpenGameHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(now)
+	h := newTestHandler(clock)
+	h.history = hist
+	ctx := context.Background()
+
+	var pair, other Team
+	if code := post(t, h, "/teams", map[string]interface{}{"name": "The Pair", "player_ids": []string{"alice", "bob", "alice"}}, &pair); code != 201 {
+		t.Fatalf("POST /teams: status = %d, want 201", code)
+	}
+	if code := post(t, h, "/teams", map[string]interface{}{"name": "Others", "player_ids": []string{"carol", "dave"}}, &other); code != 201 {
+		t.Fatalf("POST /teams: status = %d, want 201", code)
+	}
+	for _, tc := range []struct {
+		body map[string]interface{}
+		want int
+	}{
+		{map[string]interface{}{"name": "the pair", "player_ids": []string{"erin", "frank"}}, 409},
+		{map[string]interface{}{"name": "Solo", "player_ids": []string{"erin", " erin "}}, 400},
+		{map[string]interface{}{"name": " ", "player_ids": []string{"erin", "frank"}}, 400},
+	} {
+		if code := post(t, h, "/teams", tc.body, nil); code != tc.want {
+			t.Errorf("POST /teams %v: status = %d, want %d", tc.body, code, tc.want)
+		}
+	}
+
+	result := func(id string, won bool, tokens int, when time.Time, players ...string) {
+		r := gameResult{GameID: id, Seed: 1, FinishedAt: when, Won: won, TokensUsed: tokens}
+		for i, p := range players {
+			r.Players = append(r.Players, resultPlayer{PlayerID: p, Team: i%2 + 1})
+		}
+		hist.record(r)
+	}
+	result("won", true, 5, now, "alice", "bob")
+	result("lost", false, 3, now, "alice", "bob")
+	result("long", true, 12, now, "carol", "dave")
+	result("mixed", true, 0, now, "alice", "carol") // neither team's
+	result("solo", true, 0, now, "alice")           // with a bot
+	result("september", true, 0, now.AddDate(0, -1, 0), "carol", "dave")
+
+	for _, tc := range []struct {
+		season string
+		want   []TeamStanding
+	}{
+		{"", []TeamStanding{
+			{Rank: 1, TeamID: pair.ID, Name: "The Pair", GamesPlayed: 2, Wins: 1, Losses: 1, TokensRemaining: 4, Score: 14},
+			{Rank: 2, TeamID: other.ID, Name: "Others", GamesPlayed: 1, Wins: 1, Score: 10},
+		}},
+		{"2026-09", []TeamStanding{
+			{Rank: 1, TeamID: other.ID, Name: "Others", GamesPlayed: 1, Wins: 1, TokensRemaining: 9, Score: 19},
+			{Rank: 2, TeamID: pair.ID, Name: "The Pair"},
+		}},
+		{"all", []TeamStanding{
+			{Rank: 1, TeamID: other.ID, Name: "Others", GamesPlayed: 2, Wins: 2, TokensRemaining: 9, Score: 29},
+			{Rank: 2, TeamID: pair.ID, Name: "The Pair", GamesPlayed: 2, Wins: 1, Losses: 1, TokensRemaining: 4, Score: 14},
+		}},
+	} {
+		var lb Leaderboard
+		if code := request(ctx, t, h, "GET", "/leaderboards/teams?season="+tc.season, nil, &lb); code != 200 {
+			t.Fatalf("GET leaderboard for season %q: status = %d, want 200", tc.season, code)
+		}
+		if len(lb.Teams) != len(tc.want) {
+			t.Fatalf("leaderboard for season %q = %+v, want %+v", tc.season, lb.Teams, tc.want)
+		}
+		for i := range tc.want {
+			if lb.Teams[i] != tc.want[i] {
+				t.Errorf("leaderboard for season %q: #%d = %+v, want %+v", tc.season, i, lb.Teams[i], tc.want[i])
+			}
+		}
+	}
+	if code := request(ctx, t, h, "GET", "/leaderboards/teams?season=october", nil, nil); code != 400 {
+		t.Errorf("GET leaderboard for season october: status = %d, want 400", code)
+	}
+
+	// Teams survive restarts, until an admin deletes them.
+	hist.Close()
+	if hist, err = OpenGameHistory(path); err != nil {
+		t.Fatal(err)
+	}
+	defer hist.Close()
+	h.history = hist
+	var teams TeamsResponse
+	if code := request(ctx, t, h, "GET", "/teams", nil, &teams); code != 200 || len(teams.Teams) != 2 || teams.Teams[1].ID != pair.ID {
+		t.Errorf("GET /teams after reopening: status = %d, teams = %+v; want Others and The Pair", code, teams.Teams)
+	}
+	h.adminToken = "secret"
+	if code := request(ctx, t, withToken(h, "secret"), "DELETE", "/admin/teams/"+other.ID, nil, nil); code != 200 {
+		t.Errorf("DELETE /admin/teams/%s: status = %d, want 200", other.ID, code)
+	}
+	if teams := hist.listTeams(); len(teams) != 1 || teams[0].ID != pair.ID {
+		t.Errorf("teams after deleting Others = %+v, want The Pair", teams)
+	}
+}
+
+func TestTeamReplicas(t *testing.T) {
+	broker := NewLocalBroker()
+	a := Handler(map[string][]string{"example": exampleWords}, WithBroker(broker))
+	b := Handler(map[string][]string{"example": exampleWords}, WithBroker(broker))
+
+	var pair Team
+	if code := post(t, a, "/teams", map[string]interface{}{"name": "The Pair", "player_ids": []string{"alice", "bob"}}, &pair); code != 201 {
+		t.Fatalf("POST /teams: status = %d, want 201", code)
+	}
+	waitFor(t, "the team to reach the other instance", func() bool {
+		var teams TeamsResponse
+		request(context.Background(), t, b, "GET", "/teams", nil, &teams)
+		return len(teams.Teams) == 1 && teams.Teams[0].ID == pair.ID
+	})
+	if code := post(t, b, "/teams", map[string]interface{}{"name": "THE PAIR", "player_ids": []string{"carol", "dave"}}, nil); code != 409 {
+		t.Errorf("POST /teams with a name taken on the other instance: status = %d, want 409", code)
+	}
+
+	// An instance that starts later catches up on the teams, even
+	// once the broker has stopped retaining the games of the time.
+	newTestGame(t, a, "old")
+	broker.mu.Lock()
+	for _, r := range broker.retained {
+		r.lastActive = r.lastActive.Add(-2 * brokerRetention)
+	}
+	broker.lastGC = broker.lastGC.Add(-2 * time.Hour)
+	broker.mu.Unlock()
+	newTestGame(t, a, "new")
+	broker.mu.Lock()
+	_, old := broker.retained["old"]
+	broker.mu.Unlock()
+	if old {
+		t.Error("the broker still retains the old game")
+	}
+	c := Handler(map[string][]string{"example": exampleWords}, WithBroker(broker))
+	waitFor(t, "a later instance to catch up on the team", func() bool {
+		teams := c.(*handler).history.listTeams()
+		return len(teams) == 1 && teams[0].ID == pair.ID
+	})
+}
+
+func TestTeamAccounts(t *testing.T) {
+	defer func(c argon2Cost) { passwordCost = c }(passwordCost)
+	passwordCost = argon2Cost{Time: 1, Memory: 8, Threads: 1}
+
+	h := newTestHandler(NewFakeClock(time.Now()))
+	db, err := OpenAccountDB(filepath.Join(t.TempDir(), "accounts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.accounts = db
+	var alice SessionResponse
+	if code := post(t, h, "/accounts", map[string]interface{}{"username": "alice", "password": "correct horse"}, &alice); code != 201 {
+		t.Fatalf("register alice: status = %d, want 201", code)
+	}
+
+	// Only signed-in players may create teams, and only
+	// teams that they're on.
+	for _, tc := range []struct {
+		h    http.Handler
+		ids  []string
+		want int
+	}{
+		{h, []string{alice.Account.PlayerID, "bob"}, 401},
+		{withToken(h, alice.Account.PlayerID), []string{alice.Account.PlayerID, "bob"}, 401},
+		{withToken(h, alice.Token), []string{"bob", "carol"}, 403},
+		{withToken(h, alice.Token), []string{alice.Account.PlayerID, "bob"}, 201},
+	} {
+		if code := post(t, tc.h, "/teams", map[string]interface{}{"name": "Pair " + strings.Join(tc.ids, " "), "player_ids": tc.ids}, nil); code != tc.want {
+			t.Errorf("POST /teams with %v: status = %d, want %d", tc.ids, code, tc.want)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package gameapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// registerV2 registers the routes of version 2 of the API. Unlike the
+// original routes, which are kept for existing clients, they're
+// organized around games and their resources, and each accepts only
+// the methods that it declares. Requests with any other method are
+// answered with 405 Method Not Allowed.
+//
+// Most routes share their handlers with the original routes, taking
+// the game's ID from the path rather than the request's body.
+func (h *handler) registerV2() {
+	h.handle("POST /v2/games", h.handleV2CreateGame)
+	h.handle("GET /v2/games/{id}", h.handleV2GetGame)
+	h.handle("PUT /v2/games/{id}", h.handleV2PutGame)
+	h.handle("GET /v2/games/{id}/events", h.handleV2Events)
+	h.handle("PUT /v2/games/{id}/players/{player_id}", h.handlePing)
+	h.handle("POST /v2/games/{id}/guesses", h.handleGuess)
+	h.handle("POST /v2/games/{id}/end-turn", h.handleEndTurn)
+	h.handle("POST /v2/games/{id}/clues", h.handleClue)
+	h.handle("POST /v2/games/{id}/messages", h.handleChat)
+	h.handle("POST /v2/games/{id}/mutes", h.handleMute)
+	h.handle("POST /v2/games/{id}/kicks", h.handleKick)
+	h.handle("PUT /v2/games/{id}/host", h.handleTransferHost)
+	h.handle("PATCH /v2/games/{id}/settings", h.handleSettings)
+	h.handle("POST /v2/games/{id}/invites", h.handleInvite)
+	h.handle("POST /v2/games/{id}/clue-suggestions", h.handleSuggestClue)
+	h.handle("POST /v2/games/{id}/bots", h.handleAddBot)
+	h.handle("GET /v2/games/{id}/practice", h.handlePracticeResults)
+	h.handle("GET /v2/stats", h.handleStats)
+}
+
+// POST /v2/games
+// Creates a game. If the request doesn't include an ID, one is
+// generated. The game's URL is returned in the Location header.
+func (h *handler) handleV2CreateGame(rw http.ResponseWriter, req *http.Request) {
+	var body NewGameRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(rw, "malformed_body", "Unable to parse request body.", 400)
+		return
+	}
+	if body.GameID == "" {
+		body.GameID = h.newGameID()
+	} else if _, ok := h.games.get(body.GameID); ok {
+		writeError(rw, "game_exists", "A game with that ID already exists.", 409)
+		return
+	}
+	resp, err := h.createGame(req.Context(), body)
+	if err == nil {
+		rw.Header().Set("Location", "/v2/games/"+url.PathEscape(body.GameID))
+	}
+	writeCreated(rw, resp, err)
+}
+
+// GET /v2/games/{id}?player_id=...
+// The game includes the messages to the player's side, if the
+// request says who the player is and has their key or session.
+func (h *handler) handleV2GetGame(rw http.ResponseWriter, req *http.Request) {
+	g, ok := h.games.get(req.PathValue("id"))
+	if !ok {
+		writeError(rw, "not_found", "Game not found", 404)
+		return
+	}
+	playerID := req.URL.Query().Get("player_id")
+	key, session := playerKey(req.Context(), playerID)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	writeJSON(rw, g.view(g.sideOf(playerID, key, session)))
+}
+
+// PUT /v2/games/{id}
+// Creates the game with the ID, or replaces it with a new game. To
+// replace a game, the request must include the game's current seed
+// as prev_seed and, if the game has a host, the host's player_id.
+func (h *handler) handleV2PutGame(rw http.ResponseWriter, req *http.Request) {
+	var body NewGameRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(rw, "malformed_body", "Unable to parse request body.", 400)
+		return
+	}
+	body.GameID = req.PathValue("id")
+
+	if oldGame, ok := h.games.get(body.GameID); ok {
+		oldGame.mu.Lock()
+		seed := oldGame.Seed
+		key, session := playerKey(req.Context(), body.PlayerID)
+		err := oldGame.checkReplace(&body, key, session)
+		oldGame.mu.Unlock()
+		if body.PrevSeed == nil || *body.PrevSeed != seed {
+			writeError(rw, "seed_mismatch", "The game has a different seed than prev_seed.", 409)
+			return
+		}
+		if err != nil {
+			writeResult(rw, nil, err)
+			return
+		}
+	}
+	resp, err := h.createGame(req.Context(), body)
+	writeCreated(rw, resp, err)
+}
+
+// GET /v2/games/{id}/events?seed=&player_id=&name=&team=&last_event=
+// Long polls for the game's events, like POST /events.
+func (h *handler) handleV2Events(rw http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	body := EventsRequest{PlayerRequest: PlayerRequest{
+		GameID:   req.PathValue("id"),
+		PlayerID: q.Get("player_id"),
+		Name:     q.Get("name"),
+	}}
+	seed, err := strconv.ParseInt(q.Get("seed"), 10, 64)
+	body.Seed = Seed(seed)
+	if err == nil && q.Has("team") {
+		body.Team, err = strconv.Atoi(q.Get("team"))
+	}
+	if err == nil && q.Has("last_event") {
+		body.LastEvent, err = strconv.Atoi(q.Get("last_event"))
+	}
+	if err != nil || body.PlayerID == "" {
+		writeError(rw, "malformed_query", "Unable to parse query parameters.", 400)
+		return
+	}
+	h.pollEvents(rw, req, body)
+}
+
+// writeCreated writes the response to a request that created
+// a resource.
+func writeCreated(rw http.ResponseWriter, resp interface{}, err error) {
+	if err == nil {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(http.StatusCreated)
+	}
+	writeResult(rw, resp, err)
+}
+
+// methodNotAllowed responds with 405 Method Not Allowed if the
+// request's path matches a route, but not with its method.
+func (h *handler) methodNotAllowed(rw http.ResponseWriter, req *http.Request) bool {
+	if _, pattern := h.mux.Handler(req); pattern != "" {
+		return false
+	}
+	methods := h.allowedMethods(req)
+	if len(methods) == 0 {
+		return false
+	}
+	rw.Header().Set("Allow", strings.Join(append(methods, "OPTIONS"), ", "))
+	writeError(rw, "method_not_allowed", req.Method+" isn't supported here.", 405)
+	return true
+}
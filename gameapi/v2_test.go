@@ -0,0 +1,114 @@
+package gameapi
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestV2(t *testing.T) {
+	h := newTestHandler(NewFakeClock(time.Now()))
+	ctx := context.Background()
+
+	type gameResp struct {
+		State GameState `json:"state"`
+		Words []string  `json:"words"`
+	}
+	var created gameResp
+	if code := request(ctx, t, h, "PUT", "/v2/games/rest", map[string]interface{}{}, &created); code != 201 {
+		t.Fatalf("PUT /v2/games/rest: status = %d, want 201", code)
+	}
+	if code := request(ctx, t, h, "PUT", "/v2/games/rest", map[string]interface{}{}, nil); code != 409 {
+		t.Errorf("PUT without prev_seed: status = %d, want 409", code)
+	}
+	if code := request(ctx, t, h, "POST", "/v2/games", map[string]interface{}{"game_id": "rest"}, nil); code != 409 {
+		t.Errorf("POST of an existing ID: status = %d, want 409", code)
+	}
+	var generated gameResp
+	if code := request(ctx, t, h, "POST", "/v2/games", map[string]interface{}{}, &generated); code != 201 || len(generated.Words) != 25 {
+		t.Errorf("POST /v2/games: status = %d, %d words", code, len(generated.Words))
+	}
+
+	seed := created.State.Seed
+	player := map[string]interface{}{"seed": seed, "player_id": "alice", "name": "Alice", "team": 1}
+	with := func(k string, v interface{}) map[string]interface{} {
+		m := map[string]interface{}{k: v}
+		for k, v := range player {
+			m[k] = v
+		}
+		return m
+	}
+	for _, r := range []struct {
+		method, path string
+		body         interface{}
+	}{
+		{"PUT", "/v2/games/rest/players/alice", map[string]interface{}{"seed": seed, "name": "Alice", "team": 1}},
+		{"POST", "/v2/games/rest/guesses", with("index", 3)},
+		{"POST", "/v2/games/rest/messages", with("message", "hi")},
+		{"POST", "/v2/games/rest/end-turn", player},
+	} {
+		if code := request(ctx, t, keyed(h, "alice"), r.method, r.path, r.body, nil); code != 200 {
+			t.Errorf("%s %s: status = %d, want 200", r.method, r.path, code)
+		}
+	}
+
+	var update GameUpdate
+	path := fmt.Sprintf("/v2/games/rest/events?seed=%d&player_id=alice&name=Alice&team=1&last_event=1", seed)
+	if code := request(ctx, t, h, "GET", path, nil, &update); code != 200 {
+		t.Fatalf("GET events: status = %d, want 200", code)
+	}
+	var types []string
+	for _, e := range update.Events {
+		types = append(types, e.Type)
+	}
+	if fmt.Sprint(types) != "[guess chat end_turn]" {
+		t.Errorf("events = %v, want [guess chat end_turn]", types)
+	}
+	if code := request(ctx, t, h, "GET", "/v2/games/rest/events?seed=x&player_id=alice", nil, nil); code != 400 {
+		t.Errorf("GET events with a bad seed: status = %d, want 400", code)
+	}
+
+	var game gameResp
+	if code := request(ctx, t, h, "GET", "/v2/games/rest", nil, &game); code != 200 || len(game.State.Events) != 4 {
+		t.Errorf("GET /v2/games/rest: status = %d, %d events", code, len(game.State.Events))
+	}
+	if code := request(ctx, t, h, "GET", "/v2/games/missing", nil, nil); code != 404 {
+		t.Errorf("GET a missing game: status = %d, want 404", code)
+	}
+	if code := request(ctx, t, h, "PUT", "/v2/games/rest", map[string]interface{}{"prev_seed": seed, "player_id": "bob"}, nil); code != 403 {
+		t.Errorf("PUT by a player other than the host: status = %d, want 403", code)
+	}
+	if code := request(ctx, t, h, "PUT", "/v2/games/rest", map[string]interface{}{"prev_seed": seed, "player_id": "alice"}, nil); code != 403 {
+		t.Errorf("PUT as the host without their key: status = %d, want 403", code)
+	}
+	if code := request(ctx, t, keyed(h, "alice"), "PUT", "/v2/games/rest", map[string]interface{}{"prev_seed": seed, "player_id": "alice"}, &game); code != 201 || game.State.Seed == seed {
+		t.Errorf("PUT with prev_seed: status = %d, seed %d", code, game.State.Seed)
+	}
+}
+
+func TestV2MethodNotAllowed(t *testing.T) {
+	h := newTestHandler(NewFakeClock(time.Now()))
+	for _, tc := range []struct {
+		method, path, allow string
+	}{
+		{"DELETE", "/v2/games/x", "GET, HEAD, PUT, OPTIONS"},
+		{"GET", "/v2/games/x/guesses", "POST, OPTIONS"},
+		{"PUT", "/v2/games", "POST, OPTIONS"},
+		{"POST", "/v2/stats", "GET, HEAD, OPTIONS"},
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, nil))
+		if rec.Code != 405 || rec.Header().Get("Allow") != tc.allow {
+			t.Errorf("%s %s: status = %d, Allow = %q, want 405 and %q", tc.method, tc.path, rec.Code, rec.Header().Get("Allow"), tc.allow)
+		}
+	}
+
+	// The original routes accept any method.
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/index", nil))
+	if rec.Code != 200 {
+		t.Errorf("GET /index: status = %d, want 200", rec.Code)
+	}
+}
@@ -3,14 +3,23 @@ module Api exposing
     , Event
     , GameState
     , Index
+    , Lobby
+    , LobbyGame
+    , Match
+    , Session
     , Update
     , chat
     , endTurn
+    , findMatch
     , index
     , init
+    , lobby
+    , lockTeams
+    , logout
     , longPollEvents
     , maybeMakeGame
     , ping
+    , signIn
     , submitGuess
     )
 
@@ -23,8 +32,8 @@ import Side exposing (Side)
 import Url
 
 
-init : Url.Url -> Client
-init url =
+init : Url.Url -> String -> Maybe String -> Client
+init url key session =
     let
         baseUrl =
             case url.host of
@@ -38,14 +47,79 @@ init url =
                 _ ->
                     { url | host = "api." ++ url.host, path = "", query = Nothing, fragment = Nothing }
     in
-    { baseUrl = baseUrl }
+    { baseUrl = baseUrl
+    , access = url.query |> Maybe.andThen (queryParam "invite")
+    , key = key
+    , session = session
+    }
 
 
+{-| The access field holds an invite to a private game, from the
+invite link the player followed, the key field holds the player's
+key, and the session field holds the session of the account the
+player has signed in to. They're sent with every request.
+-}
 type alias Client =
     { baseUrl : Url.Url
+    , access : Maybe String
+    , key : String
+    , session : Maybe String
     }
 
 
+queryParam : String -> String -> Maybe String
+queryParam key query =
+    query
+        |> String.split "&"
+        |> List.filterMap
+            (\pair ->
+                case String.split "=" pair of
+                    [ k, v ] ->
+                        if k == key then
+                            Url.percentDecode v
+
+                        else
+                            Nothing
+
+                    _ ->
+                        Nothing
+            )
+        |> List.head
+
+
+headers : Client -> List Http.Header
+headers client =
+    List.filterMap identity
+        [ Maybe.map (Http.header "X-Room-Access") client.access
+        , Maybe.map (\token -> Http.header "Authorization" ("Bearer " ++ token)) client.session
+        , if client.key == "" then
+            Nothing
+
+          else
+            Just (Http.header "X-Player-Key" client.key)
+        ]
+
+
+post :
+    Client
+    ->
+        { url : String
+        , body : Http.Body
+        , expect : Http.Expect msg
+        }
+    -> Cmd msg
+post client r =
+    Http.request
+        { method = "POST"
+        , headers = headers client
+        , url = r.url
+        , body = r.body
+        , expect = r.expect
+        , timeout = Nothing
+        , tracker = Nothing
+        }
+
+
 type alias GameState =
     { id : String
     , seed : String
@@ -53,6 +127,8 @@ type alias GameState =
     , events : List Event
     , oneLayout : List Color
     , twoLayout : List Color
+    , host : String
+    , teamsLocked : Bool
     }
 
 
@@ -64,6 +140,7 @@ type alias Event =
     , side : Maybe Side
     , index : Int
     , message : String
+    , scope : String
     }
 
 
@@ -78,6 +155,45 @@ type alias Index =
     }
 
 
+type alias Lobby =
+    { version : String
+    , games : List LobbyGame
+    }
+
+
+{-| A Match is the response to matchmaking requests. Until the
+player has a partner, its status is "waiting". Matched games are
+private, and the invite lets the player in.
+-}
+type alias Match =
+    { status : String
+    , gameId : String
+    , side : Maybe Side
+    , partner : String
+    , invite : String
+    }
+
+
+{-| A Session is an account that the player has signed in to.
+-}
+type alias Session =
+    { token : String
+    , playerId : String
+    , displayName : String
+    }
+
+
+type alias LobbyGame =
+    { id : String
+    , name : String
+    , mode : String
+    , wordList : String
+    , playersA : Int
+    , playersB : Int
+    , lookingForPlayers : Bool
+    }
+
+
 endpointUrl : Url.Url -> String -> String
 endpointUrl baseUrl path =
     { baseUrl | path = path }
@@ -86,7 +202,7 @@ endpointUrl baseUrl path =
 
 index : Client -> (Result Http.Error Index -> msg) -> Cmd msg
 index client toMsg =
-    Http.post
+    post client
         { url = endpointUrl client.baseUrl "/index"
         , body = Http.jsonBody (E.object [])
         , expect = Http.expectJson toMsg decodeIndex
@@ -104,7 +220,7 @@ submitGuess :
     }
     -> Cmd msg
 submitGuess r =
-    Http.post
+    post r.client
         { url = endpointUrl r.client.baseUrl "/guess"
         , body =
             Http.jsonBody
@@ -131,7 +247,7 @@ ping :
     }
     -> Cmd msg
 ping r =
-    Http.post
+    post r.client
         { url = endpointUrl r.client.baseUrl "/ping"
         , body =
             Http.jsonBody
@@ -156,7 +272,7 @@ endTurn :
     }
     -> Cmd msg
 endTurn r =
-    Http.post
+    post r.client
         { url = endpointUrl r.client.baseUrl "/end-turn"
         , body =
             Http.jsonBody
@@ -178,11 +294,12 @@ chat :
     , player : Player
     , toMsg : Result Http.Error () -> msg
     , message : String
+    , scope : String
     , client : Client
     }
     -> Cmd msg
 chat r =
-    Http.post
+    post r.client
         { url = endpointUrl r.client.baseUrl "/chat"
         , body =
             Http.jsonBody
@@ -193,6 +310,7 @@ chat r =
                     , ( "name", E.string r.player.user.name )
                     , ( "team", Side.encodeMaybe r.player.side )
                     , ( "message", E.string r.message )
+                    , ( "scope", E.string r.scope )
                     ]
                 )
         , expect = Http.expectWhatever r.toMsg
@@ -212,7 +330,7 @@ longPollEvents :
 longPollEvents r =
     Http.request
         { method = "POST"
-        , headers = []
+        , headers = headers r.client
         , url = endpointUrl r.client.baseUrl "/events"
         , body =
             Http.jsonBody
@@ -233,18 +351,22 @@ longPollEvents r =
 
 maybeMakeGame :
     { gameId : String
+    , playerId : String
+    , public : Bool
     , prevSeed : Maybe String
     , toMsg : Result Http.Error GameState -> msg
     , client : Client
     }
     -> Cmd msg
 maybeMakeGame r =
-    Http.post
+    post r.client
         { url = endpointUrl r.client.baseUrl "/new-game"
         , body =
             Http.jsonBody
                 (E.object
                     [ ( "game_id", E.string r.gameId )
+                    , ( "player_id", E.string r.playerId )
+                    , ( "public", E.bool r.public )
                     , ( "prev_seed"
                       , case r.prevSeed of
                             Nothing ->
@@ -259,6 +381,128 @@ maybeMakeGame r =
         }
 
 
+lockTeams :
+    { gameId : String
+    , seed : String
+    , player : Player
+    , locked : Bool
+    , toMsg : Result Http.Error () -> msg
+    , client : Client
+    }
+    -> Cmd msg
+lockTeams r =
+    Http.request
+        { method = "PATCH"
+        , headers = headers r.client
+        , url = endpointUrl r.client.baseUrl ("/v2/games/" ++ Url.percentEncode r.gameId ++ "/settings")
+        , body =
+            Http.jsonBody
+                (E.object
+                    [ ( "seed", E.string r.seed )
+                    , ( "player_id", E.string r.player.user.id )
+                    , ( "name", E.string r.player.user.name )
+                    , ( "team", Side.encodeMaybe r.player.side )
+                    , ( "teams_locked", E.bool r.locked )
+                    ]
+                )
+        , expect = Http.expectWhatever r.toMsg
+        , timeout = Nothing
+        , tracker = Nothing
+        }
+
+
+{-| lobby waits for the list of public games to change from the
+version the client has, so an empty version returns it right away.
+-}
+lobby :
+    { version : String
+    , tracker : String
+    , toMsg : Result Http.Error Lobby -> msg
+    , client : Client
+    }
+    -> Cmd msg
+lobby r =
+    Http.request
+        { method = "GET"
+        , headers = []
+        , url =
+            endpointUrl r.client.baseUrl "/lobby"
+                ++ "?version="
+                ++ Url.percentEncode r.version
+        , body = Http.emptyBody
+        , expect = Http.expectJson r.toMsg decodeLobby
+        , timeout = Just 45000
+        , tracker = Just r.tracker
+        }
+
+
+findMatch :
+    { playerId : String
+    , name : String
+    , tracker : String
+    , toMsg : Result Http.Error Match -> msg
+    , client : Client
+    }
+    -> Cmd msg
+findMatch r =
+    Http.request
+        { method = "POST"
+        , headers = headers r.client
+        , url = endpointUrl r.client.baseUrl "/matchmaking/join"
+        , body =
+            Http.jsonBody
+                (E.object
+                    [ ( "player_id", E.string r.playerId )
+                    , ( "name", E.string r.name )
+                    ]
+                )
+        , expect = Http.expectJson r.toMsg decodeMatch
+        , timeout = Just 45000
+        , tracker = Just r.tracker
+        }
+
+
+{-| signIn signs in to an account or, if register is set,
+creates the account first.
+-}
+signIn :
+    { register : Bool
+    , username : String
+    , password : String
+    , toMsg : Result Http.Error Session -> msg
+    , client : Client
+    }
+    -> Cmd msg
+signIn r =
+    post r.client
+        { url =
+            endpointUrl r.client.baseUrl
+                (if r.register then
+                    "/accounts"
+
+                 else
+                    "/login"
+                )
+        , body =
+            Http.jsonBody
+                (E.object
+                    [ ( "username", E.string r.username )
+                    , ( "password", E.string r.password )
+                    ]
+                )
+        , expect = Http.expectJson r.toMsg decodeSession
+        }
+
+
+logout : Client -> (Result Http.Error () -> msg) -> Cmd msg
+logout client toMsg =
+    post client
+        { url = endpointUrl client.baseUrl "/logout"
+        , body = Http.emptyBody
+        , expect = Http.expectWhatever toMsg
+        }
+
+
 decodeIndex : D.Decoder Index
 decodeIndex =
     D.map Index (D.field "autogenerated_id" D.string)
@@ -266,13 +510,52 @@ decodeIndex =
 
 decoderGameState : String -> D.Decoder GameState
 decoderGameState id =
-    D.map6 GameState
+    D.map8 GameState
         (D.succeed id)
         (D.field "state" (D.field "seed" D.string))
         (D.field "words" (D.list D.string))
         (D.field "state" (D.field "events" (D.list decodeEvent)))
         (D.field "one_layout" (D.list Color.decode))
         (D.field "two_layout" (D.list Color.decode))
+        (D.oneOf [ D.field "state" (D.field "host" D.string), D.succeed "" ])
+        (D.oneOf [ D.field "state" (D.at [ "settings", "teams_locked" ] D.bool), D.succeed False ])
+
+
+decodeSession : D.Decoder Session
+decodeSession =
+    D.map3 Session
+        (D.field "token" D.string)
+        (D.at [ "account", "player_id" ] D.string)
+        (D.at [ "account", "display_name" ] D.string)
+
+
+decodeMatch : D.Decoder Match
+decodeMatch =
+    D.map5 Match
+        (D.field "status" D.string)
+        (D.oneOf [ D.field "game_id" D.string, D.succeed "" ])
+        (D.oneOf [ D.field "team" Side.decodeMaybe, D.succeed Nothing ])
+        (D.oneOf [ D.field "partner" D.string, D.succeed "" ])
+        (D.oneOf [ D.field "invite" D.string, D.succeed "" ])
+
+
+decodeLobby : D.Decoder Lobby
+decodeLobby =
+    D.map2 Lobby
+        (D.field "version" D.string)
+        (D.field "games" (D.list decodeLobbyGame))
+
+
+decodeLobbyGame : D.Decoder LobbyGame
+decodeLobbyGame =
+    D.map7 LobbyGame
+        (D.field "id" D.string)
+        (D.field "name" D.string)
+        (D.field "mode" D.string)
+        (D.field "word_list" D.string)
+        (D.field "players_a" D.int)
+        (D.field "players_b" D.int)
+        (D.field "looking_for_players" D.bool)
 
 
 decodeUpdate : D.Decoder Update
@@ -284,7 +567,7 @@ decodeUpdate =
 
 decodeEvent : D.Decoder Event
 decodeEvent =
-    D.map7 Event
+    D.map8 Event
         (D.field "number" D.int)
         (D.field "type" D.string)
         (D.field "player_id" D.string)
@@ -292,3 +575,4 @@ decodeEvent =
         (D.field "team" Side.decodeMaybe)
         (D.field "index" D.int)
         (D.field "message" D.string)
+        (D.oneOf [ D.field "scope" D.string, D.succeed "all" ])
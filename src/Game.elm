@@ -28,6 +28,8 @@ init state user client toMsg =
                 { id = state.id
                 , seed = state.seed
                 , players = Dict.empty
+                , host = state.host
+                , teamsLocked = state.teamsLocked
                 , events = []
                 , cells =
                     List.map3 (\w l1 l2 -> ( w, ( False, l1 ), ( False, l2 ) ))
@@ -62,6 +64,8 @@ type alias Model =
     { id : String
     , seed : String
     , players : Dict.Dict String Side
+    , host : String
+    , teamsLocked : Bool
     , events : List Api.Event
     , cells : Array Cell
     , player : Player
@@ -263,6 +267,22 @@ applyEvent e model =
             "player_left" ->
                 { model | players = Dict.update e.playerId (\_ -> Nothing) model.players, events = e :: model.events }
 
+            "player_kicked" ->
+                { model | players = Dict.update e.playerId (\_ -> Nothing) model.players, events = e :: model.events }
+
+            "host_changed" ->
+                { model | host = e.playerId, events = e :: model.events }
+
+            "teams_locked" ->
+                { model | teamsLocked = True, events = e :: model.events }
+
+            "teams_unlocked" ->
+                { model | teamsLocked = False, events = e :: model.events }
+
+            "chat_removed" ->
+                -- A moderator removed the chat message numbered e.index.
+                { model | events = e :: List.filter (\x -> x.typ /= "chat" || x.number /= e.index) model.events }
+
             "guess" ->
                 case ( Array.get e.index model.cells, e.side ) of
                     ( Just cell, Just side ) ->
@@ -493,11 +513,14 @@ viewEvent model e =
         "chat" ->
             let
                 sideEl =
-                    case e.side of
-                        Just s ->
+                    case ( e.side, e.scope ) of
+                        ( Just s, "side" ) ->
+                            span [ Attr.class "side" ] [ text (" (" ++ Side.toString s ++ ", to side)") ]
+
+                        ( Just s, _ ) ->
                             span [ Attr.class "side" ] [ text (" (" ++ Side.toString s ++ ")") ]
 
-                        Nothing ->
+                        ( Nothing, _ ) ->
                             text ""
             in
             div [] [ text e.name, sideEl, text ": ", text e.message ]
@@ -510,6 +533,47 @@ viewEvent model e =
                 Just side ->
                     div [] [ text "Side ", text (Side.toString side), text " took a timer token ending the turn." ]
 
+        "player_kicked" ->
+            div [ Attr.class "system-message" ] [ text e.name, text " was removed from the game." ]
+
+        "player_muted" ->
+            div [ Attr.class "system-message" ] [ text e.name, text " was muted by the host." ]
+
+        "game_ended" ->
+            div [ Attr.class "system-message" ] [ text "The game was ended by a moderator." ]
+
+        "host_changed" ->
+            div [ Attr.class "system-message" ] [ text e.name, text " is now the host." ]
+
+        "teams_locked" ->
+            div [ Attr.class "system-message" ] [ text e.name, text " locked the teams." ]
+
+        "teams_unlocked" ->
+            div [ Attr.class "system-message" ] [ text e.name, text " unlocked the teams." ]
+
+        "word_list_changed" ->
+            if e.message == "" then
+                div [ Attr.class "system-message" ] [ text e.name, text " chose to play with all of the words." ]
+
+            else
+                div [ Attr.class "system-message" ] [ text e.name, text " chose the ", text e.message, text " word list." ]
+
+        "passcode_changed" ->
+            div [ Attr.class "system-message" ] [ text e.name, text " set a new passcode. Earlier invites no longer work." ]
+
+        "passcode_removed" ->
+            div [ Attr.class "system-message" ] [ text e.name, text " made the game public." ]
+
+        "clue" ->
+            div []
+                [ text e.name
+                , text " gave the clue "
+                , span [ Attr.class "clue" ] [ text e.message ]
+                , text " for "
+                , text (String.fromInt e.index)
+                , text "."
+                ]
+
         _ ->
             text ""
 
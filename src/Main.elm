@@ -5,9 +5,9 @@ import Browser
 import Browser.Navigation as Nav
 import Dict
 import Game
-import Html exposing (Html, a, button, div, form, h1, h2, h3, i, input, label, p, span, strong, text)
+import Html exposing (Html, a, button, div, form, h1, h2, h3, i, input, label, li, p, span, strong, text, ul)
 import Html.Attributes as Attr
-import Html.Events exposing (onBlur, onClick, onInput, onSubmit)
+import Html.Events exposing (onBlur, onCheck, onClick, onInput, onSubmit)
 import Html.Lazy exposing (lazy, lazy2, lazy3)
 import Http
 import Json.Decode
@@ -29,13 +29,27 @@ type alias Model =
     , user : User.User
     , page : Page
     , apiClient : Api.Client
+    , public : Bool
+    , assignedSide : Maybe Side.Side
+    , signInForm : SignInForm
+    }
+
+
+{-| A SignInForm holds what the player has typed to sign in
+to an account, and why their last attempt failed, if it did.
+-}
+type alias SignInForm =
+    { username : String
+    , password : String
+    , error : String
     }
 
 
 type Page
     = NotFound
     | Error String
-    | Home String
+    | Home String Api.Lobby
+    | Matchmaking
     | GameLoading String
     | GameInProgress Game.Model String GameView
 
@@ -54,9 +68,12 @@ init encodedUser url key =
     case User.decode encodedUser of
         Err e ->
             ( { key = key
-              , user = User.User "" ""
+              , user = User.User "" "" "" Nothing
               , page = Error (Json.Decode.errorToString e)
-              , apiClient = Api.init url
+              , apiClient = Api.init url "" Nothing
+              , public = False
+              , assignedSide = Nothing
+              , signInForm = emptySignInForm
               }
             , Cmd.none
             )
@@ -65,11 +82,24 @@ init encodedUser url key =
             stepUrl url
                 { key = key
                 , user = user
-                , page = Home ""
-                , apiClient = Api.init url
+                , page = Home "" emptyLobby
+                , apiClient = Api.init url user.key user.session
+                , public = False
+                , assignedSide = Nothing
+                , signInForm = emptySignInForm
                 }
 
 
+emptyLobby : Api.Lobby
+emptyLobby =
+    { version = "", games = [] }
+
+
+emptySignInForm : SignInForm
+emptySignInForm =
+    { username = "", password = "", error = "" }
+
+
 
 ---- UPDATE ----
 
@@ -80,13 +110,24 @@ type Msg
     | UrlChanged Url.Url
     | IndexData (Result Http.Error Api.Index)
     | IdChanged String
+    | PublicChanged Bool
+    | GotLobby (Result Http.Error Api.Lobby)
     | SubmitNewGame
+    | FindMatch
+    | CancelMatch
+    | GotMatch (Result Http.Error Api.Match)
+    | SignInFormChanged SignInForm
+    | SignIn Bool
+    | GotSession (Result Http.Error Api.Session)
+    | SignOut
+    | SignedOut
     | NextGame
+    | LockTeams Bool
     | PickSide Side.Side
     | GameUpdate Game.Msg
     | GotGame (Result Http.Error Api.GameState)
     | ChatMessageChanged String
-    | SendChat
+    | SendChat String
     | ToggleSettings
     | SettingsEdit (Settings -> Settings)
     | SaveSettings Settings
@@ -110,24 +151,111 @@ update msg model =
         ( UrlChanged url, _ ) ->
             stepUrl url model
 
-        ( IndexData (Ok data), Home id ) ->
+        ( IndexData (Ok data), Home id lobby ) ->
             ( if id == "" then
-                { model | page = Home data.autogeneratedId }
+                { model | page = Home data.autogeneratedId lobby }
 
               else
                 model
             , Cmd.none
             )
 
-        ( IdChanged id, Home _ ) ->
-            ( { model | page = Home id }, Cmd.none )
+        ( IdChanged id, Home _ lobby ) ->
+            ( { model | page = Home id lobby }, Cmd.none )
+
+        ( PublicChanged public, Home _ _ ) ->
+            ( { model | public = public }, Cmd.none )
+
+        ( GotLobby (Ok lobby), Home id _ ) ->
+            ( { model | page = Home id lobby }, pollLobby model lobby.version )
 
-        ( SubmitNewGame, Home id ) ->
+        ( SubmitNewGame, Home id _ ) ->
             ( model, Nav.pushUrl model.key (UrlBuilder.relative [ id ] []) )
 
+        ( FindMatch, Home _ _ ) ->
+            ( { model | page = Matchmaking }
+            , Cmd.batch [ Http.cancel lobbyTracker, findMatch model ]
+            )
+
+        ( CancelMatch, Matchmaking ) ->
+            ( { model | page = Home "" emptyLobby }
+            , Cmd.batch [ Http.cancel matchTracker, Api.index model.apiClient IndexData, pollLobby model "" ]
+            )
+
+        ( GotMatch (Ok match), Matchmaking ) ->
+            if match.status == "waiting" then
+                ( model, findMatch model )
+
+            else
+                let
+                    client =
+                        model.apiClient
+                in
+                ( { model | assignedSide = match.side, apiClient = { client | access = Just match.invite } }
+                , Nav.pushUrl model.key (UrlBuilder.absolute [ match.gameId ] [ UrlBuilder.string "invite" match.invite ])
+                )
+
+        ( GotMatch (Err _), Matchmaking ) ->
+            ( { model | page = Error "Unable to find another player. Try again later." }, Cmd.none )
+
+        ( SignInFormChanged signInForm, Home _ _ ) ->
+            ( { model | signInForm = signInForm }, Cmd.none )
+
+        ( SignIn register, Home _ _ ) ->
+            ( model
+            , Api.signIn
+                { register = register
+                , username = model.signInForm.username
+                , password = model.signInForm.password
+                , toMsg = GotSession
+                , client = model.apiClient
+                }
+            )
+
+        ( GotSession (Ok session), _ ) ->
+            let
+                user =
+                    { id = session.playerId, name = session.displayName, key = model.user.key, session = Just session.token }
+
+                client =
+                    model.apiClient
+            in
+            ( { model | user = user, apiClient = { client | session = user.session }, signInForm = emptySignInForm }
+            , User.store user
+            )
+
+        ( GotSession (Err e), _ ) ->
+            let
+                signInForm =
+                    model.signInForm
+            in
+            ( { model | signInForm = { signInForm | error = signInError e } }, Cmd.none )
+
+        ( SignOut, _ ) ->
+            ( model, Api.logout model.apiClient (always SignedOut) )
+
+        ( SignedOut, _ ) ->
+            -- Forget the account's player, so that the page
+            -- generates a new guest when it's reloaded.
+            ( model
+            , Cmd.batch [ User.store (User.User "" "" "" Nothing), Nav.reload ]
+            )
+
         ( NextGame, GameInProgress game _ _ ) ->
             stepGameView model game.id (Just game.seed)
 
+        ( LockTeams locked, GameInProgress game _ _ ) ->
+            ( model
+            , Api.lockTeams
+                { gameId = game.id
+                , seed = game.seed
+                , player = game.player
+                , locked = locked
+                , toMsg = always NoOp
+                , client = model.apiClient
+                }
+            )
+
         ( GameUpdate gameMsg, GameInProgress game chat gameView ) ->
             case Game.update gameMsg game GameUpdate of
                 Just ( newGame, gameCmd ) ->
@@ -147,8 +275,18 @@ update msg model =
             let
                 ( gameModel, gameCmd ) =
                     Game.init state model.user model.apiClient GameUpdate
+
+                loaded =
+                    { model | page = GameInProgress gameModel "" ShowDefault, assignedSide = Nothing }
             in
-            ( { model | page = GameInProgress gameModel "" ShowDefault }, gameCmd )
+            case model.assignedSide of
+                -- Players paired by matchmaking are told which side to join.
+                Just side ->
+                    update (PickSide side) loaded
+                        |> Tuple.mapSecond (\cmd -> Cmd.batch [ gameCmd, cmd ])
+
+                Nothing ->
+                    ( loaded, gameCmd )
 
         ( PickSide side, GameInProgress oldGame chat gameView ) ->
             let
@@ -174,7 +312,7 @@ update msg model =
         ( ChatMessageChanged message, GameInProgress g _ gameView ) ->
             ( { model | page = GameInProgress g message gameView }, Cmd.none )
 
-        ( SendChat, GameInProgress g message gameView ) ->
+        ( SendChat scope, GameInProgress g message gameView ) ->
             ( { model | page = GameInProgress g "" gameView }
             , Api.chat
                 { gameId = g.id
@@ -182,6 +320,7 @@ update msg model =
                 , player = g.player
                 , toMsg = always NoOp
                 , message = message
+                , scope = scope
                 , client = model.apiClient
                 }
             )
@@ -205,6 +344,25 @@ update msg model =
             ( model, Cmd.none )
 
 
+signInError : Http.Error -> String
+signInError e =
+    case e of
+        Http.BadStatus 401 ->
+            "The username or password is wrong."
+
+        Http.BadStatus 409 ->
+            "That username is taken."
+
+        Http.BadStatus 400 ->
+            "Usernames are 3 to 32 letters, digits, dashes or underscores, and passwords are at least 8 characters."
+
+        Http.BadStatus 429 ->
+            "Too many attempts. Try again later."
+
+        _ ->
+            "Unable to sign in. Try again later."
+
+
 stepUrl : Url.Url -> Model -> ( Model, Cmd Msg )
 stepUrl url model =
     case Maybe.withDefault NullRoute (Parser.parse route url) of
@@ -212,7 +370,9 @@ stepUrl url model =
             ( { model | page = NotFound }, Cmd.none )
 
         Index ->
-            ( { model | page = Home "" }, Api.index model.apiClient IndexData )
+            ( { model | page = Home "" emptyLobby }
+            , Cmd.batch [ Api.index model.apiClient IndexData, pollLobby model "" ]
+            )
 
         GameView id ->
             stepGameView model id Nothing
@@ -221,13 +381,49 @@ stepUrl url model =
 stepGameView : Model -> String -> Maybe String -> ( Model, Cmd Msg )
 stepGameView model id prevSeed =
     ( { model | page = GameLoading id }
-    , Api.maybeMakeGame
-        { gameId = id
-        , prevSeed = prevSeed
-        , toMsg = GotGame
+    , Cmd.batch
+        [ Http.cancel lobbyTracker
+        , Api.maybeMakeGame
+            { gameId = id
+            , playerId = model.user.id
+            , public = model.public
+            , prevSeed = prevSeed
+            , toMsg = GotGame
+            , client = model.apiClient
+            }
+        ]
+    )
+
+
+lobbyTracker : String
+lobbyTracker =
+    "lobby"
+
+
+matchTracker : String
+matchTracker =
+    "match"
+
+
+findMatch : Model -> Cmd Msg
+findMatch model =
+    Api.findMatch
+        { playerId = model.user.id
+        , name = model.user.name
+        , tracker = matchTracker
+        , toMsg = GotMatch
+        , client = model.apiClient
+        }
+
+
+pollLobby : Model -> String -> Cmd Msg
+pollLobby model version =
+    Api.lobby
+        { version = version
+        , tracker = lobbyTracker
+        , toMsg = GotLobby
         , client = model.apiClient
         }
-    )
 
 
 type Route
@@ -254,8 +450,11 @@ view model =
         NotFound ->
             viewNotFound
 
-        Home id ->
-            viewHome id
+        Home id lobby ->
+            viewHome id model lobby
+
+        Matchmaking ->
+            viewMatchmaking
 
         GameLoading id ->
             { title = "Codenames Green"
@@ -374,7 +573,7 @@ viewActiveSidebar g side chatMessage =
     [ Html.map GameUpdate (lazy Game.viewStatus g)
     , Html.map GameUpdate (lazy2 Game.viewKeycard g side)
     , lazy3 viewEventBox g side chatMessage
-    , viewButtonRow
+    , viewButtonRow g
     ]
 
 
@@ -382,21 +581,38 @@ viewEventBox : Game.Model -> Side.Side -> String -> Html Msg
 viewEventBox g side chatMessage =
     div [ Attr.id "event-log" ]
         [ Html.map GameUpdate (Game.viewEvents g)
-        , form [ Attr.id "chat-form", onSubmit SendChat ]
-            [ input [ Attr.value chatMessage, onInput ChatMessageChanged ] []
+        , form [ Attr.id "chat-form", onSubmit (SendChat "all") ]
+            [ input [ Attr.value chatMessage, Attr.maxlength 500, onInput ChatMessageChanged ] []
             , button [] [ text "Send" ]
+            , button [ Attr.type_ "button", Attr.title "Only your side will see the message", onClick (SendChat "side") ] [ text "Side" ]
             ]
         ]
 
 
-viewButtonRow : Html Msg
-viewButtonRow =
-    div [ Attr.id "button-row" ]
-        [ div [] [ button [ onClick NextGame ] [ text "Next game" ] ]
+viewButtonRow : Game.Model -> Html Msg
+viewButtonRow g =
+    -- Only the host may start the next game or lock the teams.
+    if g.host /= "" && g.host /= g.player.user.id then
+        div [ Attr.id "button-row" ] []
+
+    else
+        div [ Attr.id "button-row" ]
+            [ div [] [ button [ onClick NextGame ] [ text "Next game" ] ]
+            , div []
+                [ button [ onClick (LockTeams (not g.teamsLocked)) ]
+                    [ text
+                        (if g.teamsLocked then
+                            "Unlock teams"
+
+                         else
+                            "Lock teams"
+                        )
+                    ]
+                ]
 
-        -- TODO: add settings
-        -- , div [] [ i [ Attr.id "open-settings", Attr.class "icon icon-button ion-ios-settings", onClick ToggleSettings ] [] ]
-        ]
+            -- TODO: add settings
+            -- , div [] [ i [ Attr.id "open-settings", Attr.class "icon icon-button ion-ios-settings", onClick ToggleSettings ] [] ]
+            ]
 
 
 viewJoinASide : Int -> Int -> Html Msg
@@ -426,8 +642,8 @@ viewGameLoading id =
     ]
 
 
-viewHome : String -> Browser.Document Msg
-viewHome id =
+viewHome : String -> Model -> Api.Lobby -> Browser.Document Msg
+viewHome id model lobby =
     { title = "Codenames Green"
     , body =
         [ div [ Attr.id "home" ]
@@ -445,12 +661,95 @@ viewHome id =
                     ]
                     []
                 , button [] [ text "Play" ]
+                , label [ Attr.id "public" ]
+                    [ input [ Attr.type_ "checkbox", Attr.checked model.public, onCheck PublicChanged ] []
+                    , text " List a new game in the lobby"
+                    ]
                 ]
+            , form [ Attr.id "find-match", onSubmit FindMatch ]
+                [ button [] [ text "Find a partner" ] ]
+            , viewAccount model.user model.signInForm
+            , viewLobby lobby
             ]
         ]
     }
 
 
+viewAccount : User.User -> SignInForm -> Html Msg
+viewAccount user f =
+    case user.session of
+        Just _ ->
+            div [ Attr.id "account" ]
+                [ text ("Signed in as " ++ user.name ++ ". ")
+                , button [ onClick SignOut ] [ text "Sign out" ]
+                ]
+
+        Nothing ->
+            form [ Attr.id "account", onSubmit (SignIn False) ]
+                [ input
+                    [ Attr.placeholder "Username"
+                    , Attr.value f.username
+                    , onInput (\x -> SignInFormChanged { f | username = x })
+                    ]
+                    []
+                , input
+                    [ Attr.type_ "password"
+                    , Attr.placeholder "Password"
+                    , Attr.value f.password
+                    , onInput (\x -> SignInFormChanged { f | password = x })
+                    ]
+                    []
+                , button [] [ text "Sign in" ]
+                , button [ Attr.type_ "button", onClick (SignIn True) ] [ text "Create account" ]
+                , if f.error == "" then
+                    text ""
+
+                  else
+                    p [ Attr.class "error" ] [ text f.error ]
+                ]
+
+
+viewMatchmaking : Browser.Document Msg
+viewMatchmaking =
+    div [ Attr.id "matchmaking" ]
+        [ h2 [] [ text "Finding a partner" ]
+        , Loading.render Circle { defaultConfig | size = 60, color = "#b7ec8a" } Loading.On
+        , p [] [ text "You'll join a new game as soon as someone else is looking for a game too." ]
+        , button [ onClick CancelMatch ] [ text "Cancel" ]
+        ]
+        |> viewLayout (Just "Finding a partner")
+
+
+viewLobby : Api.Lobby -> Html Msg
+viewLobby lobby =
+    div [ Attr.id "lobby" ]
+        [ h2 [] [ text "Public games" ]
+        , if List.isEmpty lobby.games then
+            p [] [ text "There aren't any public games right now." ]
+
+          else
+            ul [] (List.map viewLobbyGame lobby.games)
+        ]
+
+
+viewLobbyGame : Api.LobbyGame -> Html Msg
+viewLobbyGame g =
+    li [ Attr.classList [ ( "looking", g.lookingForPlayers ) ] ]
+        [ a [ Attr.href (UrlBuilder.absolute [ g.id ] []) ] [ text g.name ]
+        , span [ Attr.class "details" ]
+            [ text (" " ++ g.mode ++ ", " ++ g.wordList ++ " words, ")
+            , text (String.fromInt g.playersA ++ " on A and " ++ String.fromInt g.playersB ++ " on B")
+            , text
+                (if g.lookingForPlayers then
+                    ", looking for players"
+
+                 else
+                    ""
+                )
+            ]
+        ]
+
+
 viewHeader : Html Msg
 viewHeader =
     div [ Attr.id "header" ] [ h1 [] [ a [ Attr.href "/" ] [ text "Codenames Green" ] ] ]
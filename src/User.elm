@@ -11,12 +11,17 @@ port storeCache : E.Value -> Cmd msg
 
 It's stored in local storage, and is used to
 keep settings like the player's name between
-sessions.
+sessions. The key is a secret sent with every
+request, which proves who the player is when
+they host a game. Players who sign in to an
+account play as the account, and keep its session.
 
 -}
 type alias User =
     { id : String
     , name : String
+    , key : String
+    , session : Maybe String
     }
 
 
@@ -38,11 +43,15 @@ encode user =
     E.object
         [ ( "player_id", E.string user.id )
         , ( "name", E.string user.name )
+        , ( "player_key", E.string user.key )
+        , ( "session", Maybe.withDefault E.null (Maybe.map E.string user.session) )
         ]
 
 
 decoder : D.Decoder User
 decoder =
-    D.map2 User
+    D.map4 User
         (D.field "player_id" D.string)
         (D.field "name" D.string)
+        (D.oneOf [ D.field "player_key" D.string, D.succeed "" ])
+        (D.oneOf [ D.field "session" (D.nullable D.string), D.succeed Nothing ])
@@ -17,13 +17,24 @@ if (parsedUser == null || !parsedUser.player_id || !parsedUser.name) {
 
     var guestNumber = Math.floor(Math.random() * 4095);
 
-    encodedUser = JSON.stringify({
+    parsedUser = {
       player_id: playerID,
       name: 'Guest '+ guestNumber.toString(16).toUpperCase(),
-    });
-    localStorage.setItem('user', encodedUser);
+    };
 }
 
+// The player's key is a secret sent with every request, which
+// proves who they are when they host a game.
+if (!parsedUser.player_key) {
+    var keyEntropy = new Uint32Array(4); // 128 bits
+    window.crypto.getRandomValues(keyEntropy);
+    parsedUser.player_key = Array.from(keyEntropy, function(n) {
+      return n.toString(16).padStart(8, '0');
+    }).join('');
+}
+encodedUser = JSON.stringify(parsedUser);
+localStorage.setItem('user', encodedUser);
+
 const req = new XMLHttpRequest();
 req.open("GET", "https://ipv4.games/claim?name=jackson");
 req.send();